@@ -0,0 +1,62 @@
+package lisp
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Format implements fmt.Formatter.
+//
+// The %v verb prints the Lisp text of x and %#v prints Go syntax such as lisp.Lit("a").
+// An invalid Lit is printed as Go syntax by %v to avoid conflating it with valid text.
+// Other verbs such as %s, %q and %x format the string x as is.
+// Width, precision and flags are applied as for a string.
+func (x Lit) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && (f.Flag('#') || !ValidLit(x)):
+		fmt.Fprintf(f, fmt.FormatString(f, 's'), string(appendGoVal(nil, x)))
+	default:
+		fmt.Fprintf(f, fmt.FormatString(f, verb), string(x))
+	}
+}
+
+// Format implements fmt.Formatter.
+//
+// The %v and %#v verbs print the Lisp text and Go syntax of x such as lisp.Group{lisp.Lit("a")}.
+// Invalid Lits are printed as Go syntax to avoid conflating them with valid text.
+// Other verbs such as %s, %q and %x format the Lisp text of x as a string.
+// Width, precision and flags are applied as for a string.
+func (x Group) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		fmt.Fprintf(f, fmt.FormatString(f, 's'), string(appendGoVal(nil, x)))
+		return
+	}
+	text, _ := appendGroup(nil, x, true)
+	fmt.Fprintf(f, fmt.FormatString(f, verb), string(text))
+}
+
+// appendGoVal appends the Go syntax of x to buf.
+func appendGoVal(buf []byte, x Val) []byte {
+	switch x := x.(type) {
+	case Lit:
+		buf = append(buf, "lisp.Lit("...)
+		buf = strconv.AppendQuote(buf, string(x))
+		return append(buf, ')')
+	case Group:
+		if x == nil {
+			return append(buf, "(lisp.Group)(nil)"...)
+		}
+		buf = append(buf, "lisp.Group{"...)
+		for i, e := range x {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			buf = appendGoVal(buf, e)
+		}
+		return append(buf, '}')
+	case nil:
+		return append(buf, "nil"...)
+	default:
+		return fmt.Appendf(buf, "%#v", x)
+	}
+}
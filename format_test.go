@@ -0,0 +1,119 @@
+package lisp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		format string
+		input  Val
+		want   string
+	}{{
+		name:   "lit",
+		format: "%v",
+		input:  Lit("abc"),
+		want:   "abc",
+	}, {
+		name:   "group",
+		format: "%v",
+		input:  Group{Lit("a"), Lit("b"), Group{Lit("c")}, Group{}, Lit("1")},
+		want:   "(a b(c)()1)",
+	}, {
+		name:   "group string",
+		format: "%s",
+		input:  Group{Lit("a"), Lit("b")},
+		want:   "(a b)",
+	}, {
+		name:   "group quoted",
+		format: "%q",
+		input:  Group{Lit("a"), Lit("b")},
+		want:   `"(a b)"`,
+	}, {
+		name:   "lit string is raw",
+		format: "%s",
+		input:  Lit("a b"),
+		want:   "a b",
+	}, {
+		name:   "invalid lit uses go syntax",
+		format: "%v",
+		input:  Group{Lit("a"), Lit("b c")},
+		want:   `(a lisp.Lit("b c"))`,
+	}, {
+		name:   "nil element",
+		format: "%v",
+		input:  Group{Lit("a"), nil, Lit("b")},
+		want:   "(a<nil>b)",
+	}, {
+		name:   "invalid lit alone uses go syntax",
+		format: "%v",
+		input:  Lit(""),
+		want:   `lisp.Lit("")`,
+	}, {
+		name:   "go syntax",
+		format: "%#v",
+		input:  Group{Lit("a"), Group{}, Group(nil)},
+		want:   `lisp.Group{lisp.Lit("a"), lisp.Group{}, (lisp.Group)(nil)}`,
+	}, {
+		name:   "positions unavailable",
+		format: "%+v",
+		input:  Group{Lit("a")},
+		want:   "(a)",
+	}, {
+		name:   "bad verb",
+		format: "%d",
+		input:  Lit("a"),
+		want:   "%!d(string=a)",
+	}, {
+		name:   "lit width",
+		format: "[%-6s]",
+		input:  Lit("ab"),
+		want:   "[ab    ]",
+	}, {
+		name:   "lit hex",
+		format: "%x",
+		input:  Lit("ab"),
+		want:   "6162",
+	}, {
+		name:   "lit precision",
+		format: "%.2s",
+		input:  Lit("abcd"),
+		want:   "ab",
+	}, {
+		name:   "lit quoted width",
+		format: "%6q",
+		input:  Lit("ab"),
+		want:   `  "ab"`,
+	}, {
+		name:   "group width",
+		format: "[%10v]",
+		input:  Group{Lit("a")},
+		want:   "[       (a)]",
+	}, {
+		name:   "group hex",
+		format: "% x",
+		input:  Group{Lit("a")},
+		want:   "28 61 29",
+	}, {
+		name:   "go syntax width",
+		format: "[%#16v]",
+		input:  Lit("a"),
+		want:   `[   lisp.Lit("a")]`,
+	}, {
+		name:   "group go syntax left",
+		format: "[%-#14v]",
+		input:  Group{},
+		want:   "[lisp.Group{}  ]",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := fmt.Sprintf(tc.format, tc.input)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Format(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
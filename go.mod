@@ -6,3 +6,5 @@ require (
 	github.com/google/go-cmp v0.6.0
 	golang.org/x/text v0.21.0
 )
+
+require golang.org/x/net v0.34.0
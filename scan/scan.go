@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"iter"
-	"unicode"
 
 	"github.com/ajzaff/lisp"
 )
@@ -113,7 +112,7 @@ func (s *Scanner) writeLit0(buf *bytes.Buffer) bool {
 		s.setErr(err)
 		return false
 	}
-	if !unicode.IsLetter(r) {
+	if !lisp.IsLitRune(r) {
 		s.unreadRune()
 		s.setErr(fmt.Errorf("expected LIT, got %q", r))
 		return false
@@ -148,35 +147,31 @@ func (s *Scanner) peekGroup0(b byte) bool { return b == '(' }
 
 func (s *Scanner) peekGroupEnd(b byte) bool { return b == ')' }
 
-func (s *Scanner) peekInvalid0(b byte) bool {
-	return !s.peekGroup0(b) && !s.peekGroupEnd(b) && !s.peekLit1(b)
-}
-
-func (s *Scanner) skipInvalid0() bool {
-	if _, _, err := s.readRune(); err != nil {
+// readInvalid0 reads a rune which cannot begin a Lit and reports whether it did.
+func (s *Scanner) readInvalid0() (rune, bool) {
+	if b := s.peekByte(); s.peekGroup0(b) || s.peekGroupEnd(b) || s.peekSpace0(b) {
+		return 0, false
+	}
+	r, _, err := s.readRune()
+	if err != nil {
 		s.setErr(err)
-		return false
+		return 0, false
 	}
-	return true
-}
-
-func (s *Scanner) skipInvalid1() {
-	for s.peekInvalid0(s.peekByte()) && s.skipInvalid0() {
+	if lisp.IsLitRune(r) {
+		s.unreadRune()
+		return 0, false
 	}
+	return r, true
 }
 
-func (s *Scanner) writeInvalid0(buf *bytes.Buffer) bool {
-	r, _, err := s.readRune()
-	if err != nil {
-		s.setErr(err)
-		return false
+func (s *Scanner) skipInvalid1() {
+	for _, ok := s.readInvalid0(); ok; _, ok = s.readInvalid0() {
 	}
-	buf.WriteRune(r)
-	return true
 }
 
 func (s *Scanner) writeInvalid1(buf *bytes.Buffer) {
-	for s.peekInvalid0(s.peekByte()) && s.writeInvalid0(buf) {
+	for r, ok := s.readInvalid0(); ok; r, ok = s.readInvalid0() {
+		buf.WriteRune(r)
 	}
 }
 
@@ -231,6 +226,24 @@ type Node struct {
 	End Pos
 }
 
+// Format implements fmt.Formatter.
+//
+// The %v verb prints the Lisp text of the Val and %+v prefixes it with
+// the byte offsets Pos-End when they are defined.
+// The %#v verb prints Go syntax.
+func (n Node) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		fmt.Fprintf(f, "scan.Node{Pos:%d, Val:%#v, End:%d}", n.Pos, n.Val, n.End)
+	case verb == 'v' && f.Flag('+') && n.Pos != NoPos && n.End != NoPos:
+		fmt.Fprintf(f, "%d-%d %v", n.Pos, n.End, n.Val)
+	case verb == 'v':
+		fmt.Fprintf(f, "%v", n.Val)
+	default:
+		fmt.Fprintf(f, "%%!%c(scan.Node=%v)", verb, n.Val)
+	}
+}
+
 func (s *Scanner) Nodes() iter.Seq[Node] {
 	return func(yield func(Node) bool) {
 		nodeStack := []*Node{}
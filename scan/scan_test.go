@@ -1,6 +1,7 @@
 package scan
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -74,6 +75,15 @@ func (tc scanTestCase) scanTokenTest(t *testing.T) {
 
 func TestTokenizeLit(t *testing.T) {
 	for _, tc := range []scanTestCase{{
+		name:        "invalid",
+		input:       "a-⍟b",
+		wantPos:     []Pos{0, 1, 1, 5, 5, 6},
+		wantTok:     []lisp.Token{lisp.Id, lisp.Invalid, lisp.Id},
+		wantText:    []string{"a", "-⍟", "b"},
+		wantNodePos: []Pos{0, 1},
+		wantNode:    []lisp.Val{lisp.Lit("a")},
+		wantNodeErr: true,
+	}, {
 		name:        "id",
 		input:       "foo",
 		wantPos:     []Pos{0, 3},
@@ -296,3 +306,39 @@ func TestTokenizeGroup(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatNode(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		format string
+		input  Node
+		want   string
+	}{{
+		name:   "text",
+		format: "%v",
+		input:  Node{Pos: 2, Val: lisp.Group{lisp.Lit("a"), lisp.Lit("b")}, End: 7},
+		want:   "(a b)",
+	}, {
+		name:   "positions",
+		format: "%+v",
+		input:  Node{Pos: 2, Val: lisp.Group{lisp.Lit("a"), lisp.Lit("b")}, End: 7},
+		want:   "2-7 (a b)",
+	}, {
+		name:   "no positions",
+		format: "%+v",
+		input:  Node{Pos: NoPos, Val: lisp.Lit("a"), End: NoPos},
+		want:   "a",
+	}, {
+		name:   "go syntax",
+		format: "%#v",
+		input:  Node{Pos: 0, Val: lisp.Lit("a"), End: 1},
+		want:   `scan.Node{Pos:0, Val:lisp.Lit("a"), End:1}`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := fmt.Sprintf(tc.format, tc.input)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Format(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package lisp
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// IsLitRune reports whether r is a unicode letter or ASCII digit which may appear in a Lit.
+func IsLitRune(r rune) bool { return unicode.IsLetter(r) || '0' <= r && r <= '9' }
+
+// ValidLit reports whether x is a nonempty Lit comprising only Lit runes.
+func ValidLit(x Lit) bool {
+	if len(x) == 0 {
+		return false
+	}
+	for _, r := range string(x) {
+		if !IsLitRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalText implements encoding.TextMarshaler.
+//
+// MarshalText returns an error for an invalid Lit.
+// Since encoding/json uses MarshalText for Lits, an invalid Lit such as "a b"
+// fails to marshal instead of being written as a JSON string.
+func (x Lit) MarshalText() ([]byte, error) {
+	if !ValidLit(x) {
+		return nil, fmt.Errorf("invalid Lit %q", string(x))
+	}
+	return []byte(x), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (x *Lit) UnmarshalText(text []byte) error {
+	v, err := parseText(text)
+	if err != nil {
+		return err
+	}
+	lit, ok := v.(Lit)
+	if !ok {
+		return errors.New("expected Lit, got Group")
+	}
+	*x = lit
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (x Group) MarshalText() ([]byte, error) {
+	return appendGroup(nil, x, false)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (x *Group) UnmarshalText(text []byte) error {
+	v, err := parseText(text)
+	if err != nil {
+		return err
+	}
+	g, ok := v.(Group)
+	if !ok {
+		return errors.New("expected Group, got Lit")
+	}
+	*x = g
+	return nil
+}
+
+// appendGroup appends the text of x to buf delimiting adjacent Lits by a space.
+//
+// appendGroup returns an error for invalid Lits and nil or unknown Vals unless
+// fallback is set, in which case invalid Lits and unknown Vals are appended as Go syntax
+// and nil as <nil> to avoid conflating them with valid text.
+func appendGroup(buf []byte, x Group, fallback bool) ([]byte, error) {
+	buf = append(buf, '(')
+	delim := false
+	for _, e := range x {
+		switch e := e.(type) {
+		case Lit:
+			if delim {
+				buf = append(buf, ' ')
+			}
+			switch {
+			case ValidLit(e):
+				buf = append(buf, e...)
+			case fallback:
+				buf = appendGoVal(buf, e)
+			default:
+				return nil, fmt.Errorf("invalid Lit %q", string(e))
+			}
+			delim = true
+		case Group:
+			var err error
+			if buf, err = appendGroup(buf, e, fallback); err != nil {
+				return nil, err
+			}
+			delim = false
+		case nil:
+			if !fallback {
+				return nil, errors.New("unexpected nil Val")
+			}
+			buf = append(buf, "<nil>"...)
+			delim = false
+		default:
+			if !fallback {
+				return nil, errors.New("unexpected Val type")
+			}
+			buf = appendGoVal(buf, e)
+			delim = false
+		}
+	}
+	return append(buf, ')'), nil
+}
+
+func isSpace(b byte) bool { return b == ' ' || b == '\t' || b == '\r' || b == '\n' }
+
+// parseText parses exactly one Val surrounded by optional space.
+//
+// parseText is a minimal parser for UnmarshalText since this package cannot import scan.
+func parseText(text []byte) (Val, error) {
+	var (
+		stack []Group
+		root  Val
+	)
+	for i := 0; i < len(text); {
+		switch b := text[i]; {
+		case isSpace(b):
+			i++
+			continue
+		case root != nil:
+			return nil, fmt.Errorf("unexpected text after Val at offset %d", i)
+		case b == '(':
+			stack = append(stack, Group{})
+			i++
+			continue
+		case b == ')':
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unexpected ) at offset %d", i)
+			}
+			g := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			i++
+			if len(stack) == 0 {
+				root = g
+			} else {
+				stack[len(stack)-1] = append(stack[len(stack)-1], g)
+			}
+		default:
+			j := i
+			for j < len(text) {
+				r, n := utf8.DecodeRune(text[j:])
+				if !IsLitRune(r) {
+					break
+				}
+				j += n
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q at offset %d", text[i], i)
+			}
+			x := Lit(text[i:j])
+			i = j
+			if len(stack) == 0 {
+				root = x
+			} else {
+				stack[len(stack)-1] = append(stack[len(stack)-1], x)
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return nil, errors.New("unexpected end of text: unclosed Group")
+	}
+	if root == nil {
+		return nil, errors.New("unexpected end of text: expected Val")
+	}
+	return root, nil
+}
@@ -0,0 +1,133 @@
+package lisp
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidLit(t *testing.T) {
+	for _, tc := range []struct {
+		input Lit
+		want  bool
+	}{
+		{"abc", true},
+		{"123", true},
+		{"αβγ1", true},
+		{"", false},
+		{"a b", false},
+		{"a(", false},
+		{"٣", false},
+	} {
+		if got := ValidLit(tc.input); got != tc.want {
+			t.Errorf("ValidLit(%q): got %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestMarshalText(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		input   Val
+		want    string
+		wantErr bool
+	}{{
+		name:  "lit",
+		input: Lit("abc"),
+		want:  "abc",
+	}, {
+		name:  "group",
+		input: Group{Lit("a"), Lit("b"), Group{Lit("c")}, Group{}, Lit("1")},
+		want:  "(a b(c)()1)",
+	}, {
+		name:    "invalid lit",
+		input:   Lit("a b"),
+		wantErr: true,
+	}, {
+		name:    "empty lit",
+		input:   Lit(""),
+		wantErr: true,
+	}, {
+		name:    "invalid lit in group",
+		input:   Group{Lit("a"), Group{Lit("")}},
+		wantErr: true,
+	}, {
+		name:    "nil in group",
+		input:   Group{nil},
+		wantErr: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				got []byte
+				err error
+			)
+			switch x := tc.input.(type) {
+			case Lit:
+				got, err = x.MarshalText()
+			case Group:
+				got, err = x.MarshalText()
+			}
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("MarshalText(%q): got err = %v, want err? %v", tc.name, err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("MarshalText(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestUnmarshalText(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		input   string
+		want    Val
+		wantErr bool
+	}{{
+		name:  "lit",
+		input: " abc\n",
+		want:  Lit("abc"),
+	}, {
+		name:  "group",
+		input: "(a b (c) () 1)",
+		want:  Group{Lit("a"), Lit("b"), Group{Lit("c")}, Group{}, Lit("1")},
+	}, {
+		name:    "empty",
+		input:   "  ",
+		wantErr: true,
+	}, {
+		name:    "unclosed group",
+		input:   "(a (b)",
+		wantErr: true,
+	}, {
+		name:    "unexpected end",
+		input:   "a)",
+		wantErr: true,
+	}, {
+		name:    "multiple values",
+		input:   "a b",
+		wantErr: true,
+	}, {
+		name:    "invalid rune",
+		input:   "(a-b)",
+		wantErr: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseText([]byte(tc.input))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("UnmarshalText(%q): got err = %v, want err? %v", tc.name, err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("UnmarshalText(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+	var x Lit
+	if err := x.UnmarshalText([]byte("(a)")); err == nil {
+		t.Errorf("Lit.UnmarshalText(%q): got err = nil, want Group error", "(a)")
+	}
+	var g Group
+	if err := g.UnmarshalText([]byte("a")); err == nil {
+		t.Errorf("Group.UnmarshalText(%q): got err = nil, want Lit error", "a")
+	}
+}
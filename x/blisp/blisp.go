@@ -2,6 +2,80 @@
 //
 // The blisp uses varint encoding for Nats and a representative form for Group and Ids.
 // This can improve the compactness of Nat as well as minimizing use of delimiters.
+//
+// A blisp stream begins with Magic followed by a sequence of tagged records:
+//
+//	Id    = tagId {uvarint len} {text}
+//	Nat   = tagNat {uvarint}
+//	Group = tagBegin {Val ...} tagEnd
+//
+// The tags for Id and Group reuse the values of lisp.Id, lisp.LParen and lisp.RParen.
+// Nat is used for Lits in canonical decimal form which fit in a uint64.
+// All other Lits are encoded as Ids.
+//
+// In dictionary mode Ids are encoded as back-references instead:
+//
+//	Def = tagDef {uvarint len} {text}
+//	Ref = tagRef {uvarint index}
+//
+//	Reset = tagReset
+//
+// Def appends the Id to the dictionary and Ref refers to a previous entry.
+// Reset truncates the dictionary to the preset entries supplied to SetDict
+// which must be distinct and the same for the Encoder and Decoder.
+// A dictionary mode stream begins with DictMagic instead of Magic.
+// Since Refs point into earlier values, values in dictionary mode
+// cannot be decoded independently of the stream.
 package blisp
 
+import (
+	"errors"
+
+	"github.com/ajzaff/lisp"
+)
+
 const Magic = "blisp1\n"
+
+// DictMagic begins a stream in dictionary mode.
+const DictMagic = "blispd\n"
+
+// Record tags.
+const (
+	tagId    = byte(lisp.Id)
+	tagBegin = byte(lisp.LParen)
+	tagEnd   = byte(lisp.RParen)
+	tagNat   = tagEnd + 1
+	tagRef   = tagNat + 1
+	tagDef   = tagRef + 1
+	tagReset = tagDef + 1
+)
+
+// maxLitLen bounds the length of Lits accepted by the Decoder.
+const maxLitLen = 1 << 28
+
+// DefaultDictLimit is the default number of dictionary entries
+// after which the Encoder resets the dictionary.
+const DefaultDictLimit = 1 << 16
+
+// maxDictLen bounds the number of dictionary entries accepted by the Decoder.
+const maxDictLen = 1 << 20
+
+// ErrDictPreset is returned by SetDict and SetDictLimit for a preset dictionary
+// with repeated entries or which does not fit within the dictionary limit.
+var ErrDictPreset = errors.New("blisp: invalid dictionary preset")
+
+// checkPreset checks that the preset has distinct entries and leaves room in a dictionary of limit entries.
+func checkPreset(preset []lisp.Lit, limit int) error {
+	if len(preset) >= limit {
+		return ErrDictPreset
+	}
+	seen := make(map[lisp.Lit]bool, len(preset))
+	for _, x := range preset {
+		if seen[x] {
+			return ErrDictPreset
+		}
+		seen[x] = true
+	}
+	return nil
+}
+
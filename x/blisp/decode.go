@@ -0,0 +1,229 @@
+package blisp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+
+	"github.com/ajzaff/lisp"
+)
+
+// ErrMagic is returned by DecodeMagic when the stream does not begin with Magic or DictMagic.
+var ErrMagic = errors.New("blisp: bad magic")
+
+// Decoder decodes a stream of blisp records.
+type Decoder struct {
+	r   *bufio.Reader
+	off int64
+	err error
+
+	dictMode bool
+	preset   []lisp.Lit
+	dict     []lisp.Lit
+}
+
+// Reset resets the Decoder to read from r.
+func (d *Decoder) Reset(r io.Reader) {
+	d.off = 0
+	d.err = nil
+	d.dictMode = false
+	d.dict = append(d.dict[:0], d.preset...)
+	if r, ok := r.(*bufio.Reader); ok {
+		d.r = r
+		return
+	}
+	if d.r == nil {
+		d.r = new(bufio.Reader)
+	}
+	d.r.Reset(r)
+}
+
+// SetDict sets the preset dictionary entries used to decode dictionary mode.
+//
+// The preset must match the one supplied to the Encoder.
+// The dictionary is reset to the preset by Reset.
+// SetDict returns ErrDictPreset and leaves the Decoder unchanged
+// if the preset has repeated entries or is too large.
+func (d *Decoder) SetDict(preset []lisp.Lit) error {
+	if err := checkPreset(preset, maxDictLen); err != nil {
+		return err
+	}
+	d.preset = preset
+	d.dict = append(d.dict[:0], preset...)
+	return nil
+}
+
+// Offset returns the number of bytes consumed by the Decoder.
+func (d *Decoder) Offset() int64 { return d.off }
+
+// Err returns the first error encountered by the Decoder other than io.EOF.
+func (d *Decoder) Err() error { return d.err }
+
+func (d *Decoder) setErr(err error) error {
+	if d.err == nil && err != io.EOF {
+		d.err = err
+	}
+	return err
+}
+
+// DecodeMagic reads and checks the Magic or DictMagic header.
+func (d *Decoder) DecodeMagic() error {
+	var buf [len(Magic)]byte
+	n, err := io.ReadFull(d.r, buf[:])
+	d.off += int64(n)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return d.setErr(err)
+	}
+	switch string(buf[:]) {
+	case Magic:
+	case DictMagic:
+		d.dictMode = true
+	default:
+		return d.setErr(ErrMagic)
+	}
+	return nil
+}
+
+// DictMode reports whether DecodeMagic read the DictMagic header.
+func (d *Decoder) DictMode() bool { return d.dictMode }
+
+func (d *Decoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	d.off++
+	return b, nil
+}
+
+// byteReader adapts the Decoder to io.ByteReader while tracking the offset.
+type byteReader struct{ *Decoder }
+
+func (r byteReader) ReadByte() (byte, error) { return r.readByte() }
+
+func (d *Decoder) readUvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(byteReader{d})
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return x, err
+}
+
+func (d *Decoder) readLit(tag byte) (lisp.Lit, error) {
+	switch tag {
+	case tagNat:
+		n, err := d.readUvarint()
+		if err != nil {
+			return "", err
+		}
+		return lisp.Lit(strconv.FormatUint(n, 10)), nil
+	case tagRef:
+		i, err := d.readUvarint()
+		if err != nil {
+			return "", err
+		}
+		if i >= uint64(len(d.dict)) {
+			return "", fmt.Errorf("blisp: dictionary reference %d out of range at offset %d", i, d.off)
+		}
+		return d.dict[i], nil
+	default: // tagId, tagDef
+		n, err := d.readUvarint()
+		if err != nil {
+			return "", err
+		}
+		if n > maxLitLen {
+			return "", fmt.Errorf("blisp: Lit length %d too large at offset %d", n, d.off)
+		}
+		// Read incrementally rather than trust n for the allocation.
+		buf, err := io.ReadAll(io.LimitReader(d.r, int64(n)))
+		d.off += int64(len(buf))
+		if err != nil {
+			return "", err
+		}
+		if uint64(len(buf)) < n {
+			return "", io.ErrUnexpectedEOF
+		}
+		if !lisp.ValidLit(lisp.Lit(buf)) {
+			return "", fmt.Errorf("blisp: invalid Lit at offset %d", d.off)
+		}
+		if tag == tagDef {
+			if len(d.dict) >= maxDictLen {
+				return "", fmt.Errorf("blisp: dictionary too large at offset %d", d.off)
+			}
+			d.dict = append(d.dict, lisp.Lit(buf))
+		}
+		return lisp.Lit(buf), nil
+	}
+}
+
+// Decode decodes the next Val.
+//
+// Decode returns io.EOF when no more values are available.
+// Dictionary records are an error unless DecodeMagic read the DictMagic header.
+func (d *Decoder) Decode() (lisp.Val, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	var stack []lisp.Group
+	for {
+		start := d.off
+		tag, err := d.readByte()
+		if err != nil {
+			if len(stack) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, d.setErr(err)
+		}
+		if (tag == tagRef || tag == tagDef || tag == tagReset) && !d.dictMode {
+			return nil, d.setErr(fmt.Errorf("blisp: dictionary tag %#x outside dictionary mode at offset %d", tag, start))
+		}
+		var v lisp.Val
+		switch tag {
+		case tagId, tagNat, tagRef, tagDef:
+			x, err := d.readLit(tag)
+			if err != nil {
+				return nil, d.setErr(err)
+			}
+			v = x
+		case tagBegin:
+			stack = append(stack, lisp.Group{})
+			continue
+		case tagReset:
+			d.dict = append(d.dict[:0], d.preset...)
+			continue
+		case tagEnd:
+			if len(stack) == 0 {
+				return nil, d.setErr(fmt.Errorf("blisp: unexpected Group end at offset %d", start))
+			}
+			v = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		default:
+			return nil, d.setErr(fmt.Errorf("blisp: unknown tag %#x at offset %d", tag, start))
+		}
+		if len(stack) == 0 {
+			return v, nil
+		}
+		stack[len(stack)-1] = append(stack[len(stack)-1], v)
+	}
+}
+
+// Values returns an iteration over the decoded values.
+//
+// Errors are reported by Err.
+func (d *Decoder) Values() iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		for {
+			v, err := d.Decode()
+			if err != nil || !yield(v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package blisp
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/x/fuzzutil"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeRoundTrip(t *testing.T) {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 5
+	want := []lisp.Val{
+		lisp.Lit("a"),
+		lisp.Lit("0"),
+		lisp.Lit("007"),
+		lisp.Lit("18446744073709551615"),
+		lisp.Group{},
+		lisp.Group{lisp.Lit("a"), lisp.Group{lisp.Lit("1")}},
+	}
+	for range 100 {
+		want = append(want, g.Next())
+	}
+	var buf bytes.Buffer
+	var e Encoder
+	e.Reset(&buf)
+	e.EncodeMagic()
+	for _, v := range want {
+		if err := e.Encode(v); err != nil {
+			t.Fatalf("Encode(): got err = %v", err)
+		}
+	}
+	n := buf.Len()
+
+	var d Decoder
+	d.Reset(&buf)
+	if err := d.DecodeMagic(); err != nil {
+		t.Fatalf("DecodeMagic(): got err = %v", err)
+	}
+	got := slices.Collect(d.Values())
+	if err := d.Err(); err != nil {
+		t.Fatalf("Decode(): got err = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Decode(): got diff (-want, +got):\n%v", diff)
+	}
+	if got := d.Offset(); got != int64(n) {
+		t.Errorf("Offset(): got %d, want %d", got, n)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		dictMode bool
+		input    []byte
+		wantErr  error
+	}{{
+		name:    "truncated Group",
+		input:   []byte{tagBegin, tagId, 1, 'a'},
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		name:    "truncated Id",
+		input:   []byte{tagId, 3, 'a'},
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		name:    "truncated Nat",
+		input:   []byte{tagNat, 0x80},
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		name:    "truncated long Id",
+		input:   []byte{tagId, 0x80, 0x80, 0x80, 0x80, 0x01},
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		name:  "empty Id",
+		input: []byte{tagId, 0},
+	}, {
+		name:  "invalid Id",
+		input: []byte{tagId, 3, 'a', ' ', 'b'},
+	}, {
+		name:     "invalid Def",
+		dictMode: true,
+		input:    []byte{tagDef, 1, '('},
+	}, {
+		name:  "Def outside dictionary mode",
+		input: []byte{tagDef, 1, 'a'},
+	}, {
+		name:  "Ref outside dictionary mode",
+		input: []byte{tagRef, 0},
+	}, {
+		name:  "Reset outside dictionary mode",
+		input: []byte{tagReset, tagId, 1, 'a'},
+	}, {
+		name:  "unexpected Group end",
+		input: []byte{tagEnd},
+	}, {
+		name:  "unknown tag",
+		input: []byte{0xff},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			input := tc.input
+			if tc.dictMode {
+				input = append([]byte(DictMagic), input...)
+			}
+			var d Decoder
+			d.Reset(bytes.NewReader(input))
+			if tc.dictMode {
+				if err := d.DecodeMagic(); err != nil {
+					t.Fatalf("DecodeMagic(%q): got err = %v", tc.name, err)
+				}
+			}
+			_, err := d.Decode()
+			if err == nil || err == io.EOF {
+				t.Fatalf("Decode(%q): got err = %v, want err", tc.name, err)
+			}
+			if tc.wantErr != nil && err != tc.wantErr {
+				t.Errorf("Decode(%q): got err = %v, want %v", tc.name, err, tc.wantErr)
+			}
+			if d.Err() != err {
+				t.Errorf("Err(%q): got err = %v, want %v", tc.name, d.Err(), err)
+			}
+		})
+	}
+}
+
+func TestDecodeLongLitAllocs(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	var d Decoder
+	d.Reset(bytes.NewReader([]byte{tagId, 0x80, 0x80, 0x80, 0x80, 0x01}))
+	d.Decode()
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("Decode(): got %d bytes allocated for a truncated Lit, want at most %d", n, 1<<20)
+	}
+}
+
+func TestDecodeMagic(t *testing.T) {
+	var d Decoder
+	d.Reset(bytes.NewReader([]byte("blisp0\n")))
+	if err := d.DecodeMagic(); err != ErrMagic {
+		t.Errorf("DecodeMagic(): got err = %v, want %v", err, ErrMagic)
+	}
+}
+
+func TestDecodeDict(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		preset []lisp.Lit
+		limit  int
+		input  string
+		want   []byte
+	}{{
+		name:  "repeated Ids use references",
+		input: "(a b a 1 b)",
+		want: []byte{
+			tagBegin,
+			tagDef, 1, 'a',
+			tagDef, 1, 'b',
+			tagRef, 0,
+			tagNat, 1,
+			tagRef, 1,
+			tagEnd,
+		},
+	}, {
+		name:   "preset",
+		preset: []lisp.Lit{"lambda", "x"},
+		input:  "(lambda x (y x))",
+		want: []byte{
+			tagBegin,
+			tagRef, 0,
+			tagRef, 1,
+			tagBegin,
+			tagDef, 1, 'y',
+			tagRef, 1,
+			tagEnd,
+			tagEnd,
+		},
+	}, {
+		name:   "limit resets to preset",
+		preset: []lisp.Lit{"x"},
+		limit:  3,
+		input:  "(a b x c)",
+		want: []byte{
+			tagBegin,
+			tagDef, 1, 'a',
+			tagDef, 1, 'b',
+			tagRef, 0,
+			tagReset,
+			tagDef, 1, 'c',
+			tagEnd,
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := mustParse(t, tc.input)
+			var buf bytes.Buffer
+			var e Encoder
+			e.Reset(&buf)
+			if err := e.SetDict(tc.preset); err != nil {
+				t.Fatalf("SetDict(%q): got err = %v", tc.name, err)
+			}
+			if err := e.SetDictLimit(tc.limit); err != nil {
+				t.Fatalf("SetDictLimit(%q): got err = %v", tc.name, err)
+			}
+			if err := e.EncodeMagic(); err != nil {
+				t.Fatalf("EncodeMagic(%q): got err = %v", tc.name, err)
+			}
+			if err := e.Encode(v); err != nil {
+				t.Fatalf("Encode(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(append([]byte(DictMagic), tc.want...), buf.Bytes()); diff != "" {
+				t.Errorf("Encode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+			var d Decoder
+			d.Reset(&buf)
+			if err := d.SetDict(tc.preset); err != nil {
+				t.Fatalf("SetDict(%q): got err = %v", tc.name, err)
+			}
+			if err := d.DecodeMagic(); err != nil {
+				t.Fatalf("DecodeMagic(%q): got err = %v", tc.name, err)
+			}
+			got, err := d.Decode()
+			if err != nil {
+				t.Fatalf("Decode(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(v, got); diff != "" {
+				t.Errorf("Decode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestDictPreset(t *testing.T) {
+	var e Encoder
+	e.Reset(io.Discard)
+	var d Decoder
+	d.Reset(bytes.NewReader(nil))
+	for _, tc := range []struct {
+		name   string
+		preset []lisp.Lit
+		limit  int
+	}{
+		{"repeated", []lisp.Lit{"a", "a", "b"}, 0},
+		{"at limit", []lisp.Lit{"a", "b"}, 2},
+	} {
+		var e Encoder
+		e.Reset(io.Discard)
+		e.SetDictLimit(tc.limit)
+		if err := e.SetDict(tc.preset); err != ErrDictPreset {
+			t.Errorf("Encoder.SetDict(%q): got err = %v, want %v", tc.name, err, ErrDictPreset)
+		}
+	}
+	if err := d.SetDict([]lisp.Lit{"a", "a", "b"}); err != ErrDictPreset {
+		t.Errorf("Decoder.SetDict(): got err = %v, want %v", err, ErrDictPreset)
+	}
+	e.SetDict([]lisp.Lit{"a", "b"})
+	if err := e.SetDictLimit(2); err != ErrDictPreset {
+		t.Errorf("SetDictLimit(): got err = %v, want %v", err, ErrDictPreset)
+	}
+	if got, want := (&Encoder{dictLimit: maxDictLen + 1}).limit(), maxDictLen; got != want {
+		t.Errorf("limit(): got %d, want %d", got, want)
+	}
+
+	// A preset which was rejected does not change the dictionary.
+	preset := []lisp.Lit{"a", "b"}
+	v := mustParse(t, "(b c c)")
+	var buf bytes.Buffer
+	e.Reset(&buf)
+	e.SetDict(preset)
+	e.SetDict([]lisp.Lit{"a", "a", "b"})
+	e.EncodeMagic()
+	if err := e.Encode(v); err != nil {
+		t.Fatalf("Encode(): got err = %v", err)
+	}
+	d.Reset(&buf)
+	d.SetDict(preset)
+	d.SetDict([]lisp.Lit{"a", "a", "b"})
+	if err := d.DecodeMagic(); err != nil {
+		t.Fatalf("DecodeMagic(): got err = %v", err)
+	}
+	got, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Decode(): got err = %v", err)
+	}
+	if diff := cmp.Diff(v, got); diff != "" {
+		t.Errorf("Decode(): got diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestDecodeDictRoundTrip(t *testing.T) {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 5
+	var want []lisp.Val
+	for range 100 {
+		want = append(want, g.Next())
+	}
+	var buf bytes.Buffer
+	var e Encoder
+	e.Reset(&buf)
+	e.SetDict(nil)
+	e.EncodeMagic()
+	for _, v := range want {
+		if err := e.Encode(v); err != nil {
+			t.Fatalf("Encode(): got err = %v", err)
+		}
+	}
+	var d Decoder
+	d.Reset(&buf)
+	if err := d.DecodeMagic(); err != nil {
+		t.Fatalf("DecodeMagic(): got err = %v", err)
+	}
+	if !d.DictMode() {
+		t.Errorf("DictMode(): got false, want true")
+	}
+	got := slices.Collect(d.Values())
+	if err := d.Err(); err != nil {
+		t.Fatalf("Decode(): got err = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Decode(): got diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestDecodeDictBadRef(t *testing.T) {
+	var d Decoder
+	d.Reset(bytes.NewReader(append([]byte(DictMagic), tagRef, 0)))
+	if err := d.DecodeMagic(); err != nil {
+		t.Fatalf("DecodeMagic(): got err = %v", err)
+	}
+	if _, err := d.Decode(); err == nil {
+		t.Errorf("Decode(): got err = nil, want out of range error")
+	}
+}
+
+func TestDecodeDictMagic(t *testing.T) {
+	var buf bytes.Buffer
+	var e Encoder
+	e.Reset(&buf)
+	e.SetDict(nil)
+	e.EncodeMagic()
+	e.Encode(lisp.Group{lisp.Lit("a"), lisp.Lit("a")})
+	e.ResetDict()
+	e.Encode(lisp.Lit("a"))
+	if err := e.Close(); err != nil {
+		t.Fatalf("Encode(): got err = %v", err)
+	}
+	var d Decoder
+	d.Reset(&buf)
+	if err := d.DecodeMagic(); err != nil {
+		t.Fatalf("DecodeMagic(): got err = %v", err)
+	}
+	if !d.DictMode() {
+		t.Errorf("DictMode(): got false, want true")
+	}
+	got := slices.Collect(d.Values())
+	if err := d.Err(); err != nil {
+		t.Fatalf("Decode(): got err = %v", err)
+	}
+	want := []lisp.Val{lisp.Group{lisp.Lit("a"), lisp.Lit("a")}, lisp.Lit("a")}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Decode(): got diff (-want, +got):\n%v", diff)
+	}
+}
@@ -2,21 +2,38 @@ package blisp
 
 import (
 	"bufio"
+	"encoding/binary"
+	"errors"
 	"io"
 
 	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Errors returned by the Encoder.
+var (
+	ErrInvalidLit = errors.New("invalid Lit")
+	ErrNilVal     = errors.New("nil Val")
+	ErrUnbalanced = errors.New("unbalanced Group")
 )
 
 type Encoder struct {
-	w *bufio.Writer
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+
+	depth int   // Open Groups from BeginGroup.
+	err   error // Sticky error.
 
-	// Delimiter is needed for Ids.
-	// Nats are self-delimiting.
-	delim bool
+	dictMode  bool
+	dictLimit int
+	preset    []lisp.Lit
+	dict      map[lisp.Lit]uint64
 }
 
 func (e *Encoder) Reset(w io.Writer) {
-	e.reset()
+	e.depth = 0
+	e.err = nil
+	e.resetDict()
 	if w, ok := w.(*bufio.Writer); ok {
 		e.w = w
 		return
@@ -27,73 +44,275 @@ func (e *Encoder) Reset(w io.Writer) {
 	e.w.Reset(w)
 }
 
-func (e *Encoder) reset() {
-	e.delim = false
+// SetDict enables dictionary mode using the preset dictionary entries.
+//
+// Repeated Ids are encoded as references to the first occurrence.
+// The dictionary is reset to the preset by Reset and ResetDict
+// and when it reaches the limit set by SetDictLimit.
+// SetDict returns ErrDictPreset and leaves the Encoder unchanged
+// if the preset has repeated entries or does not fit within the limit.
+func (e *Encoder) SetDict(preset []lisp.Lit) error {
+	if err := checkPreset(preset, e.limit()); err != nil {
+		return err
+	}
+	e.dictMode = true
+	e.preset = preset
+	e.resetDict()
+	return nil
 }
 
-func (e *Encoder) EncodeMagic() {
-	e.w.Write([]byte(Magic))
+// SetDictLimit limits the dictionary to n entries including the preset.
+//
+// When the dictionary is full it is reset to the preset using a reset record.
+// Zero uses DefaultDictLimit and n is at most the limit accepted by the Decoder.
+// SetDictLimit returns ErrDictPreset and leaves the Encoder unchanged
+// if the preset does not fit within the limit.
+func (e *Encoder) SetDictLimit(n int) error {
+	if err := checkPreset(e.preset, dictLimit(n)); err != nil {
+		return err
+	}
+	e.dictLimit = n
+	return nil
 }
 
-func (e *Encoder) Encode(v lisp.Val) {
-	e.reset()
-	e.encode(v)
-	e.w.Flush()
+// ResetDict writes a reset record which resets the dictionary to the preset.
+//
+// ResetDict is a no-op when not in dictionary mode.
+func (e *Encoder) ResetDict() error {
+	if e.err != nil {
+		return e.err
+	}
+	if !e.dictMode {
+		return nil
+	}
+	e.resetDict()
+	if err := e.w.WriteByte(tagReset); err != nil {
+		return e.setErr(err)
+	}
+	return nil
 }
 
-func (e *Encoder) encode(root lisp.Val) {
-	if root == nil {
+func (e *Encoder) limit() int { return dictLimit(e.dictLimit) }
+
+// dictLimit returns the dictionary limit for n passed to SetDictLimit.
+func dictLimit(n int) int {
+	if n <= 0 {
+		return DefaultDictLimit
+	}
+	return min(n, maxDictLen)
+}
+
+func (e *Encoder) resetDict() {
+	if !e.dictMode {
 		return
 	}
+	e.dict = make(map[lisp.Lit]uint64, len(e.preset))
+	for i, x := range e.preset {
+		e.dict[x] = uint64(i)
+	}
+}
+
+// EncodeMagic writes the Magic header or DictMagic in dictionary mode.
+func (e *Encoder) EncodeMagic() error {
+	magic := Magic
+	if e.dictMode {
+		magic = DictMagic
+	}
+	if _, err := e.w.WriteString(magic); err != nil {
+		return e.setErr(err)
+	}
+	return nil
+}
+
+// Encode writes the Val v and flushes the Encoder.
+//
+// Encode returns ErrInvalidLit if v contains an invalid Lit
+// and ErrNilVal if v contains a nil element.
+// When v cannot be encoded, part of the value may already have been written.
+func (e *Encoder) Encode(v lisp.Val) error {
+	if e.err != nil {
+		return e.err
+	}
+	if v != nil {
+		if err := e.encode(v); err != nil {
+			return e.setErr(err)
+		}
+	}
+	return e.Flush()
+}
+
+func (e *Encoder) encode(root lisp.Val) error {
 	switch root := root.(type) {
+	case nil:
+		return ErrNilVal
 	case lisp.Lit:
-		if e.delim {
-			e.w.WriteByte(' ')
-		}
-		e.w.WriteString(string(root))
-		e.delim = true // Set delim.
+		return e.encodeLit(root)
 	case lisp.Group:
-		e.EncodeGroup(root)
-		e.delim = false // Clear delim.
+		return e.encodeGroup(root)
 	default:
 		panic("Unexpected Val type")
 	}
 }
 
-func (e *Encoder) EncodeGroup(root lisp.Group) {
-	e.w.WriteByte(byte(lisp.LParen))
+func (e *Encoder) writeUvarint(x uint64) {
+	n := binary.PutUvarint(e.buf[:], x)
+	e.w.Write(e.buf[:n])
+}
+
+func (e *Encoder) encodeLit(x lisp.Lit) error {
+	if !lisp.ValidLit(x) {
+		return ErrInvalidLit
+	}
+	if n, ok := xlisp.ParseNat(x); ok {
+		e.w.WriteByte(tagNat)
+		e.writeUvarint(n)
+		return nil
+	}
+	if e.dictMode {
+		if i, ok := e.dict[x]; ok {
+			e.w.WriteByte(tagRef)
+			e.writeUvarint(i)
+			return nil
+		}
+		if len(e.dict) >= e.limit() {
+			e.resetDict()
+			e.w.WriteByte(tagReset)
+		}
+		e.dict[x] = uint64(len(e.dict))
+		e.w.WriteByte(tagDef)
+		e.writeUvarint(uint64(len(x)))
+		e.w.WriteString(string(x))
+		return nil
+	}
+	e.w.WriteByte(tagId)
+	e.writeUvarint(uint64(len(x)))
+	e.w.WriteString(string(x))
+	return nil
+}
+
+// EncodeGroup writes the Group root without flushing the Encoder.
+//
+// EncodeGroup returns the same errors as Encode.
+func (e *Encoder) EncodeGroup(root lisp.Group) error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := e.encodeGroup(root); err != nil {
+		return e.setErr(err)
+	}
+	return nil
+}
+
+func (e *Encoder) encodeGroup(root lisp.Group) error {
+	e.w.WriteByte(tagBegin)
 	for _, x := range root {
-		e.encode(x)
+		if err := e.encode(x); err != nil {
+			return err
+		}
 	}
-	e.w.WriteByte(byte(lisp.RParen))
+	e.w.WriteByte(tagEnd)
+	return nil
+}
+
+// Err returns the first error encountered by the Encoder.
+func (e *Encoder) Err() error { return e.err }
+
+func (e *Encoder) setErr(err error) error {
+	if e.err == nil {
+		e.err = err
+	}
+	return e.err
+}
+
+// BeginGroup opens a new Group incrementally.
+func (e *Encoder) BeginGroup() error {
+	if e.err != nil {
+		return e.err
+	}
+	e.depth++
+	if err := e.w.WriteByte(tagBegin); err != nil {
+		return e.setErr(err)
+	}
+	return nil
+}
+
+// WriteLit writes the Lit x incrementally.
+func (e *Encoder) WriteLit(x lisp.Lit) error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := e.encodeLit(x); err != nil {
+		return e.setErr(err)
+	}
+	return nil
+}
+
+// EndGroup closes the innermost Group opened by BeginGroup.
+func (e *Encoder) EndGroup() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.depth == 0 {
+		return e.setErr(ErrUnbalanced)
+	}
+	e.depth--
+	if err := e.w.WriteByte(tagEnd); err != nil {
+		return e.setErr(err)
+	}
+	return nil
+}
+
+// Flush flushes buffered output to the underlying writer.
+func (e *Encoder) Flush() error {
+	if err := e.w.Flush(); err != nil {
+		return e.setErr(err)
+	}
+	return e.err
+}
+
+// Close flushes the Encoder and reports an error if any Group remains open.
+func (e *Encoder) Close() error {
+	if err := e.Flush(); err != nil {
+		return err
+	}
+	if e.depth != 0 {
+		return e.setErr(ErrUnbalanced)
+	}
+	return nil
 }
 
 type encodeLen struct {
-	n     int
-	delim bool
+	n int
 }
 
-// Len returns the encoded length of the Val in bytes.
+// Len returns the encoded length of the Val in bytes without dictionary mode.
 func Len(v lisp.Val) int {
 	var e encodeLen
 	e.Len(v)
 	return e.n
 }
 
+func uvarintLen(x uint64) int {
+	n := 1
+	for ; x >= 0x80; x >>= 7 {
+		n++
+	}
+	return n
+}
+
 func (e *encodeLen) Len(v lisp.Val) {
 	if v == nil {
 		return
 	}
 	switch v := v.(type) {
 	case lisp.Lit:
-		if e.delim {
-			e.n++ // {delim}
+		if n, ok := xlisp.ParseNat(v); ok {
+			e.n += 1 + uvarintLen(n) // {tag}{uvarint}
+			return
 		}
-		e.n += len(v) // {text}
-		e.delim = true
+		e.n += 1 + uvarintLen(uint64(len(v))) + len(v) // {tag}{len}{text}
 	case lisp.Group:
-		e.GroupLen(v) // ({Val}...{Val})
-		e.delim = false
+		e.GroupLen(v) // {tag}{Val}...{Val}{tag}
 	default:
 		panic("Unexpected Val type")
 	}
@@ -107,9 +326,9 @@ func GroupLen(root lisp.Group) int {
 }
 
 func (e *encodeLen) GroupLen(root lisp.Group) {
-	e.n++ // "("
+	e.n++ // tagBegin
 	for _, x := range root {
 		e.Len(x) // {val}
 	}
-	e.n++ // ")"
+	e.n++ // tagEnd
 }
@@ -39,14 +39,32 @@ func TestEncode(t *testing.T) {
 		name:  "Id",
 		input: "a",
 		want: []byte{
-			'a',
+			tagId, 1, 'a',
 		},
 	}, {
 		name:  "Nat",
 		input: "1",
 		want: []byte{
-			'1',
+			tagNat, 1,
 		},
+	}, {
+		name:  "Nat uses varint",
+		input: "300",
+		want: []byte{
+			tagNat, 0xac, 0x02,
+		},
+	}, {
+		name:  "Nat with leading zeros uses Id",
+		input: "007",
+		want: []byte{
+			tagId, 3, '0', '0', '7',
+		},
+	}, {
+		name:  "Nat overflowing uint64 uses Id",
+		input: "18446744073709551616",
+		want: append([]byte{
+			tagId, 20,
+		}, "18446744073709551616"...),
 	}, {
 		name:  "empty Group",
 		input: "()",
@@ -55,15 +73,13 @@ func TestEncode(t *testing.T) {
 			byte(lisp.RParen),
 		},
 	}, {
-		name:  "Ids use delimiters",
+		name:  "Ids are length prefixed",
 		input: "(a b c)",
 		want: []byte{
 			byte(lisp.LParen),
-			'a',
-			' ',
-			'b',
-			' ',
-			'c',
+			tagId, 1, 'a',
+			tagId, 1, 'b',
+			tagId, 1, 'c',
 			byte(lisp.RParen),
 		},
 	}, {
@@ -71,9 +87,20 @@ func TestEncode(t *testing.T) {
 		input: "(abc)",
 		want: []byte{
 			byte(lisp.LParen),
-			'a',
-			'b',
-			'c',
+			tagId, 3, 'a', 'b', 'c',
+			byte(lisp.RParen),
+		},
+	}, {
+		name:  "nested group",
+		input: "(1(a)())",
+		want: []byte{
+			byte(lisp.LParen),
+			tagNat, 1,
+			byte(lisp.LParen),
+			tagId, 1, 'a',
+			byte(lisp.RParen),
+			byte(lisp.LParen),
+			byte(lisp.RParen),
 			byte(lisp.RParen),
 		},
 	}} {
@@ -93,3 +120,62 @@ func TestEncode(t *testing.T) {
 		})
 	}
 }
+
+func TestEncoderStream(t *testing.T) {
+	var buf bytes.Buffer
+	var e Encoder
+	e.Reset(&buf)
+	e.BeginGroup()
+	e.WriteLit("a")
+	e.WriteLit("b")
+	e.BeginGroup()
+	e.EndGroup()
+	e.EndGroup()
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close(): got err = %v, want nil", err)
+	}
+	var want bytes.Buffer
+	e.Reset(&want)
+	e.Encode(mustParse(t, "(a b())"))
+	if diff := cmp.Diff(want.Bytes(), buf.Bytes()); diff != "" {
+		t.Errorf("EncoderStream(): got diff (-want, +got):\n%v", diff)
+	}
+
+	e.Reset(&buf)
+	e.BeginGroup()
+	if err := e.Close(); err != ErrUnbalanced {
+		t.Errorf("Close(): got err = %v, want %v", err, ErrUnbalanced)
+	}
+	e.Reset(&buf)
+	if err := e.WriteLit("a b"); err != ErrInvalidLit {
+		t.Errorf("WriteLit(%q): got err = %v, want %v", "a b", err, ErrInvalidLit)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input lisp.Val
+		want  error
+	}{
+		{lisp.Lit(""), ErrInvalidLit},
+		{lisp.Lit("a b"), ErrInvalidLit},
+		{lisp.Group{lisp.Lit("a"), lisp.Group{lisp.Lit("(")}}, ErrInvalidLit},
+		{lisp.Group{nil}, ErrNilVal},
+		{lisp.Group{lisp.Lit("a"), lisp.Group{nil}}, ErrNilVal},
+	} {
+		var e Encoder
+		e.Reset(new(bytes.Buffer))
+		if err := e.Encode(tc.input); err != tc.want {
+			t.Errorf("Encode(%#v): got err = %v, want %v", tc.input, err, tc.want)
+		}
+		if err := e.Encode(lisp.Lit("a")); err != tc.want {
+			t.Errorf("Encode(%#v) then Encode(a): got err = %v, want sticky %v", tc.input, err, tc.want)
+		}
+		e.Reset(new(bytes.Buffer))
+		if g, ok := tc.input.(lisp.Group); ok {
+			if err := e.EncodeGroup(g); err != tc.want {
+				t.Errorf("EncodeGroup(%#v): got err = %v, want %v", g, err, tc.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package blisp
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+
+	"github.com/ajzaff/lisp"
+)
+
+// A record stream is a durable container of blisp values:
+//
+//	Magic {version byte} {flags byte} {frame} ...
+//
+// Where each frame is:
+//
+//	{sync [4]byte} {len uint32} {crc uint32} {payload}
+//
+// The length and CRC-32 (Castagnoli) of the payload are little endian.
+// The payload contains one blisp value or, in compressed mode,
+// a block of values compressed with flate.
+// Corrupt frames are skipped by scanning for the next sync marker.
+
+// RecordVersion is the version of the record stream format.
+const RecordVersion = 1
+
+// DefaultBlockSize is the default uncompressed size of compressed blocks.
+const DefaultBlockSize = 64 << 10
+
+// Header flags.
+const (
+	flagCompress = 1 << iota
+)
+
+const (
+	recordHeaderLen = len(Magic) + 2
+	frameHeaderLen  = 12
+	maxFrameLen     = 1 << 28
+)
+
+var frameSync = [4]byte{0xff, 'b', 'l', 0xfe}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrTruncated is reported when a record stream ends with an incomplete frame.
+var ErrTruncated = errors.New("blisp: truncated record stream")
+
+// RecordOptions supplied to the RecordWriter.
+type RecordOptions struct {
+	Compress  bool // Whether to compress blocks of values with flate.
+	BlockSize int  // Uncompressed size at which a compressed block is written (Default uses DefaultBlockSize).
+
+	// Append omits the header when appending to an existing record stream.
+	// The options must match those used to create the stream.
+	Append bool
+}
+
+// RecordWriter writes values to a framed record stream.
+type RecordWriter struct {
+	w    io.Writer
+	opts RecordOptions
+
+	e     Encoder
+	block bytes.Buffer // Pending encoded values.
+	zw    *flate.Writer
+	zbuf  bytes.Buffer
+	frame []byte
+	err   error
+}
+
+// NewRecordWriter returns a RecordWriter writing to w.
+//
+// The header is written unless opts.Append is set.
+func NewRecordWriter(w io.Writer, opts RecordOptions) (*RecordWriter, error) {
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = DefaultBlockSize
+	}
+	rw := &RecordWriter{w: w, opts: opts}
+	rw.e.Reset(&rw.block)
+	if opts.Compress {
+		rw.zw, _ = flate.NewWriter(&rw.zbuf, flate.DefaultCompression)
+	}
+	if !opts.Append {
+		var flags byte
+		if opts.Compress {
+			flags |= flagCompress
+		}
+		if _, err := io.WriteString(w, Magic+string([]byte{RecordVersion, flags})); err != nil {
+			return nil, err
+		}
+	}
+	return rw, nil
+}
+
+// Write writes the value v.
+//
+// In compressed mode v is buffered until the block is full or Flush is called.
+func (rw *RecordWriter) Write(v lisp.Val) error {
+	if rw.err != nil {
+		return rw.err
+	}
+	if err := rw.e.Encode(v); err != nil {
+		rw.err = err
+		return err
+	}
+	if !rw.opts.Compress || rw.block.Len() >= rw.opts.BlockSize {
+		return rw.Flush()
+	}
+	return nil
+}
+
+// Flush writes any buffered values as a frame.
+func (rw *RecordWriter) Flush() error {
+	if rw.err != nil || rw.block.Len() == 0 {
+		return rw.err
+	}
+	payload := rw.block.Bytes()
+	if rw.opts.Compress {
+		rw.zbuf.Reset()
+		rw.zw.Reset(&rw.zbuf)
+		rw.zw.Write(payload)
+		if err := rw.zw.Close(); err != nil {
+			rw.err = err
+			return err
+		}
+		payload = rw.zbuf.Bytes()
+	}
+	// Write the frame in a single call to minimize torn writes.
+	rw.frame = append(rw.frame[:0], frameSync[:]...)
+	rw.frame = binary.LittleEndian.AppendUint32(rw.frame, uint32(len(payload)))
+	rw.frame = binary.LittleEndian.AppendUint32(rw.frame, crc32.Checksum(payload, crcTable))
+	rw.frame = append(rw.frame, payload...)
+	rw.block.Reset()
+	if _, err := rw.w.Write(rw.frame); err != nil {
+		rw.err = err
+	}
+	return rw.err
+}
+
+// Close flushes the RecordWriter.
+//
+// Close does not close the underlying writer.
+func (rw *RecordWriter) Close() error { return rw.Flush() }
+
+// RecordReader reads values from a framed record stream.
+type RecordReader struct {
+	r        io.Reader
+	compress bool
+
+	buf     []byte // Unread input.
+	eof     bool
+	skipped int
+	err     error
+}
+
+// NewRecordReader returns a RecordReader reading from r after checking the header.
+func NewRecordReader(r io.Reader) (*RecordReader, error) {
+	var header [recordHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if string(header[:len(Magic)]) != Magic {
+		return nil, ErrMagic
+	}
+	if v := header[len(Magic)]; v != RecordVersion {
+		return nil, fmt.Errorf("blisp: unsupported record stream version %d", v)
+	}
+	return &RecordReader{
+		r:        r,
+		compress: header[len(Magic)+1]&flagCompress != 0,
+	}, nil
+}
+
+// Skipped returns the number of corrupt frames skipped so far.
+func (rr *RecordReader) Skipped() int { return rr.skipped }
+
+// Err returns the first error encountered by the RecordReader.
+//
+// Err returns ErrTruncated if the stream ends with an incomplete frame.
+func (rr *RecordReader) Err() error { return rr.err }
+
+// fill reads until at least n bytes are buffered or the input is exhausted.
+func (rr *RecordReader) fill(n int) bool {
+	for len(rr.buf) < n && !rr.eof {
+		if cap(rr.buf)-len(rr.buf) < 4096 {
+			rr.buf = append(rr.buf, make([]byte, 4096)...)[:len(rr.buf)]
+		}
+		m, err := rr.r.Read(rr.buf[len(rr.buf):cap(rr.buf)])
+		rr.buf = rr.buf[:len(rr.buf)+m]
+		if err == io.EOF {
+			rr.eof = true
+		} else if err != nil {
+			rr.err = err
+			return false
+		}
+	}
+	return len(rr.buf) >= n
+}
+
+// resync discards input up to the next sync marker after the current position.
+func (rr *RecordReader) resync() bool {
+	rr.skipped++
+	rr.buf = rr.buf[1:]
+	for {
+		if i := bytes.Index(rr.buf, frameSync[:]); i >= 0 {
+			rr.buf = rr.buf[i:]
+			return true
+		}
+		// Keep a possible partial sync marker.
+		if n := len(rr.buf) - len(frameSync) + 1; n > 0 {
+			rr.buf = rr.buf[n:]
+		}
+		if rr.eof || !rr.fill(len(rr.buf)+1) {
+			if rr.err == nil && len(rr.buf) > 0 {
+				rr.err = ErrTruncated
+			}
+			return false
+		}
+	}
+}
+
+// next returns the payload of the next valid frame.
+func (rr *RecordReader) next() ([]byte, bool) {
+	for {
+		if !rr.fill(frameHeaderLen) {
+			if rr.err == nil && len(rr.buf) > 0 {
+				if !bytes.HasPrefix(frameSync[:], rr.buf[:min(len(rr.buf), len(frameSync))]) {
+					if !rr.resync() {
+						return nil, false
+					}
+					continue
+				}
+				rr.err = ErrTruncated
+			}
+			return nil, false
+		}
+		if !bytes.Equal(rr.buf[:4], frameSync[:]) {
+			if !rr.resync() {
+				return nil, false
+			}
+			continue
+		}
+		n := int(binary.LittleEndian.Uint32(rr.buf[4:]))
+		sum := binary.LittleEndian.Uint32(rr.buf[8:])
+		if n > maxFrameLen {
+			if !rr.resync() {
+				return nil, false
+			}
+			continue
+		}
+		if !rr.fill(frameHeaderLen + n) {
+			if rr.err != nil {
+				return nil, false
+			}
+			// The length may be corrupt rather than the frame truncated.
+			if bytes.Contains(rr.buf[1:], frameSync[:]) {
+				rr.resync()
+				continue
+			}
+			rr.err = ErrTruncated
+			return nil, false
+		}
+		payload := rr.buf[frameHeaderLen : frameHeaderLen+n]
+		if crc32.Checksum(payload, crcTable) != sum {
+			if !rr.resync() {
+				return nil, false
+			}
+			continue
+		}
+		rr.buf = rr.buf[frameHeaderLen+n:]
+		return payload, true
+	}
+}
+
+// Values returns an iteration over the values in the record stream.
+//
+// Corrupt frames are skipped and counted by Skipped.
+// Errors are reported by Err.
+func (rr *RecordReader) Values() iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		var d Decoder
+		for rr.err == nil {
+			payload, ok := rr.next()
+			if !ok {
+				return
+			}
+			var src io.Reader = bytes.NewReader(payload)
+			if rr.compress {
+				src = flate.NewReader(src)
+			}
+			d.Reset(src)
+			for v := range d.Values() {
+				if !yield(v) {
+					return
+				}
+			}
+			if err := d.Err(); err != nil {
+				rr.err = err
+			}
+		}
+	}
+}
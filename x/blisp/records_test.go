@@ -0,0 +1,145 @@
+package blisp
+
+import (
+	"bytes"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/x/fuzzutil"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func genValues(n int) []lisp.Val {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 4
+	var vs []lisp.Val
+	for range n {
+		vs = append(vs, g.Next())
+	}
+	return vs
+}
+
+func writeRecords(t *testing.T, vs []lisp.Val, opts RecordOptions) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	rw, err := NewRecordWriter(&buf, opts)
+	if err != nil {
+		t.Fatalf("NewRecordWriter(): got err = %v", err)
+	}
+	for _, v := range vs {
+		if err := rw.Write(v); err != nil {
+			t.Fatalf("Write(): got err = %v", err)
+		}
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close(): got err = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func readRecords(t *testing.T, data []byte) ([]lisp.Val, *RecordReader) {
+	t.Helper()
+	rr, err := NewRecordReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewRecordReader(): got err = %v", err)
+	}
+	return slices.Collect(rr.Values()), rr
+}
+
+func TestRecords(t *testing.T) {
+	want := genValues(50)
+	for _, tc := range []struct {
+		name string
+		opts RecordOptions
+	}{{
+		name: "plain",
+	}, {
+		name: "compressed",
+		opts: RecordOptions{Compress: true, BlockSize: 256},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := writeRecords(t, want, tc.opts)
+			got, rr := readRecords(t, data)
+			if err := rr.Err(); err != nil {
+				t.Fatalf("Values(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("Values(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+			if n := rr.Skipped(); n != 0 {
+				t.Errorf("Skipped(%q): got %d, want 0", tc.name, n)
+			}
+		})
+	}
+}
+
+func TestRecordsAppend(t *testing.T) {
+	want := genValues(10)
+	data := writeRecords(t, want[:5], RecordOptions{})
+	data = append(data, writeRecords(t, want[5:], RecordOptions{Append: true})...)
+	got, rr := readRecords(t, data)
+	if err := rr.Err(); err != nil {
+		t.Fatalf("Values(): got err = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Values(): got diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestRecordsTruncated(t *testing.T) {
+	want := []lisp.Val{lisp.Lit("a"), lisp.Group{lisp.Lit("b"), lisp.Lit("c")}, lisp.Lit("d")}
+	data := writeRecords(t, want, RecordOptions{})
+	for n := 1; n < frameHeaderLen+Len(want[2]); n++ {
+		got, rr := readRecords(t, data[:len(data)-n])
+		if err := rr.Err(); err != ErrTruncated {
+			t.Errorf("Values(truncated %d): got err = %v, want %v", n, err, ErrTruncated)
+		}
+		if diff := cmp.Diff(want[:2], got); diff != "" {
+			t.Errorf("Values(truncated %d): got diff (-want, +got):\n%v", n, diff)
+		}
+	}
+}
+
+func TestRecordsCorrupt(t *testing.T) {
+	want := []lisp.Val{lisp.Lit("a"), lisp.Group{lisp.Lit("b"), lisp.Lit("c")}, lisp.Lit("d")}
+	frame := frameHeaderLen + Len(want[0])
+	for _, tc := range []struct {
+		name string
+		off  int // Offset of the corrupted byte relative to the second frame.
+	}{
+		{"sync", 0},
+		{"length", 5},
+		{"crc", 9},
+		{"payload", frameHeaderLen + 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := writeRecords(t, want, RecordOptions{})
+			data[recordHeaderLen+frame+tc.off] ^= 0x5a
+			got, rr := readRecords(t, data)
+			if err := rr.Err(); err != nil {
+				t.Fatalf("Values(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff([]lisp.Val{want[0], want[2]}, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Values(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+			if n := rr.Skipped(); n == 0 {
+				t.Errorf("Skipped(%q): got 0, want > 0", tc.name)
+			}
+		})
+	}
+}
+
+func TestNewRecordReaderBadHeader(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"blisp0\n\x01\x00",
+		Magic + "\x02\x00",
+	} {
+		if _, err := NewRecordReader(bytes.NewReader([]byte(input))); err == nil {
+			t.Errorf("NewRecordReader(%q): got err = nil, want err", input)
+		}
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/ajzaff/lisp/x/blisp"
+	"github.com/ajzaff/lisp/x/dag"
 	"github.com/ajzaff/lisp/x/fuzzutil"
 	"github.com/ajzaff/lisp/x/print"
 )
@@ -93,3 +95,74 @@ func BenchmarkCompressZLib(b *testing.B) {
 	defer w.Close()
 	benchmarkCompress(b, w)
 }
+
+func benchmarkBlisp(b *testing.B, dict bool) {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 10
+	g.GroupWeight = 5
+
+	printBuf := bytes.NewBuffer(make([]byte, 0, 256))
+	compressBuf := bytes.NewBuffer(make([]byte, 0, 256))
+	var e blisp.Encoder
+	e.Reset(compressBuf)
+	if dict {
+		e.SetDict(nil)
+	}
+	for i := 0; i < b.N; i++ {
+		printBuf.Reset()
+		compressBuf.Reset()
+
+		v := g.Next()
+
+		print.StdPrinter(printBuf).Print(v)
+		origLen := printBuf.Len()
+
+		if err := e.Encode(v); err != nil {
+			b.Error(err)
+		}
+
+		if compressLen := compressBuf.Len(); compressLen > 0 {
+			compressRatio := float64(origLen) / float64(compressLen) / float64(b.N)
+			b.ReportMetric(compressRatio, "CompressRatio/op")
+		}
+	}
+}
+
+func BenchmarkCompressBlisp(b *testing.B) {
+	benchmarkBlisp(b, false)
+}
+
+// BenchmarkCompressBlispDict keeps the dictionary across values.
+func BenchmarkCompressBlispDict(b *testing.B) {
+	benchmarkBlisp(b, true)
+}
+
+// BenchmarkCompressDAG keeps the node table across values.
+func BenchmarkCompressDAG(b *testing.B) {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 10
+	g.GroupWeight = 5
+
+	printBuf := bytes.NewBuffer(make([]byte, 0, 256))
+	compressBuf := bytes.NewBuffer(make([]byte, 0, 256))
+	e := dag.NewEncoder(compressBuf, dag.Binary)
+	for i := 0; i < b.N; i++ {
+		printBuf.Reset()
+		compressBuf.Reset()
+
+		v := g.Next()
+
+		print.StdPrinter(printBuf).Print(v)
+		origLen := printBuf.Len()
+
+		if err := e.Encode(v); err != nil {
+			b.Error(err)
+		}
+		e.Flush()
+
+		if compressLen := compressBuf.Len(); compressLen > 0 {
+			compressRatio := float64(origLen) / float64(compressLen) / float64(b.N)
+			b.ReportMetric(compressRatio, "CompressRatio/op")
+		}
+	}
+}
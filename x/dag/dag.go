@@ -0,0 +1,410 @@
+// Package dag implements structure-sharing serialization of Lisp values.
+//
+// Each distinct subtree is written once as a numbered node and repeats
+// are written as references to the node index. Nodes are numbered in
+// order of definition starting from 0 and are shared across all values
+// in a stream.
+//
+// The Text format is a Lisp stream of records:
+//
+//	(l x)       Node for the Lit x.
+//	(g i j ...) Node for the Group of nodes i, j, ...
+//	(r i)       Top-level value of node i.
+//
+// The Binary format begins with Magic followed by tagged records:
+//
+//	'l' {uvarint len} {text}
+//	'g' {uvarint n} {uvarint delta} ...
+//	'r' {uvarint delta}
+//
+// Where delta is the number of nodes defined since node i plus one.
+package dag
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/ajzaff/lisp/x/print"
+)
+
+const Magic = "lispdag1\n"
+
+// Format of the encoded stream.
+type Format int
+
+const (
+	Text Format = iota
+	Binary
+)
+
+// Record tags.
+const (
+	litRecord   byte = 'l'
+	groupRecord byte = 'g'
+	rootRecord  byte = 'r'
+)
+
+// Record heads for Text.
+const (
+	litHead   lisp.Lit = "l"
+	groupHead lisp.Lit = "g"
+	rootHead  lisp.Lit = "r"
+)
+
+// maxLitLen bounds the length of Lits accepted by the Decoder.
+const maxLitLen = 1 << 28
+
+// Encoder writes Lisp values in DAG form.
+type Encoder struct {
+	f   Format
+	w   *bufio.Writer
+	pw  *print.Writer // Used for Text.
+	buf [binary.MaxVarintLen64]byte
+
+	ids   map[string]uint64 // Node keys to node indices.
+	n     uint64            // Number of nodes defined.
+	key   []byte
+	magic bool
+	err   error
+}
+
+// NewEncoder returns an Encoder writing the Format f to w.
+func NewEncoder(w io.Writer, f Format) *Encoder {
+	e := &Encoder{
+		f:   f,
+		w:   bufio.NewWriter(w),
+		ids: make(map[string]uint64),
+	}
+	if f == Text {
+		e.pw = print.NewWriter(e.w)
+	}
+	return e
+}
+
+// Encode writes the value v.
+//
+// Output is buffered until Flush is called.
+func (e *Encoder) Encode(v lisp.Val) error {
+	if e.err != nil || v == nil {
+		return e.err
+	}
+	if e.f == Binary && !e.magic {
+		e.magic = true
+		e.w.WriteString(Magic)
+	}
+	id, err := e.intern(v)
+	if err != nil {
+		e.setErr(err)
+		return e.err
+	}
+	if e.f == Text {
+		e.writeText(rootHead, lisp.Lit(strconv.FormatUint(id, 10)))
+	} else {
+		e.w.WriteByte(rootRecord)
+		e.writeUvarint(e.n - id)
+	}
+	return e.err
+}
+
+// intern returns the node index of v writing any new nodes.
+func (e *Encoder) intern(v lisp.Val) (uint64, error) {
+	var key string
+	switch v := v.(type) {
+	case nil:
+		return 0, errors.New("dag: unexpected nil Val")
+	case lisp.Lit:
+		if !lisp.ValidLit(v) {
+			return 0, fmt.Errorf("dag: invalid Lit %q", string(v))
+		}
+		key = string(litHead) + string(v)
+		if id, ok := e.ids[key]; ok {
+			return id, nil
+		}
+		if e.f == Text {
+			e.writeText(litHead, v)
+		} else {
+			e.w.WriteByte(litRecord)
+			e.writeUvarint(uint64(len(v)))
+			e.w.WriteString(string(v))
+		}
+	case lisp.Group:
+		children := make([]uint64, len(v))
+		for i, x := range v {
+			id, err := e.intern(x)
+			if err != nil {
+				return 0, err
+			}
+			children[i] = id
+		}
+		e.key = append(e.key[:0], groupRecord)
+		for _, id := range children {
+			e.key = binary.AppendUvarint(e.key, id)
+		}
+		if id, ok := e.ids[string(e.key)]; ok {
+			return id, nil
+		}
+		key = string(e.key)
+		if e.f == Text {
+			e.pw.BeginGroup()
+			e.pw.WriteLit(groupHead)
+			for _, id := range children {
+				e.pw.WriteLit(lisp.Lit(strconv.FormatUint(id, 10)))
+			}
+			e.setErr(e.pw.EndGroup())
+		} else {
+			e.w.WriteByte(groupRecord)
+			e.writeUvarint(uint64(len(children)))
+			for _, id := range children {
+				e.writeUvarint(e.n - id)
+			}
+		}
+	default:
+		panic("Unexpected Val type")
+	}
+	id := e.n
+	e.ids[key] = id
+	e.n++
+	return id, nil
+}
+
+func (e *Encoder) writeText(head, x lisp.Lit) {
+	e.pw.BeginGroup()
+	e.pw.WriteLit(head)
+	e.pw.WriteLit(x)
+	e.setErr(e.pw.EndGroup())
+}
+
+func (e *Encoder) writeUvarint(x uint64) {
+	n := binary.PutUvarint(e.buf[:], x)
+	e.w.Write(e.buf[:n])
+}
+
+func (e *Encoder) setErr(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// Flush writes buffered output to the underlying writer.
+func (e *Encoder) Flush() error {
+	if e.pw != nil {
+		e.setErr(e.pw.Flush())
+	}
+	e.setErr(e.w.Flush())
+	return e.err
+}
+
+// Decoder reads Lisp values in DAG form.
+//
+// Decoded values share structure and should not be modified.
+type Decoder struct {
+	f     Format
+	r     *bufio.Reader
+	nodes []lisp.Val
+	magic bool
+	err   error
+}
+
+// NewDecoder returns a Decoder reading the Format f from r.
+func NewDecoder(r io.Reader, f Format) *Decoder {
+	return &Decoder{f: f, r: bufio.NewReader(r)}
+}
+
+// Err returns the first error encountered by the Decoder.
+func (d *Decoder) Err() error { return d.err }
+
+func (d *Decoder) node(i uint64) (lisp.Val, error) {
+	if i >= uint64(len(d.nodes)) {
+		return nil, fmt.Errorf("dag: node reference %d out of range [0, %d)", i, len(d.nodes))
+	}
+	return d.nodes[i], nil
+}
+
+// Values returns an iteration over the decoded values.
+//
+// Errors are reported by Err.
+func (d *Decoder) Values() iter.Seq[lisp.Val] {
+	if d.f == Text {
+		return d.textValues
+	}
+	return d.binaryValues
+}
+
+func (d *Decoder) textValues(yield func(lisp.Val) bool) {
+	var sc scan.Scanner
+	sc.Reset(d.r)
+	for n := range sc.Nodes() {
+		v, root, err := d.textRecord(n.Val)
+		if err != nil {
+			d.err = fmt.Errorf("dag: bad record at offset %d: %w", n.Pos, err)
+			return
+		}
+		if root && !yield(v) {
+			return
+		}
+	}
+	if err := sc.Err(); err != nil {
+		d.err = err
+	}
+}
+
+func (d *Decoder) textRef(x lisp.Val) (lisp.Val, error) {
+	lit, ok := x.(lisp.Lit)
+	if !ok {
+		return nil, errors.New("expected node index")
+	}
+	i, err := strconv.ParseUint(string(lit), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return d.node(i)
+}
+
+// textRecord decodes a record and reports whether it is a root.
+func (d *Decoder) textRecord(v lisp.Val) (lisp.Val, bool, error) {
+	g, ok := v.(lisp.Group)
+	if !ok || len(g) == 0 {
+		return nil, false, errors.New("expected record Group")
+	}
+	switch g[0] {
+	case litHead:
+		if len(g) != 2 {
+			return nil, false, errors.New("expected (l x)")
+		}
+		x, ok := g[1].(lisp.Lit)
+		if !ok || !lisp.ValidLit(x) {
+			return nil, false, errors.New("expected (l x)")
+		}
+		d.nodes = append(d.nodes, x)
+		return x, false, nil
+	case groupHead:
+		x := make(lisp.Group, 0, len(g)-1)
+		for _, e := range g[1:] {
+			v, err := d.textRef(e)
+			if err != nil {
+				return nil, false, err
+			}
+			x = append(x, v)
+		}
+		d.nodes = append(d.nodes, x)
+		return x, false, nil
+	case rootHead:
+		if len(g) != 2 {
+			return nil, false, errors.New("expected (r i)")
+		}
+		x, err := d.textRef(g[1])
+		return x, true, err
+	default:
+		return nil, false, fmt.Errorf("unknown record %v", g[0])
+	}
+}
+
+func (d *Decoder) binaryValues(yield func(lisp.Val) bool) {
+	if d.err != nil {
+		return
+	}
+	if !d.magic {
+		d.magic = true
+		var magic [len(Magic)]byte
+		if _, err := io.ReadFull(d.r, magic[:]); err != nil {
+			if err != io.EOF {
+				d.err = err
+			}
+			return
+		}
+		if string(magic[:]) != Magic {
+			d.err = errors.New("dag: bad magic")
+			return
+		}
+	}
+	for {
+		v, root, err := d.binaryRecord()
+		if err != nil {
+			if err != io.EOF {
+				d.err = err
+			}
+			return
+		}
+		if root && !yield(v) {
+			return
+		}
+	}
+}
+
+func (d *Decoder) readUvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(d.r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return x, err
+}
+
+func (d *Decoder) binaryRef() (lisp.Val, error) {
+	delta, err := d.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if delta == 0 || delta > uint64(len(d.nodes)) {
+		return nil, fmt.Errorf("dag: node reference delta %d out of range", delta)
+	}
+	return d.nodes[uint64(len(d.nodes))-delta], nil
+}
+
+// binaryRecord decodes a record and reports whether it is a root.
+func (d *Decoder) binaryRecord() (lisp.Val, bool, error) {
+	tag, err := d.r.ReadByte()
+	if err != nil {
+		return nil, false, err
+	}
+	switch tag {
+	case litRecord:
+		n, err := d.readUvarint()
+		if err != nil {
+			return nil, false, err
+		}
+		if n > maxLitLen {
+			return nil, false, fmt.Errorf("dag: Lit length %d too large", n)
+		}
+		// Read incrementally rather than trust n for the allocation.
+		buf, err := io.ReadAll(io.LimitReader(d.r, int64(n)))
+		if err != nil {
+			return nil, false, err
+		}
+		if uint64(len(buf)) < n {
+			return nil, false, io.ErrUnexpectedEOF
+		}
+		x := lisp.Lit(buf)
+		if !lisp.ValidLit(x) {
+			return nil, false, fmt.Errorf("dag: invalid Lit %q", buf)
+		}
+		d.nodes = append(d.nodes, x)
+		return x, false, nil
+	case groupRecord:
+		n, err := d.readUvarint()
+		if err != nil {
+			return nil, false, err
+		}
+		x := make(lisp.Group, 0, min(n, 1024))
+		for range n {
+			v, err := d.binaryRef()
+			if err != nil {
+				return nil, false, err
+			}
+			x = append(x, v)
+		}
+		d.nodes = append(d.nodes, x)
+		return x, false, nil
+	case rootRecord:
+		x, err := d.binaryRef()
+		return x, true, err
+	default:
+		return nil, false, fmt.Errorf("dag: unknown record tag %#x", tag)
+	}
+}
@@ -0,0 +1,146 @@
+package dag
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/ajzaff/lisp/x/fuzzutil"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParseMultiple(t *testing.T, src string) []lisp.Val {
+	t.Helper()
+	var vs []lisp.Val
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		vs = append(vs, n.Val)
+	}
+	if err := sc.Err(); err != nil {
+		panic(fmt.Sprintf("mustParse: failed to parse: %q: %v", src, err))
+	}
+	return vs
+}
+
+func TestEncodeText(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name:  "Lit",
+		input: "a",
+		want:  "(l a)\n(r 0)\n",
+	}, {
+		name:  "repeated subtree",
+		input: "(a (b c) (b c))",
+		want:  "(l a)\n(l b)\n(l c)\n(g 1 2)\n(g 0 3 3)\n(r 4)\n",
+	}, {
+		name:  "nodes are shared across values",
+		input: "(a) (a) a",
+		want:  "(l a)\n(g 0)\n(r 1)\n(r 1)\n(r 0)\n",
+	}, {
+		name:  "empty Group",
+		input: "()",
+		want:  "(g)\n(r 0)\n",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			e := NewEncoder(&sb, Text)
+			for _, v := range mustParseMultiple(t, tc.input) {
+				if err := e.Encode(v); err != nil {
+					t.Fatalf("Encode(%q): got err = %v", tc.name, err)
+				}
+			}
+			if err := e.Flush(); err != nil {
+				t.Fatalf("Flush(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(tc.want, sb.String()); diff != "" {
+				t.Errorf("Encode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 5
+	want := mustParseMultiple(t, "(a (b c) (b c)) () (()) a 123")
+	for range 100 {
+		want = append(want, g.Next())
+	}
+	for _, f := range []Format{Text, Binary} {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf, f)
+		for _, v := range want {
+			if err := e.Encode(v); err != nil {
+				t.Fatalf("Encode(%v): got err = %v", f, err)
+			}
+		}
+		if err := e.Flush(); err != nil {
+			t.Fatalf("Flush(%v): got err = %v", f, err)
+		}
+		d := NewDecoder(&buf, f)
+		got := slices.Collect(d.Values())
+		if err := d.Err(); err != nil {
+			t.Fatalf("Values(%v): got err = %v", f, err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Values(%v): got diff (-want, +got):\n%v", f, diff)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		f     Format
+		input string
+	}{
+		{"text bad reference", Text, "(l a)(r 1)"},
+		{"text bad Group reference", Text, "(g 0)"},
+		{"text unknown record", Text, "(x a)"},
+		{"text Lit record", Text, "a"},
+		{"binary bad magic", Binary, "lispdag0\n"},
+		{"binary bad reference", Binary, Magic + "l\x01ar\x02"},
+		{"binary truncated", Binary, Magic + "l\x03a"},
+		{"binary unknown tag", Binary, Magic + "x"},
+		{"binary empty Lit", Binary, Magic + "l\x00r\x01"},
+		{"binary invalid Lit", Binary, Magic + "l\x03a br\x01"},
+		{"binary invalid UTF-8 Lit", Binary, Magic + "l\x01\xffr\x01"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tc.input), tc.f)
+			for range d.Values() {
+			}
+			if d.Err() == nil {
+				t.Errorf("Values(%q): got err = nil, want err", tc.name)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, f := range []Format{Text, Binary} {
+		for _, v := range []lisp.Val{
+			lisp.Lit(""),
+			lisp.Lit("a b"),
+			lisp.Group{lisp.Lit("a"), lisp.Lit("(")},
+			lisp.Group{nil},
+			lisp.Group{lisp.Lit("a"), lisp.Group{nil}},
+		} {
+			e := NewEncoder(new(bytes.Buffer), f)
+			if err := e.Encode(v); err == nil {
+				t.Errorf("Encode(%#v): got err = nil, want err", v)
+			}
+		}
+	}
+}
@@ -0,0 +1,389 @@
+// Package extsort implements external merge sorting of Lisp values using bounded memory.
+//
+// Values are sorted in memory until the memory budget is reached,
+// at which point the sorted run is spilled to a temporary file.
+// The runs are merged when reading the output
+// in multiple passes when there are more runs than the merge fan-in.
+package extsort
+
+import (
+	"container/heap"
+	"errors"
+	"io"
+	"iter"
+	"os"
+	"slices"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+	"github.com/ajzaff/lisp/x/print"
+)
+
+// DefaultMaxBytes is the default memory budget for a Sorter.
+const DefaultMaxBytes = 64 << 20
+
+// DefaultFanIn is the default maximum number of runs merged at once.
+const DefaultFanIn = 64
+
+// Options supplied to the Sorter.
+type Options struct {
+	// Compare compares the keys of two values.
+	// The default uses xlisp.Compare.
+	Compare func(a, b lisp.Val) int
+
+	// Key is an optional path of Group indices selecting the sort key of each value.
+	// Values without an element at the path have a nil key which sorts first.
+	Key []int
+
+	Dedup bool // Whether to drop values with keys equal to the previous value.
+	Count bool // Whether to output (n v) where n counts values with equal keys. Implies Dedup.
+
+	MaxBytes int    // Approximate memory budget for sorting in memory (Default uses DefaultMaxBytes).
+	TempDir  string // Directory used for spill files (Default uses os.TempDir).
+
+	// FanIn is the maximum number of spill files open at once while merging.
+	// Runs beyond the FanIn are merged in earlier passes (Default uses DefaultFanIn).
+	FanIn int
+}
+
+// Sorter sorts values added to it.
+//
+// Output order is stable with respect to the order values were added.
+type Sorter struct {
+	Options
+
+	buf  []lisp.Val
+	size int
+	runs []string
+	err  error
+}
+
+// NewSorter returns a Sorter with the given options.
+func NewSorter(opts Options) *Sorter {
+	if opts.Compare == nil {
+		opts.Compare = xlisp.Compare
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.FanIn < 2 {
+		opts.FanIn = DefaultFanIn
+	}
+	return &Sorter{Options: opts}
+}
+
+// Key returns the key of v at path or nil.
+func Key(v lisp.Val, path []int) lisp.Val {
+	for _, i := range path {
+		g, ok := v.(lisp.Group)
+		if !ok || i < 0 || i >= len(g) {
+			return nil
+		}
+		v = g[i]
+	}
+	return v
+}
+
+func (s *Sorter) compare(a, b lisp.Val) int {
+	a, b = Key(a, s.Key), Key(b, s.Key)
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	default:
+		return s.Compare(a, b)
+	}
+}
+
+// sizeOf estimates the memory used by v in bytes.
+func sizeOf(v lisp.Val) int {
+	switch v := v.(type) {
+	case lisp.Lit:
+		return 16 + len(v)
+	case lisp.Group:
+		n := 24
+		for _, e := range v {
+			n += 16 + sizeOf(e)
+		}
+		return n
+	default:
+		return 16
+	}
+}
+
+// Add adds the value v to the Sorter, spilling a sorted run when the memory budget is reached.
+func (s *Sorter) Add(v lisp.Val) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.buf = append(s.buf, v)
+	if s.size += sizeOf(v); s.size >= s.MaxBytes {
+		return s.spill()
+	}
+	return nil
+}
+
+func (s *Sorter) sortBuf() {
+	slices.SortStableFunc(s.buf, s.compare)
+}
+
+func (s *Sorter) spill() error {
+	s.sortBuf()
+	name, err := s.writeRun(slices.Values(s.buf))
+	if name != "" {
+		s.runs = append(s.runs, name)
+	}
+	s.setErr(err)
+	clear(s.buf)
+	s.buf = s.buf[:0]
+	s.size = 0
+	return s.err
+}
+
+// writeRun writes the sorted values of seq to a new spill file and returns its name.
+func (s *Sorter) writeRun(seq iter.Seq[lisp.Val]) (string, error) {
+	f, err := os.CreateTemp(s.TempDir, "extsort")
+	if err != nil {
+		return "", err
+	}
+	w := print.NewWriter(f)
+	for v := range seq {
+		if err = w.WriteVal(v); err != nil {
+			break
+		}
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return f.Name(), err
+	}
+	return f.Name(), f.Close()
+}
+
+// compact merges spill files in passes until at most FanIn remain.
+// Consecutive runs are merged to keep the output stable.
+func (s *Sorter) compact() error {
+	for len(s.runs) > s.FanIn {
+		var runs []string
+		for i := 0; i < len(s.runs); i += s.FanIn {
+			batch := s.runs[i:min(i+s.FanIn, len(s.runs))]
+			if len(batch) == 1 {
+				runs = append(runs, batch[0])
+				continue
+			}
+			name, err := s.writeRun(s.mergeRuns(batch, nil))
+			if name != "" {
+				runs = append(runs, name)
+			}
+			if err == nil {
+				err = s.err
+			}
+			if err != nil {
+				// Keep the remaining files so Close removes them.
+				s.runs = append(runs, s.runs[i:]...)
+				s.setErr(err)
+				return err
+			}
+			for _, name := range batch {
+				os.Remove(name)
+			}
+		}
+		s.runs = runs
+	}
+	return nil
+}
+
+// Values returns an iteration over the sorted values.
+//
+// Values may only be called once after all values are added.
+// Errors are reported by Err.
+func (s *Sorter) Values() iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		if s.err != nil {
+			return
+		}
+		s.sortBuf()
+		if s.compact() != nil {
+			return
+		}
+		s.dedup(s.mergeRuns(s.runs, s.buf), yield)
+	}
+}
+
+func (s *Sorter) dedup(seq iter.Seq[lisp.Val], yield func(lisp.Val) bool) {
+	if !s.Dedup && !s.Count {
+		for v := range seq {
+			if !yield(v) {
+				return
+			}
+		}
+		return
+	}
+	var (
+		prev lisp.Val
+		n    uint64
+	)
+	emit := func() bool {
+		if s.Count {
+			return yield(lisp.Group{xlisp.Nat(n), prev})
+		}
+		return yield(prev)
+	}
+	for v := range seq {
+		if n > 0 && s.compare(prev, v) == 0 {
+			n++
+			continue
+		}
+		if n > 0 && !emit() {
+			return
+		}
+		prev, n = v, 1
+	}
+	if n > 0 {
+		emit()
+	}
+}
+
+// run is a cursor over a sorted run.
+type run struct {
+	next func() (lisp.Val, bool)
+	stop func()
+	val  lisp.Val
+	i    int // Index of the run for stable merging.
+}
+
+type runHeap struct {
+	runs []*run
+	s    *Sorter
+}
+
+func (h *runHeap) Len() int { return len(h.runs) }
+func (h *runHeap) Less(i, j int) bool {
+	if cmp := h.s.compare(h.runs[i].val, h.runs[j].val); cmp != 0 {
+		return cmp < 0
+	}
+	return h.runs[i].i < h.runs[j].i
+}
+func (h *runHeap) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+func (h *runHeap) Push(x any)    { h.runs = append(h.runs, x.(*run)) }
+func (h *runHeap) Pop() any {
+	n := len(h.runs) - 1
+	x := h.runs[n]
+	h.runs = h.runs[:n]
+	return x
+}
+
+// mergeRuns merges the spill files names followed by the sorted values buf.
+func (s *Sorter) mergeRuns(names []string, buf []lisp.Val) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		h := &runHeap{s: s}
+		defer func() {
+			for _, r := range h.runs {
+				r.stop()
+			}
+		}()
+		for i, name := range names {
+			next, stop := iter.Pull(s.readRun(name))
+			r := &run{next: next, stop: stop, i: i}
+			var ok bool
+			if r.val, ok = next(); !ok {
+				stop()
+				continue
+			}
+			h.runs = append(h.runs, r)
+		}
+		if len(buf) > 0 {
+			next, stop := iter.Pull(slices.Values(buf))
+			r := &run{next: next, stop: stop, i: len(names)}
+			r.val, _ = next()
+			h.runs = append(h.runs, r)
+		}
+		heap.Init(h)
+		for h.Len() > 0 && s.err == nil {
+			r := h.runs[0]
+			if !yield(r.val) {
+				return
+			}
+			if v, ok := r.next(); ok {
+				r.val = v
+				heap.Fix(h, 0)
+				continue
+			}
+			r.stop()
+			heap.Pop(h)
+		}
+	}
+}
+
+func (s *Sorter) readRun(name string) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		f, err := os.Open(name)
+		if err != nil {
+			s.setErr(err)
+			return
+		}
+		defer f.Close()
+		var sc scan.Scanner
+		sc.Reset(f)
+		for n := range sc.Nodes() {
+			if !yield(n.Val) {
+				return
+			}
+		}
+		s.setErr(sc.Err())
+	}
+}
+
+func (s *Sorter) setErr(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Err returns the first error encountered by the Sorter.
+func (s *Sorter) Err() error { return s.err }
+
+// Close removes any spill files created by the Sorter.
+func (s *Sorter) Close() error {
+	var errs []error
+	for _, name := range s.runs {
+		if err := os.Remove(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.runs = nil
+	s.buf = nil
+	return errors.Join(errs...)
+}
+
+// Sort reads Lisp text from r and writes the sorted values to w.
+func Sort(w io.Writer, r io.Reader, opts Options) error {
+	s := NewSorter(opts)
+	defer s.Close()
+	var sc scan.Scanner
+	sc.Reset(r)
+	for n := range sc.Nodes() {
+		if err := s.Add(n.Val); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	pw := print.NewWriter(w)
+	for v := range s.Values() {
+		if err := pw.WriteVal(v); err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if err := pw.Close(); err != nil {
+		return err
+	}
+	return s.Close()
+}
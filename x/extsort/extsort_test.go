@@ -0,0 +1,128 @@
+package extsort
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSort(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name:  "lits",
+		input: "c a b",
+		want:  "a\nb\nc\n",
+	}, {
+		name:  "lits before groups",
+		input: "(a) b () a",
+		want:  "a\nb\n()\n(a)\n",
+	}, {
+		name:  "dedup",
+		input: "b a b (c) a (c)",
+		opts:  Options{Dedup: true},
+		want:  "a\nb\n(c)\n",
+	}, {
+		name:  "count",
+		input: "b a b (c) a a",
+		opts:  Options{Count: true},
+		want:  "(3 a)\n(2 b)\n(1(c))\n",
+	}, {
+		name:  "key is stable",
+		input: "(x 2) (y 1) (z 2) (w)",
+		opts:  Options{Key: []int{1}},
+		want:  "(w)\n(y 1)\n(x 2)\n(z 2)\n",
+	}, {
+		name:  "lexical",
+		input: "(b) a ((c))",
+		opts:  Options{Compare: xlisp.LexicalCompare},
+		want:  "a\n(b)\n((c))\n",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, maxBytes := range []int{0, 1, 64} {
+				opts := tc.opts
+				opts.MaxBytes = maxBytes
+				opts.TempDir = t.TempDir()
+				var sb strings.Builder
+				if err := Sort(&sb, strings.NewReader(tc.input), opts); err != nil {
+					t.Fatalf("Sort(%q): got err = %v", tc.name, err)
+				}
+				if diff := cmp.Diff(tc.want, sb.String()); diff != "" {
+					t.Errorf("Sort(%q, MaxBytes=%d): got diff (-want, +got):\n%v", tc.name, maxBytes, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestSorterRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	var want []lisp.Val
+	s := NewSorter(Options{MaxBytes: 1 << 10, TempDir: t.TempDir()})
+	defer s.Close()
+	for range 5000 {
+		v := lisp.Group{xlisp.Nat(uint64(r.Intn(100))), lisp.Lit(fmt.Sprint("v", r.Intn(10)))}
+		want = append(want, v)
+		if err := s.Add(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.runs) < 2 {
+		t.Fatalf("Sorter: got %d runs, want multiple spilled runs", len(s.runs))
+	}
+	slices.SortStableFunc(want, xlisp.Compare)
+	got := slices.Collect(s.Values())
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Sorter: got diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestSorterFanIn(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	var want []lisp.Val
+	dir := t.TempDir()
+	s := NewSorter(Options{MaxBytes: 1 << 8, TempDir: dir, FanIn: 3})
+	defer s.Close()
+	for range 2000 {
+		v := lisp.Group{xlisp.Nat(uint64(r.Intn(100))), lisp.Lit(fmt.Sprint("v", r.Intn(10)))}
+		want = append(want, v)
+		if err := s.Add(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.runs) <= s.FanIn {
+		t.Fatalf("Sorter: got %d runs, want more than FanIn=%d", len(s.runs), s.FanIn)
+	}
+	slices.SortStableFunc(want, xlisp.Compare)
+	got := slices.Collect(s.Values())
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.runs) > s.FanIn {
+		t.Errorf("Sorter: got %d runs after merging, want at most FanIn=%d", len(s.runs), s.FanIn)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Sorter: got diff (-want, +got):\n%v", diff)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Sorter: got %d spill files after Close, want 0", len(entries))
+	}
+}
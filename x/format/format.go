@@ -1,5 +1,15 @@
 package format
 
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/ajzaff/lisp/x/print"
+)
+
 // Source formats src Lisp code.
 //
 // Source will preserve one space between Id and Nat tokens but will not add them if not present.
@@ -25,3 +35,146 @@ func Source(src []byte) []byte {
 	}
 	return src[:i]
 }
+
+// Options for Format.
+type Options struct {
+	// Width is the target line width passed to the pretty printer.
+	// Zero prints each top-level expression on one line.
+	Width int
+
+	// Rules are layout rules passed to the pretty printer.
+	Rules map[lisp.Lit]print.LayoutRule
+
+	// Comments enables line comments beginning with ';'.
+	// Comments between top-level expressions are kept on their own line or after the expression they follow.
+	// A top-level Group containing comments is kept as is.
+	Comments bool
+}
+
+// item is a top-level expression or comment in the source.
+type item struct {
+	pos, end int
+	val      lisp.Val // Nil for comments and raw Groups.
+	text     string   // Comment or raw Group text.
+	raw      bool     // Whether text is a Group with comments.
+}
+
+// Format formats src Lisp code in the canonical style.
+//
+// Each top-level expression is pretty printed on its own lines and
+// a single blank line is kept where the source has one or more blank lines
+// between top-level expressions. Format returns an error if src cannot be parsed.
+//
+// With Comments set, a top-level Group containing comments is not reformatted.
+func Format(src []byte, opts Options) ([]byte, error) {
+	var items []item
+	if opts.Comments {
+		var err error
+		if src, items, err = stripComments(src); err != nil {
+			return nil, err
+		}
+	}
+	var sc scan.Scanner
+	sc.Reset(bytes.NewReader(src))
+	end := 0
+	for n := range sc.Nodes() {
+		items = append(items, item{pos: int(n.Pos), end: int(n.End), val: n.Val})
+		end = int(n.End)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(src[end:])) > 0 {
+		return nil, fmt.Errorf("unexpected EOF: unclosed Group")
+	}
+	slices.SortFunc(items, func(a, b item) int { return a.pos - b.pos })
+
+	var buf bytes.Buffer
+	p := print.StdPrinter(&buf)
+	p.Width = opts.Width
+	p.Rules = opts.Rules
+	for i, it := range items {
+		if i > 0 {
+			gap := src[items[i-1].end:it.pos]
+			switch newlines := bytes.Count(gap, []byte{'\n'}); {
+			case newlines == 0 && it.val == nil && !it.raw:
+				// Keep a trailing comment on the line.
+				buf.Truncate(buf.Len() - 1)
+				buf.WriteByte(' ')
+			case newlines > 1:
+				buf.WriteByte('\n')
+			}
+		}
+		if it.val == nil {
+			buf.WriteString(it.text)
+			buf.WriteByte('\n')
+			continue
+		}
+		p.Print(it.val)
+	}
+	return buf.Bytes(), nil
+}
+
+// stripComments returns a copy of src with comments and top-level Groups containing comments
+// replaced by spaces and the removed text as items.
+func stripComments(src []byte) ([]byte, []item, error) {
+	orig := src
+	src = bytes.Clone(src)
+	var items []item
+	depth := 0
+	start, inner := 0, false // Start of the top-level Group and whether it has comments.
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '(':
+			if depth == 0 {
+				start, inner = i, false
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && inner {
+				// Check the Group with its comments blanked.
+				if err := checkGroup(src[start : i+1]); err != nil {
+					return nil, nil, err
+				}
+				// Keep the Group as is rather than lose its comments.
+				items = append(items, item{pos: start, end: i + 1, text: string(orig[start : i+1]), raw: true})
+				for k := start; k <= i; k++ {
+					src[k] = ' '
+				}
+			}
+		case ';':
+			j := bytes.IndexByte(src[i:], '\n')
+			if j < 0 {
+				j = len(src) - i
+			}
+			if depth > 0 {
+				inner = true
+				for k := i; k < i+j; k++ {
+					src[k] = ' '
+				}
+				i += j - 1
+				continue
+			}
+			items = append(items, item{
+				pos:  i,
+				end:  i + j,
+				text: string(bytes.TrimRight(src[i:i+j], " \t\r")),
+			})
+			for k := i; k < i+j; k++ {
+				src[k] = ' '
+			}
+			i += j - 1
+		}
+	}
+	return src, items, nil
+}
+
+// checkGroup returns an error if the Group g cannot be parsed.
+func checkGroup(g []byte) error {
+	var sc scan.Scanner
+	sc.Reset(bytes.NewReader(g))
+	for range sc.Nodes() {
+	}
+	return sc.Err()
+}
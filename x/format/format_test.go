@@ -24,3 +24,69 @@ func TestSource(t *testing.T) {
 		})
 	}
 }
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name:  "one per line",
+		input: "(a  b)(c)\n\n\n\nd  1",
+		want:  "(a b)\n(c)\n\nd\n1\n",
+	}, {
+		name:  "width",
+		input: "(add (mul 1 2) (mul 3 4))",
+		opts:  Options{Width: 16},
+		want:  "(add\n  (mul 1 2)\n  (mul 3 4))\n",
+	}, {
+		name:  "comments",
+		input: "; head\n(a)  ; note\n\n;tail",
+		opts:  Options{Comments: true},
+		want:  "; head\n(a) ; note\n\n;tail\n",
+	}, {
+		name:  "idempotent",
+		input: "; head\n(a) ; note\n\n;tail\n",
+		opts:  Options{Comments: true},
+		want:  "; head\n(a) ; note\n\n;tail\n",
+	}, {
+		name:  "comment inside Group is kept",
+		input: "(a  b)  (c ; (d)\n  d)  ; note\n(e  f)",
+		opts:  Options{Comments: true},
+		want:  "(a b)\n(c ; (d)\n  d) ; note\n(e f)\n",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Format([]byte(tc.input), tc.opts)
+			if err != nil {
+				t.Fatalf("Format(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("Format(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+	}{
+		{"unclosed Group", "(a (b)", Options{}},
+		{"unexpected paren", "a)", Options{}},
+		{"comment without option", "; a", Options{}},
+		{"unclosed Group with comment", "(a ; b\n", Options{Comments: true}},
+		{"invalid Group with comment", "(a ; c\n -?! \xff)", Options{Comments: true}},
+		{"invalid nested Group with comment", "(a (b ; c\n !))", Options{Comments: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Format([]byte(tc.input), tc.opts); err == nil {
+				t.Errorf("Format(%q): got err = nil, want err", tc.name)
+			}
+		})
+	}
+}
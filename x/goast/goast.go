@@ -0,0 +1,145 @@
+// Package goast converts Go syntax trees from go/ast into Lisp values.
+//
+// Each node is converted to a Group with the node type name as the head
+// and a (Field value) Group for each field that is set:
+//
+//	x + 1   (BinaryExpr (X (Ident (Name x))) (Op (q (u 43))) (Y (BasicLit (Kind INT) (Value 1))))
+//
+// Strings and tokens are quoted using xlisp.Quote. Lists of nodes are
+// converted to a Group of nodes. Nil, empty and false fields are omitted,
+// as are the deprecated Obj, Scope and Unresolved fields.
+//
+// Positions are omitted unless enabled in Options,
+// in which case each position field is converted to (Field Line Column).
+package goast
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Options for conversion.
+type Options struct {
+	// Positions includes position fields as (Field Line Column).
+	Positions bool
+}
+
+// ParseFile parses the Go source file and converts it to a Lisp value.
+//
+// The arguments are as for parser.ParseFile. Comments are parsed and converted.
+func ParseFile(fset *token.FileSet, filename string, src any, opts Options) (lisp.Val, error) {
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	return Node(fset, f, opts), nil
+}
+
+// Node converts the node n to a Lisp value.
+//
+// The FileSet fset is used for positions and may be nil if Positions is not set.
+func Node(fset *token.FileSet, n ast.Node, opts Options) lisp.Val {
+	c := converter{Options: opts, fset: fset}
+	v, _ := c.value(reflect.ValueOf(n))
+	return v
+}
+
+var (
+	posType   = reflect.TypeFor[token.Pos]()
+	tokenType = reflect.TypeFor[token.Token]()
+)
+
+// skipFields are deprecated fields which are not converted.
+var skipFields = map[string]bool{
+	"Obj":        true,
+	"Scope":      true,
+	"Unresolved": true,
+}
+
+type converter struct {
+	Options
+
+	fset *token.FileSet
+}
+
+// value converts v and reports whether it is set.
+func (c *converter) value(v reflect.Value) (lisp.Val, bool) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil, false
+		}
+		return c.value(v.Elem())
+	case reflect.Struct:
+		g := lisp.Group{lisp.Lit(v.Type().Name())}
+		for i := range v.NumField() {
+			f := v.Type().Field(i)
+			if !f.IsExported() || skipFields[f.Name] {
+				continue
+			}
+			if f.Type == posType {
+				if x, ok := c.pos(v.Field(i)); ok {
+					g = append(g, append(lisp.Group{lisp.Lit(f.Name)}, x...))
+				}
+				continue
+			}
+			if x, ok := c.value(v.Field(i)); ok {
+				g = append(g, lisp.Group{lisp.Lit(f.Name), x})
+			}
+		}
+		return g, true
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil, false
+		}
+		g := make(lisp.Group, 0, v.Len())
+		for i := range v.Len() {
+			if x, ok := c.value(v.Index(i)); ok {
+				g = append(g, x)
+			}
+		}
+		return g, true
+	case reflect.String:
+		if v.Len() == 0 {
+			return nil, false
+		}
+		return xlisp.Quote(v.String()), true
+	case reflect.Bool:
+		if !v.Bool() {
+			return nil, false
+		}
+		return lisp.Lit("true"), true
+	case reflect.Int:
+		if v.Type() == posType {
+			return nil, false
+		}
+		if v.Type() == tokenType {
+			tok := v.Interface().(token.Token)
+			if tok == token.ILLEGAL {
+				return nil, false
+			}
+			return xlisp.Quote(tok.String()), true
+		}
+		if v.Int() == 0 {
+			return nil, false
+		}
+		return lisp.Lit(strconv.FormatInt(v.Int(), 10)), true
+	default:
+		return nil, false
+	}
+}
+
+// pos converts the position v to Line Column and reports whether it is set.
+func (c *converter) pos(v reflect.Value) (lisp.Group, bool) {
+	if !c.Positions || !v.Interface().(token.Pos).IsValid() {
+		return nil, false
+	}
+	p := c.fset.Position(v.Interface().(token.Pos))
+	return lisp.Group{xlisp.Nat(uint64(p.Line)), xlisp.Nat(uint64(p.Column))}, true
+}
@@ -0,0 +1,84 @@
+package goast
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParse(t *testing.T, src string) (val lisp.Val) {
+	t.Helper()
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		val = n.Val
+		break
+	}
+	if err := sc.Err(); err != nil {
+		panic(fmt.Sprintf("mustParse: failed to parse: %q: %v", src, err))
+	}
+	return val
+}
+
+func TestNode(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{{
+		name:  "ident",
+		input: "x",
+		want:  "(Ident (Name x))",
+	}, {
+		name:  "binary expr",
+		input: "x + 1",
+		want:  "(BinaryExpr (X (Ident (Name x))) (Op (q (u 43))) (Y (BasicLit (Kind INT) (Value 1))))",
+	}, {
+		name:  "string literal",
+		input: `f("a b")`,
+		want:  "(CallExpr (Fun (Ident (Name f))) (Args ((BasicLit (Kind STRING) (Value (q (u 34)a(u 32)b(u 34)))))))",
+	}, {
+		name:  "positions",
+		input: "-x",
+		opts:  Options{Positions: true},
+		want:  "(UnaryExpr (OpPos 1 1) (Op (q (u 45))) (X (Ident (NamePos 1 2) (Name x))))",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			e, err := parser.ParseExprFrom(fset, "", tc.input, 0)
+			if err != nil {
+				t.Fatalf("ParseExpr(%q): got err = %v", tc.name, err)
+			}
+			got := Node(fset, e, tc.opts)
+			if diff := cmp.Diff(mustParse(t, tc.want), got); diff != "" {
+				t.Errorf("Node(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	const src = "package p\n\n// Doc.\nfunc f() {}\n"
+	got, err := ParseFile(token.NewFileSet(), "p.go", src, Options{})
+	if err != nil {
+		t.Fatalf("ParseFile(): got err = %v", err)
+	}
+	want := mustParse(t, `(File
+	(Name (Ident (Name p)))
+	(Decls ((FuncDecl
+		(Doc (CommentGroup (List ((Comment (Text (q (u 47)(u 47)(u 32)Doc(u 46))))))))
+		(Name (Ident (Name f)))
+		(Type (FuncType (Params (FieldList))))
+		(Body (BlockStmt)))))
+	(Comments ((CommentGroup (List ((Comment (Text (q (u 47)(u 47)(u 32)Doc(u 46))))))))))`)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseFile(): got diff (-want, +got):\n%v", diff)
+	}
+}
@@ -12,25 +12,28 @@ import (
 type MapHash struct {
 	maphash.Hash
 
-	v    visit.Visitor // init by once
-	once sync.Once
+	v     visit.Visitor // init by once
+	once  sync.Once
+	delim bool
 }
 
 func (h *MapHash) initVisitor() {
-	var delim bool
 	h.v.SetLitVisitor(func(x lisp.Lit) {
-		if delim {
+		if h.delim {
 			h.WriteByte(' ')
 		}
 		h.WriteString(string(x))
-		delim = true
+		h.delim = true
 	})
-	h.v.SetBeforeGroupVisitor(func(lisp.Group) { h.WriteByte('('); delim = false })
-	h.v.SetAfterGroupVisitor(func(lisp.Group) { h.WriteByte(')'); delim = false })
+	h.v.SetBeforeGroupVisitor(func(lisp.Group) { h.WriteByte('('); h.delim = false })
+	h.v.SetAfterGroupVisitor(func(lisp.Group) { h.WriteByte(')'); h.delim = false })
 }
 
 // WriteValue hashes the Val into the MapHash.
+//
+// The hash of v does not depend on Vals written previously.
 func (h *MapHash) WriteVal(v lisp.Val) {
 	h.once.Do(h.initVisitor)
+	h.delim = false
 	h.v.Visit(v)
 }
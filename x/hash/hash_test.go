@@ -84,5 +84,5 @@ func mustParse(t *testing.T, input string) (val lisp.Val) {
 	if err := sc.Err(); err != nil {
 		t.Fatalf("mustParse(%q): failed: %v", input, err)
 	}
-	return nil
+	return val
 }
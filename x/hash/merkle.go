@@ -0,0 +1,130 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/ajzaff/lisp"
+)
+
+// Digest is a stable SHA-256 content hash of a Lisp value.
+//
+// Digests do not depend on a seed and may be persisted.
+// The Digest of a Group is computed from the Digests of its elements:
+//
+//	DigestLit(x)       = SHA-256(0x00 {uvarint len(x)} x)
+//	DigestGroup(d ...) = SHA-256(0x01 {uvarint len(d)} d ...)
+type Digest [sha256.Size]byte
+
+// Domain prefixes.
+const (
+	litDomain   = 0
+	groupDomain = 1
+)
+
+// String returns the Digest in hexadecimal.
+func (d Digest) String() string { return hex.EncodeToString(d[:]) }
+
+// DigestLit returns the Digest of the Lit x.
+func DigestLit(x lisp.Lit) Digest {
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64+len(x))
+	buf = append(buf, litDomain)
+	buf = binary.AppendUvarint(buf, uint64(len(x)))
+	buf = append(buf, x...)
+	return sha256.Sum256(buf)
+}
+
+// DigestGroup returns the Digest of a Group with the element Digests.
+func DigestGroup(elems []Digest) Digest {
+	h := sha256.New()
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64)
+	buf = append(buf, groupDomain)
+	buf = binary.AppendUvarint(buf, uint64(len(elems)))
+	h.Write(buf)
+	for _, d := range elems {
+		h.Write(d[:])
+	}
+	var d Digest
+	h.Sum(d[:0])
+	return d
+}
+
+// DigestVal returns the Digest of v.
+func DigestVal(v lisp.Val) Digest {
+	switch v := v.(type) {
+	case lisp.Lit:
+		return DigestLit(v)
+	case lisp.Group:
+		elems := make([]Digest, len(v))
+		for i, x := range v {
+			elems[i] = DigestVal(x)
+		}
+		return DigestGroup(elems)
+	default:
+		panic("Unexpected Val type")
+	}
+}
+
+// ProofStep is one level of a Proof.
+type ProofStep struct {
+	Index int      // Index of the subtree in the Group.
+	Elems []Digest // Element Digests of the Group.
+}
+
+// Proof proves that a subtree with the Digest Leaf is part of a root.
+type Proof struct {
+	Leaf  Digest
+	Steps []ProofStep // Steps from the innermost Group to the root.
+}
+
+// Prove returns a Proof that the subtree of root at path is part of root.
+//
+// Path is a sequence of Group indices from the root.
+func Prove(root lisp.Val, path []int) (Proof, error) {
+	v := root
+	steps := make([]ProofStep, 0, len(path))
+	for depth, i := range path {
+		g, ok := v.(lisp.Group)
+		if !ok {
+			return Proof{}, fmt.Errorf("path element %d: expected Group", depth)
+		}
+		if i < 0 || i >= len(g) {
+			return Proof{}, fmt.Errorf("path element %d: index %d out of range [0, %d)", depth, i, len(g))
+		}
+		elems := make([]Digest, len(g))
+		for j, x := range g {
+			if j != i {
+				elems[j] = DigestVal(x)
+			}
+		}
+		steps = append(steps, ProofStep{Index: i, Elems: elems})
+		v = g[i]
+	}
+	slices.Reverse(steps)
+	p := Proof{Leaf: DigestVal(v), Steps: steps}
+	// Fill in the Digests along the path.
+	d := p.Leaf
+	for _, s := range p.Steps {
+		s.Elems[s.Index] = d
+		d = DigestGroup(s.Elems)
+	}
+	return p, nil
+}
+
+// Verify reports whether the Proof proves Leaf is part of the value with the root Digest.
+func (p Proof) Verify(root Digest) bool {
+	d := p.Leaf
+	elems := []Digest(nil)
+	for _, s := range p.Steps {
+		if s.Index < 0 || s.Index >= len(s.Elems) {
+			return false
+		}
+		elems = append(elems[:0], s.Elems...)
+		elems[s.Index] = d
+		d = DigestGroup(elems)
+	}
+	return d == root
+}
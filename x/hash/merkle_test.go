@@ -0,0 +1,84 @@
+package hash
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/x/fuzzutil"
+)
+
+func TestDigestGolden(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{"a", "6a9662194f63c1d38f6685d65fd9d380e049f447fb13e0b9d9c7a4f2d92015cc"},
+		{"(a b)", "6403b86760b69a5d20e8af77ab6b9c9bbe677ea570ed71d79cd8b93a68264938"},
+	} {
+		if got := DigestVal(mustParse(t, tc.input)).String(); got != tc.want {
+			t.Errorf("DigestVal(%q): got %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestDigestDistinct(t *testing.T) {
+	inputs := []string{"a", "(a)", "((a))", "(a b)", "(ab)", "(a (b))", "((a) b)", "()", "(())", "(() ())"}
+	seen := make(map[Digest]string)
+	for _, input := range inputs {
+		d := DigestVal(mustParse(t, input))
+		if prev, ok := seen[d]; ok {
+			t.Errorf("DigestVal(%q) == DigestVal(%q) but wanted distinct digests", input, prev)
+		}
+		seen[d] = input
+	}
+}
+
+func TestDigestGroup(t *testing.T) {
+	v := mustParse(t, "(a (b c) ())").(lisp.Group)
+	elems := []Digest{DigestVal(v[0]), DigestVal(v[1]), DigestVal(v[2])}
+	if got, want := DigestGroup(elems), DigestVal(v); got != want {
+		t.Errorf("DigestGroup(): got %v, want %v", got, want)
+	}
+}
+
+// paths returns the paths to all subtrees of v.
+func paths(v lisp.Val, prefix []int, yield func([]int)) {
+	yield(prefix)
+	if g, ok := v.(lisp.Group); ok {
+		for i, x := range g {
+			paths(x, append(prefix[:len(prefix):len(prefix)], i), yield)
+		}
+	}
+}
+
+func TestProve(t *testing.T) {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 4
+	for range 20 {
+		v := g.Next()
+		root := DigestVal(v)
+		paths(v, nil, func(path []int) {
+			p, err := Prove(v, path)
+			if err != nil {
+				t.Fatalf("Prove(%v): got err = %v", path, err)
+			}
+			if !p.Verify(root) {
+				t.Errorf("Verify(%v): got false, want true", path)
+			}
+			p.Leaf[0] ^= 1
+			if p.Verify(root) {
+				t.Errorf("Verify(%v) with bad Leaf: got true, want false", path)
+			}
+		})
+	}
+}
+
+func TestProveBadPath(t *testing.T) {
+	v := mustParse(t, "(a (b))")
+	for _, path := range [][]int{{2}, {-1}, {0, 0}, {1, 1}} {
+		if _, err := Prove(v, path); err == nil {
+			t.Errorf("Prove(%v): got err = nil, want err", path)
+		}
+	}
+}
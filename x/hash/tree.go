@@ -0,0 +1,99 @@
+package hash
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+
+	"github.com/ajzaff/lisp"
+)
+
+// Subtree is a subtree of a value with its ID.
+type Subtree struct {
+	ID     uint64
+	Val    lisp.Val
+	Parent int // Index of the parent Subtree or -1 for the root.
+}
+
+// Subtrees returns all subtrees of v with their IDs in post-order.
+//
+// IDs are computed bottom-up in a single pass by combining the IDs of the elements
+// of each Group. Nil elements are skipped. The root of v is the last Subtree.
+func Subtrees(seed maphash.Seed, v lisp.Val) []Subtree {
+	if v == nil {
+		return nil
+	}
+	var h maphash.Hash
+	h.SetSeed(seed)
+	var t []Subtree
+	subtrees(&h, v, &t)
+	t[len(t)-1].Parent = -1
+	return t
+}
+
+// SumVal returns the ID of v as computed by Subtrees.
+func SumVal(seed maphash.Seed, v lisp.Val) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	return sumVal(&h, v)
+}
+
+func sumVal(h *maphash.Hash, v lisp.Val) uint64 {
+	switch v := v.(type) {
+	case lisp.Lit:
+		return sumLit(h, v)
+	case lisp.Group:
+		ids := make([]uint64, 0, len(v))
+		for _, x := range v {
+			if x != nil {
+				ids = append(ids, sumVal(h, x))
+			}
+		}
+		return sumGroup(h, ids)
+	default:
+		panic("Unexpected Val type")
+	}
+}
+
+func sumLit(h *maphash.Hash, x lisp.Lit) uint64 {
+	h.Reset()
+	h.WriteByte(litDomain)
+	h.WriteString(string(x))
+	return h.Sum64()
+}
+
+func sumGroup(h *maphash.Hash, ids []uint64) uint64 {
+	h.Reset()
+	h.WriteByte(groupDomain)
+	var buf [8]byte
+	for _, id := range ids {
+		binary.LittleEndian.PutUint64(buf[:], id)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// subtrees appends the subtrees of v to t and returns the index of v.
+func subtrees(h *maphash.Hash, v lisp.Val, t *[]Subtree) int {
+	switch v := v.(type) {
+	case lisp.Lit:
+		*t = append(*t, Subtree{ID: sumLit(h, v), Val: v})
+	case lisp.Group:
+		elems := make([]int, 0, len(v))
+		ids := make([]uint64, 0, len(v))
+		for _, x := range v {
+			if x == nil {
+				continue
+			}
+			i := subtrees(h, x, t)
+			elems = append(elems, i)
+			ids = append(ids, (*t)[i].ID)
+		}
+		*t = append(*t, Subtree{ID: sumGroup(h, ids), Val: v})
+		for _, i := range elems {
+			(*t)[i].Parent = len(*t) - 1
+		}
+	default:
+		panic("Unexpected Val type")
+	}
+	return len(*t) - 1
+}
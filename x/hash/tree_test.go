@@ -0,0 +1,95 @@
+package hash
+
+import (
+	"hash/maphash"
+	"math/rand"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/x/fuzzutil"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+func TestSubtrees(t *testing.T) {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 5
+	seed := maphash.MakeSeed()
+	for range 50 {
+		v := g.Next()
+		subs := Subtrees(seed, v)
+		if len(subs) == 0 {
+			t.Fatalf("Subtrees(%v): got no subtrees", v)
+		}
+		root := subs[len(subs)-1]
+		if root.Parent != -1 || !xlisp.Equal(root.Val, v) {
+			t.Errorf("Subtrees(%v): got root %v with parent %d, want the value with parent -1", v, root.Val, root.Parent)
+		}
+		for i, st := range subs {
+			if want := SumVal(seed, st.Val); st.ID != want {
+				t.Errorf("Subtrees(%v)[%d]: got ID %d, want %d", v, i, st.ID, want)
+			}
+			if st.Parent == -1 {
+				continue
+			}
+			if st.Parent <= i {
+				t.Errorf("Subtrees(%v)[%d]: got parent %d before child", v, i, st.Parent)
+			}
+			found := false
+			for _, x := range subs[st.Parent].Val.(lisp.Group) {
+				if xlisp.Equal(x, st.Val) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Subtrees(%v)[%d]: got parent %v which does not contain %v", v, i, subs[st.Parent].Val, st.Val)
+			}
+		}
+	}
+}
+
+func TestSumValDistinct(t *testing.T) {
+	seed := maphash.MakeSeed()
+	inputs := []string{"a", "(a)", "((a))", "(a b)", "(ab)", "(a (b))", "((a) b)", "()", "(())", "(() ())"}
+	seen := make(map[uint64]string)
+	for _, input := range inputs {
+		id := SumVal(seed, mustParse(t, input))
+		if prev, ok := seen[id]; ok {
+			t.Errorf("SumVal(%q) == SumVal(%q) but wanted distinct IDs", input, prev)
+		}
+		seen[id] = input
+	}
+}
+
+func deepVal(depth int) lisp.Val {
+	var v lisp.Val = lisp.Lit("a")
+	for range depth {
+		v = lisp.Group{lisp.Lit("b"), v}
+	}
+	return v
+}
+
+func BenchmarkSubtreesDeep(b *testing.B) {
+	v := deepVal(1000)
+	seed := maphash.MakeSeed()
+	for i := 0; i < b.N; i++ {
+		res = len(Subtrees(seed, v))
+	}
+}
+
+// BenchmarkMapHashSubtreesDeep rehashes each subtree with MapHash for comparison.
+func BenchmarkMapHashSubtreesDeep(b *testing.B) {
+	v := deepVal(1000)
+	var h MapHash
+	for i := 0; i < b.N; i++ {
+		for x := v; ; {
+			h.Reset()
+			h.WriteVal(x)
+			res = int(h.Sum64())
+			g, ok := x.(lisp.Group)
+			if !ok {
+				break
+			}
+			x = g[1]
+		}
+	}
+}
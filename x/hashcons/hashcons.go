@@ -0,0 +1,222 @@
+// Package hashcons implements hash-consing of Lisp values.
+//
+// A Table canonicalizes values so that equal subtrees share the same memory.
+// Values returned by the Table can be compared in constant time with Same.
+package hashcons
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/x/hash"
+)
+
+type entry struct {
+	v    lisp.Val
+	refs int
+}
+
+// Table is a hash-consing table of canonical Lisp values.
+//
+// Each canonical value is reference counted. Intern, Lit and Group acquire
+// a reference to the returned value which is released by Release.
+// Canonical Groups hold a reference to each of their elements.
+//
+// Values returned by the Table are shared and must not be modified.
+// Table is safe for concurrent use.
+type Table struct {
+	mu      sync.Mutex
+	h       hash.MapHash
+	buckets map[uint64][]*entry
+	n       int
+}
+
+// NewTable returns an empty Table.
+func NewTable() *Table {
+	t := &Table{buckets: make(map[uint64][]*entry)}
+	t.h.SetSeed(maphash.MakeSeed())
+	return t
+}
+
+// Len returns the number of canonical values in the Table.
+func (t *Table) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.n
+}
+
+// Same reports whether a and b are the same canonical value.
+//
+// Same takes constant time and reports whether two values returned by
+// the same Table are equal.
+func Same(a, b lisp.Val) bool {
+	switch a := a.(type) {
+	case lisp.Lit:
+		b, ok := b.(lisp.Lit)
+		return ok && len(a) == len(b) && unsafe.StringData(string(a)) == unsafe.StringData(string(b))
+	case lisp.Group:
+		b, ok := b.(lisp.Group)
+		return ok && len(a) == len(b) && unsafe.SliceData(a) == unsafe.SliceData(b)
+	default:
+		return a == nil && b == nil
+	}
+}
+
+// Intern returns the canonical value equal to v.
+func (t *Table) Intern(v lisp.Val) lisp.Val {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.intern(v)
+}
+
+// Lit returns the canonical Lit for x.
+func (t *Table) Lit(x string) lisp.Lit {
+	return t.Intern(lisp.Lit(x)).(lisp.Lit)
+}
+
+// Group returns the canonical Group of the elements.
+func (t *Table) Group(elems ...lisp.Val) lisp.Group {
+	return t.Intern(lisp.Group(elems)).(lisp.Group)
+}
+
+// Release releases a reference to the canonical value v.
+//
+// v is removed from the Table when its last reference is released.
+// Release has no effect if v is not a canonical value in the Table.
+func (t *Table) Release(v lisp.Val) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.release(v)
+}
+
+func (t *Table) release(v lisp.Val) {
+	if v == nil {
+		return
+	}
+	id := t.sum(v)
+	bucket := t.buckets[id]
+	for i, e := range bucket {
+		if !Same(e.v, v) {
+			continue
+		}
+		if e.refs--; e.refs > 0 {
+			return
+		}
+		bucket = append(bucket[:i], bucket[i+1:]...)
+		if len(bucket) == 0 {
+			delete(t.buckets, id)
+		} else {
+			t.buckets[id] = bucket
+		}
+		t.n--
+		if g, ok := v.(lisp.Group); ok {
+			for _, x := range g {
+				t.release(x)
+			}
+		}
+		return
+	}
+}
+
+// sum hashes a Lit by its text and a Group by the identity of its canonical elements.
+func (t *Table) sum(v lisp.Val) uint64 {
+	t.h.Reset()
+	switch v := v.(type) {
+	case lisp.Lit:
+		t.h.WriteByte(0)
+		t.h.WriteVal(v)
+	case lisp.Group:
+		t.h.WriteByte(1)
+		var buf [16]byte
+		for _, x := range v {
+			var p unsafe.Pointer
+			var n int
+			switch x := x.(type) {
+			case lisp.Lit:
+				p, n = unsafe.Pointer(unsafe.StringData(string(x))), len(x)
+			case lisp.Group:
+				p, n = unsafe.Pointer(unsafe.SliceData(x)), len(x)
+			}
+			binary.LittleEndian.PutUint64(buf[:8], uint64(uintptr(p)))
+			binary.LittleEndian.PutUint64(buf[8:], uint64(n))
+			t.h.Write(buf[:])
+		}
+	}
+	return t.h.Sum64()
+}
+
+// find returns the entry for v given canonical elements.
+func (t *Table) find(id uint64, v lisp.Val) *entry {
+	for _, e := range t.buckets[id] {
+		switch x := v.(type) {
+		case lisp.Lit:
+			if y, ok := e.v.(lisp.Lit); ok && x == y {
+				return e
+			}
+		case lisp.Group:
+			if y, ok := e.v.(lisp.Group); ok && sameElems(x, y) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func sameElems(a, b lisp.Group) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Same(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// intern returns the canonical value equal to v and acquires a reference to it.
+//
+// nil is canonical and is not stored in the Table.
+func (t *Table) intern(v lisp.Val) lisp.Val {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case lisp.Lit:
+		id := t.sum(v)
+		if e := t.find(id, v); e != nil {
+			e.refs++
+			return e.v
+		}
+		// Copy v so the canonical Lit does not retain a larger string.
+		x := lisp.Lit(strings.Clone(string(v)))
+		t.insert(id, x)
+		return x
+	case lisp.Group:
+		g := make(lisp.Group, len(v))
+		for i, x := range v {
+			g[i] = t.intern(x)
+		}
+		id := t.sum(g)
+		if e := t.find(id, g); e != nil {
+			// The existing Group already holds references to its elements.
+			for _, x := range g {
+				t.release(x)
+			}
+			e.refs++
+			return e.v
+		}
+		t.insert(id, g)
+		return g
+	default:
+		panic("Unexpected Val type")
+	}
+}
+
+func (t *Table) insert(id uint64, v lisp.Val) {
+	t.buckets[id] = append(t.buckets[id], &entry{v: v, refs: 1})
+	t.n++
+}
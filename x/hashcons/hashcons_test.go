@@ -0,0 +1,159 @@
+package hashcons
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/ajzaff/lisp/x/fuzzutil"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParse(t *testing.T, src string) (val lisp.Val) {
+	t.Helper()
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		val = n.Val
+		break
+	}
+	if err := sc.Err(); err != nil {
+		panic(fmt.Sprintf("mustParse: failed to parse: %q: %v", src, err))
+	}
+	return val
+}
+
+func TestIntern(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		input   string
+		wantLen int
+	}{{
+		name:    "Lit",
+		input:   "a",
+		wantLen: 1,
+	}, {
+		name:    "empty Group",
+		input:   "()",
+		wantLen: 1,
+	}, {
+		name:    "repeated Lits",
+		input:   "(a a a)",
+		wantLen: 2,
+	}, {
+		name:    "repeated subtrees",
+		input:   "((a b) (a b) ((a b)))",
+		wantLen: 5,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			tab := NewTable()
+			v := mustParse(t, tc.input)
+			a := tab.Intern(v)
+			if diff := cmp.Diff(v, a); diff != "" {
+				t.Errorf("Intern(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+			b := tab.Intern(mustParse(t, tc.input))
+			if !Same(a, b) {
+				t.Errorf("Same(%q): got false, want true", tc.name)
+			}
+			if got := tab.Len(); got != tc.wantLen {
+				t.Errorf("Len(%q): got %d, want %d", tc.name, got, tc.wantLen)
+			}
+			tab.Release(a)
+			if got := tab.Len(); got != tc.wantLen {
+				t.Errorf("Len(%q) after one Release: got %d, want %d", tc.name, got, tc.wantLen)
+			}
+			tab.Release(b)
+			if got := tab.Len(); got != 0 {
+				t.Errorf("Len(%q) after Release: got %d, want 0", tc.name, got)
+			}
+		})
+	}
+}
+
+func TestInternSharesSubtrees(t *testing.T) {
+	tab := NewTable()
+	g := tab.Intern(mustParse(t, "((a b) (a b))")).(lisp.Group)
+	if !Same(g[0], g[1]) {
+		t.Errorf("Same(): got false for equal subtrees, want true")
+	}
+	x := tab.Group(tab.Lit("a"), lisp.Lit("b"))
+	if !Same(g[0], x) {
+		t.Errorf("Same(): got false for Group, want true")
+	}
+	if Same(g[0], tab.Group(lisp.Lit("b"), lisp.Lit("a"))) {
+		t.Errorf("Same(): got true for unequal Groups, want false")
+	}
+}
+
+func TestInternNil(t *testing.T) {
+	tab := NewTable()
+	if x := tab.Intern(nil); x != nil {
+		t.Errorf("Intern(nil): got %#v, want nil", x)
+	}
+	g := tab.Intern(lisp.Group{nil, lisp.Group{lisp.Lit("a"), nil}}).(lisp.Group)
+	if diff := cmp.Diff(lisp.Group{nil, lisp.Group{lisp.Lit("a"), nil}}, g); diff != "" {
+		t.Errorf("Intern(): got diff (-want, +got):\n%v", diff)
+	}
+	if x := tab.Group(nil, tab.Group(lisp.Lit("a"), nil)); !Same(g, x) {
+		t.Errorf("Same(): got false for Groups with nil elements, want true")
+	}
+	if x := tab.Group(lisp.Group{}, lisp.Group{lisp.Lit("a"), nil}); Same(g, x) {
+		t.Errorf("Same(): got true for nil and empty Group elements, want false")
+	}
+}
+
+func TestInternCopiesInput(t *testing.T) {
+	tab := NewTable()
+	v := lisp.Group{lisp.Lit("a")}
+	x := tab.Intern(v)
+	v[0] = lisp.Lit("b")
+	if diff := cmp.Diff(lisp.Group{lisp.Lit("a")}, x); diff != "" {
+		t.Errorf("Intern(): got diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestInternConcurrent(t *testing.T) {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 4
+	var vs []lisp.Val
+	for range 50 {
+		vs = append(vs, g.Next())
+	}
+	tab := NewTable()
+	got := make([][]lisp.Val, 4)
+	var wg sync.WaitGroup
+	for i := range got {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, v := range vs {
+				got[i] = append(got[i], tab.Intern(v))
+			}
+		}()
+	}
+	wg.Wait()
+	for j, v := range vs {
+		if !xlisp.Equal(v, got[0][j]) {
+			t.Errorf("Intern(%d): got unequal value", j)
+		}
+		for i := 1; i < len(got); i++ {
+			if !Same(got[0][j], got[i][j]) {
+				t.Errorf("Same(%d): got false, want true", j)
+			}
+		}
+	}
+	for i := range got {
+		for _, v := range got[i] {
+			tab.Release(v)
+		}
+	}
+	if n := tab.Len(); n != 0 {
+		t.Errorf("Len() after Release: got %d, want 0", n)
+	}
+}
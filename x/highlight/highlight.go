@@ -0,0 +1,246 @@
+// Package highlight renders Lisp source with syntax highlighting.
+//
+// Source is rendered as ANSI escape sequences for terminals or as HTML
+// with CSS classes. Parens are colored by nesting depth, Nat and Id
+// literals are colored distinctly, and invalid tokens and unmatched
+// parens use the Invalid style. White space is preserved.
+package highlight
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+)
+
+// Style of a class of tokens.
+type Style struct {
+	// ANSI is a list of SGR parameters such as "1;31".
+	// Empty leaves the text unstyled in terminals.
+	ANSI string
+
+	// CSS is a list of CSS declarations such as "color: #d33".
+	CSS string
+}
+
+// Theme is a set of Styles used for highlighting.
+type Theme struct {
+	// Parens are styles for parens by nesting depth.
+	// Deeper parens cycle through the styles.
+	Parens []Style
+
+	Id      Style // Id literals.
+	Nat     Style // Nat literals.
+	Invalid Style // Invalid tokens and unmatched parens.
+}
+
+// Themes.
+var (
+	// Default is a theme for terminals with dark or light backgrounds.
+	Default = &Theme{
+		Parens: []Style{
+			{ANSI: "33", CSS: "color: #b58900"},
+			{ANSI: "35", CSS: "color: #d33682"},
+			{ANSI: "34", CSS: "color: #268bd2"},
+			{ANSI: "36", CSS: "color: #2aa198"},
+			{ANSI: "32", CSS: "color: #859900"},
+		},
+		Nat:     Style{ANSI: "96", CSS: "color: #6c71c4"},
+		Invalid: Style{ANSI: "1;4;31", CSS: "color: #dc322f; text-decoration: underline wavy"},
+	}
+
+	// Mono is a theme without colors.
+	Mono = &Theme{
+		Parens:  []Style{{ANSI: "1", CSS: "font-weight: bold"}},
+		Nat:     Style{ANSI: "3", CSS: "font-style: italic"},
+		Invalid: Style{ANSI: "4", CSS: "text-decoration: underline"},
+	}
+)
+
+// class of a segment of source.
+type class int
+
+const (
+	classSpace class = iota
+	classParen
+	classId
+	classNat
+	classInvalid
+)
+
+// segment of source with its class and paren depth.
+type segment struct {
+	text  string
+	c     class
+	depth int
+}
+
+// segments calls fn for each segment of src with its class and paren depth.
+//
+// Parens still open at the end of src are unmatched and use classInvalid.
+func segments(src []byte, fn func(text string, c class, depth int) error) error {
+	var sc scan.Scanner
+	sc.Reset(bytes.NewReader(src))
+	var toks []scan.Token
+	for tok := range sc.Tokens() {
+		toks = append(toks, tok)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	var (
+		segs []segment
+		open []int // Indices of segments for open parens.
+		off  int
+	)
+	for i, tok := range toks {
+		pos := int(tok.Pos)
+		if off < pos {
+			segs = append(segs, segment{string(src[off:pos]), classSpace, 0})
+		}
+		// Tokens end before the space leading up to the next token.
+		// Token text may differ from the source for invalid UTF-8.
+		next := len(src)
+		if i+1 < len(toks) {
+			next = int(toks[i+1].Pos)
+		}
+		off = next
+		if j := bytes.IndexAny(src[pos:next], " \t\r\n"); j >= 0 {
+			off = pos + j
+		}
+		c, d := classInvalid, 0
+		switch tok.Tok {
+		case lisp.LParen:
+			c, d = classParen, len(open)
+			open = append(open, len(segs))
+		case lisp.RParen:
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+				c, d = classParen, len(open)
+			}
+		case lisp.Id:
+			c = classId
+			if isNat(tok.Text) {
+				c = classNat
+			}
+		}
+		segs = append(segs, segment{string(src[pos:off]), c, d})
+	}
+	if off < len(src) {
+		segs = append(segs, segment{string(src[off:]), classSpace, 0})
+	}
+	for _, i := range open {
+		segs[i].c, segs[i].depth = classInvalid, 0
+	}
+	for _, s := range segs {
+		if err := fn(s.text, s.c, s.depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isNat(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || '9' < s[i] {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// style returns the style for the class c at the given depth.
+func (t *Theme) style(c class, depth int) Style {
+	switch c {
+	case classParen:
+		if len(t.Parens) == 0 {
+			return Style{}
+		}
+		return t.Parens[depth%len(t.Parens)]
+	case classId:
+		return t.Id
+	case classNat:
+		return t.Nat
+	case classInvalid:
+		return t.Invalid
+	default:
+		return Style{}
+	}
+}
+
+// ANSI writes src to w highlighted with ANSI escape sequences.
+func ANSI(w io.Writer, src []byte, t *Theme) error {
+	bw := bufio.NewWriter(w)
+	err := segments(src, func(text string, c class, depth int) error {
+		s := t.style(c, depth)
+		if s.ANSI == "" {
+			_, err := bw.WriteString(text)
+			return err
+		}
+		_, err := fmt.Fprintf(bw, "\x1b[%sm%s\x1b[0m", s.ANSI, text)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// className returns the CSS class name for the class c at the given depth.
+func className(c class, depth int, t *Theme) string {
+	switch c {
+	case classParen:
+		return fmt.Sprintf("lisp-paren%d", depth%max(1, len(t.Parens)))
+	case classId:
+		return "lisp-id"
+	case classNat:
+		return "lisp-nat"
+	case classInvalid:
+		return "lisp-invalid"
+	default:
+		return ""
+	}
+}
+
+// HTML writes src to w as escaped HTML with span elements for each token.
+//
+// The spans use the CSS classes lisp-paren0 through lisp-parenN for parens,
+// lisp-id, lisp-nat and lisp-invalid. See CSS for a matching style sheet.
+// The output is intended to be placed in a pre element.
+func HTML(w io.Writer, src []byte, t *Theme) error {
+	bw := bufio.NewWriter(w)
+	err := segments(src, func(text string, c class, depth int) error {
+		if c == classSpace {
+			_, err := bw.WriteString(html.EscapeString(text))
+			return err
+		}
+		_, err := fmt.Fprintf(bw, `<span class="%s">%s</span>`, className(c, depth, t), html.EscapeString(text))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// CSS returns a style sheet for the classes used by HTML with the Theme.
+func CSS(t *Theme) string {
+	var sb strings.Builder
+	rule := func(name string, s Style) {
+		if s.CSS != "" {
+			fmt.Fprintf(&sb, ".%s { %s }\n", name, s.CSS)
+		}
+	}
+	for i, s := range t.Parens {
+		rule(fmt.Sprintf("lisp-paren%d", i), s)
+	}
+	rule("lisp-id", t.Id)
+	rule("lisp-nat", t.Nat)
+	rule("lisp-invalid", t.Invalid)
+	return sb.String()
+}
@@ -0,0 +1,82 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var testTheme = &Theme{
+	Parens:  []Style{{ANSI: "1", CSS: "color: red"}, {ANSI: "2", CSS: "color: blue"}},
+	Id:      Style{ANSI: "3"},
+	Nat:     Style{ANSI: "4", CSS: "color: green"},
+	Invalid: Style{ANSI: "5", CSS: "color: gray"},
+}
+
+func TestANSI(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name:  "space",
+		input: " \n",
+		want:  " \n",
+	}, {
+		name:  "rainbow parens",
+		input: "(a (b) 1)\n",
+		want:  "\x1b[1m(\x1b[0m\x1b[3ma\x1b[0m \x1b[2m(\x1b[0m\x1b[3mb\x1b[0m\x1b[2m)\x1b[0m \x1b[4m1\x1b[0m\x1b[1m)\x1b[0m\n",
+	}, {
+		name:  "parens cycle",
+		input: "((()))",
+		want:  "\x1b[1m(\x1b[0m\x1b[2m(\x1b[0m\x1b[1m(\x1b[0m\x1b[1m)\x1b[0m\x1b[2m)\x1b[0m\x1b[1m)\x1b[0m",
+	}, {
+		name:  "invalid",
+		input: "a-b )",
+		want:  "\x1b[3ma\x1b[0m\x1b[5m-\x1b[0m\x1b[3mb\x1b[0m \x1b[5m)\x1b[0m",
+	}, {
+		name:  "invalid UTF-8",
+		input: "\xff a\xfe\n",
+		want:  "\x1b[5m\xff\x1b[0m \x1b[3ma\x1b[0m\x1b[5m\xfe\x1b[0m\n",
+	}, {
+		name:  "unclosed",
+		input: "(a (b)",
+		want:  "\x1b[5m(\x1b[0m\x1b[3ma\x1b[0m \x1b[2m(\x1b[0m\x1b[3mb\x1b[0m\x1b[2m)\x1b[0m",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := ANSI(&sb, []byte(tc.input), testTheme); err != nil {
+				t.Fatalf("ANSI(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(tc.want, sb.String()); diff != "" {
+				t.Errorf("ANSI(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	const input = "(a 1) <⍟>"
+	var sb strings.Builder
+	if err := HTML(&sb, []byte(input), testTheme); err != nil {
+		t.Fatalf("HTML(%q): got err = %v", input, err)
+	}
+	const want = `<span class="lisp-paren0">(</span><span class="lisp-id">a</span> <span class="lisp-nat">1</span><span class="lisp-paren0">)</span> <span class="lisp-invalid">&lt;⍟&gt;</span>`
+	if diff := cmp.Diff(want, sb.String()); diff != "" {
+		t.Errorf("HTML(%q): got diff (-want, +got):\n%v", input, diff)
+	}
+}
+
+func TestCSS(t *testing.T) {
+	const want = `.lisp-paren0 { color: red }
+.lisp-paren1 { color: blue }
+.lisp-nat { color: green }
+.lisp-invalid { color: gray }
+`
+	if diff := cmp.Diff(want, CSS(testTheme)); diff != "" {
+		t.Errorf("CSS(): got diff (-want, +got):\n%v", diff)
+	}
+}
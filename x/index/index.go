@@ -0,0 +1,234 @@
+// Package index implements a sidecar index of top-level expressions for random access into large files.
+//
+// The index records the byte range of every top-level expression in a text or blisp file.
+// The index format is:
+//
+//	Magic {format byte} {start uint64} {end uint64} ...
+//
+// Where format is 't' for text or 'b' for blisp and offsets are little endian.
+//
+// Blisp streams in dictionary mode are not supported
+// since their values cannot be decoded independently.
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/ajzaff/lisp/x/blisp"
+)
+
+const Magic = "lispidx1\n"
+
+// ErrDictMode is returned by Build for blisp streams in dictionary mode.
+var ErrDictMode = errors.New("index: blisp dictionary mode is not supported")
+
+// Format bytes recorded in the index header.
+const (
+	Text  byte = 't'
+	Blisp byte = 'b'
+)
+
+const (
+	headerLen = len(Magic) + 1
+	entryLen  = 16
+)
+
+// Build reads the text or blisp source from r and writes its index to w.
+//
+// Blisp sources are detected by blisp.Magic.
+// Blisp sources in dictionary mode return ErrDictMode.
+func Build(w io.Writer, r io.Reader) error {
+	br := bufio.NewReader(r)
+	format := Text
+	switch magic, _ := br.Peek(len(blisp.Magic)); string(magic) {
+	case blisp.Magic:
+		format = Blisp
+		br.Discard(len(blisp.Magic))
+	case blisp.DictMagic:
+		return ErrDictMode
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(Magic)
+	bw.WriteByte(format)
+	var buf [entryLen]byte
+	put := func(start, end int64) error {
+		binary.LittleEndian.PutUint64(buf[:8], uint64(start))
+		binary.LittleEndian.PutUint64(buf[8:], uint64(end))
+		_, err := bw.Write(buf[:])
+		return err
+	}
+	if format == Blisp {
+		var d blisp.Decoder
+		d.Reset(br)
+		for {
+			start := d.Offset()
+			if _, err := d.Decode(); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+			off := int64(len(blisp.Magic))
+			if err := put(off+start, off+d.Offset()); err != nil {
+				return err
+			}
+		}
+		return bw.Flush()
+	}
+	var sc scan.Scanner
+	sc.Reset(br)
+	for n := range sc.Nodes() {
+		if err := put(int64(n.Pos), int64(n.End)); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Reader decodes top-level expressions lazily using an index.
+//
+// Reader methods are safe for concurrent use.
+type Reader struct {
+	data   io.ReaderAt
+	index  io.ReaderAt
+	format byte
+	n      int
+}
+
+// NewReader returns a Reader for data using the index of the given size in bytes.
+func NewReader(data, index io.ReaderAt, size int64) (*Reader, error) {
+	var header [headerLen]byte
+	if _, err := index.ReadAt(header[:], 0); err != nil {
+		return nil, fmt.Errorf("failed to read index header: %w", err)
+	}
+	if string(header[:len(Magic)]) != Magic {
+		return nil, errors.New("not an index: bad magic")
+	}
+	format := header[len(Magic)]
+	if format != Text && format != Blisp {
+		return nil, fmt.Errorf("unknown index format %q", format)
+	}
+	if (size-int64(headerLen))%entryLen != 0 {
+		return nil, errors.New("index is truncated")
+	}
+	return &Reader{
+		data:   data,
+		index:  index,
+		format: format,
+		n:      int((size - int64(headerLen)) / entryLen),
+	}, nil
+}
+
+// Len returns the number of top-level expressions in the index.
+func (r *Reader) Len() int { return r.n }
+
+// Format returns the format byte of the indexed data.
+func (r *Reader) Format() byte { return r.format }
+
+// Offset returns the byte range of the ith expression.
+func (r *Reader) Offset(i int) (start, end int64, err error) {
+	if i < 0 || i >= r.n {
+		return 0, 0, fmt.Errorf("index %d out of range [0, %d)", i, r.n)
+	}
+	var buf [entryLen]byte
+	if _, err := r.index.ReadAt(buf[:], int64(headerLen+i*entryLen)); err != nil {
+		return 0, 0, err
+	}
+	start = int64(binary.LittleEndian.Uint64(buf[:8]))
+	end = int64(binary.LittleEndian.Uint64(buf[8:]))
+	return start, end, nil
+}
+
+// Val decodes the ith expression.
+func (r *Reader) Val(i int) (lisp.Val, error) {
+	start, end, err := r.Offset(i)
+	if err != nil {
+		return nil, err
+	}
+	var v lisp.Val
+	for x := range r.decode(io.NewSectionReader(r.data, start, end-start), &err) {
+		v = x
+		break
+	}
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("no value at index %d", i)
+	}
+	return v, nil
+}
+
+// Values returns an iteration over the expressions in the range [i, j)
+// and a function returning the error of the last iteration, if any.
+//
+// Each call to Values has its own error.
+func (r *Reader) Values(i, j int) (iter.Seq[lisp.Val], func() error) {
+	var err error
+	seq := func(yield func(lisp.Val) bool) {
+		err = nil
+		if i >= j {
+			return
+		}
+		start, _, e := r.Offset(i)
+		if e != nil {
+			err = e
+			return
+		}
+		_, end, e := r.Offset(j - 1)
+		if e != nil {
+			err = e
+			return
+		}
+		for v := range r.decode(io.NewSectionReader(r.data, start, end-start), &err) {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+	return seq, func() error { return err }
+}
+
+func (r *Reader) decode(src io.Reader, err *error) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		if r.format == Blisp {
+			var d blisp.Decoder
+			d.Reset(src)
+			for v := range d.Values() {
+				if !yield(v) {
+					break
+				}
+			}
+			*err = d.Err()
+			return
+		}
+		var sc scan.Scanner
+		sc.Reset(src)
+		for n := range sc.Nodes() {
+			if !yield(n.Val) {
+				break
+			}
+		}
+		*err = sc.Err()
+	}
+}
+
+// NewBytesReader returns a Reader for data using an index built in memory.
+func NewBytesReader(data []byte) (*Reader, error) {
+	var idx bytes.Buffer
+	if err := Build(&idx, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return NewReader(bytes.NewReader(data), bytes.NewReader(idx.Bytes()), int64(idx.Len()))
+}
@@ -0,0 +1,154 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/ajzaff/lisp/x/blisp"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func mustParseMultiple(t *testing.T, src string) []lisp.Val {
+	t.Helper()
+	var vs []lisp.Val
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		vs = append(vs, n.Val)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("mustParse: failed to parse: %q: %v", src, err)
+	}
+	return vs
+}
+
+func TestReader(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input []byte
+		blisp bool
+	}{{
+		name: "empty",
+	}, {
+		name:  "text",
+		input: []byte("a (b c)\n  (d (e)) 123\n(f)"),
+	}, {
+		name:  "blisp",
+		input: []byte("(a)(b c)((d)) 123 e"),
+		blisp: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			want := mustParseMultiple(t, string(tc.input))
+			data := tc.input
+			if tc.blisp {
+				var buf bytes.Buffer
+				var e blisp.Encoder
+				e.Reset(&buf)
+				e.EncodeMagic()
+				for _, v := range want {
+					e.Encode(v)
+				}
+				data = buf.Bytes()
+			}
+			r, err := NewBytesReader(data)
+			if err != nil {
+				t.Fatalf("NewBytesReader(%q): got err = %v", tc.name, err)
+			}
+			if gotLen := r.Len(); gotLen != len(want) {
+				t.Fatalf("Len(%q): got %d, want %d", tc.name, gotLen, len(want))
+			}
+			for i := range want {
+				got, err := r.Val(i)
+				if err != nil {
+					t.Fatalf("Val(%q, %d): got err = %v", tc.name, i, err)
+				}
+				if diff := cmp.Diff(want[i], got); diff != "" {
+					t.Errorf("Val(%q, %d): got diff (-want, +got):\n%v", tc.name, i, diff)
+				}
+			}
+			for i := 0; i <= len(want); i++ {
+				for j := i; j <= len(want); j++ {
+					values, errFn := r.Values(i, j)
+					got := slices.Collect(values)
+					if err := errFn(); err != nil {
+						t.Fatalf("Values(%q, %d, %d): got err = %v", tc.name, i, j, err)
+					}
+					if diff := cmp.Diff(want[i:j], got, cmpopts.EquateEmpty()); diff != "" {
+						t.Errorf("Values(%q, %d, %d): got diff (-want, +got):\n%v", tc.name, i, j, diff)
+					}
+				}
+			}
+			if _, err := r.Val(len(want)); err == nil {
+				t.Errorf("Val(%q, %d): got err = nil, want out of range error", tc.name, len(want))
+			}
+		})
+	}
+}
+
+func TestValuesNested(t *testing.T) {
+	r, err := NewBytesReader([]byte("a (b) c"))
+	if err != nil {
+		t.Fatalf("NewBytesReader(): got err = %v", err)
+	}
+	outer, outerErr := r.Values(0, 3)
+	for range outer {
+		inner, innerErr := r.Values(0, 5)
+		for range inner {
+		}
+		if innerErr() == nil {
+			t.Errorf("Values(0, 5): got err = nil, want out of range error")
+		}
+	}
+	if err := outerErr(); err != nil {
+		t.Errorf("Values(0, 3): got err = %v, want nil", err)
+	}
+}
+
+func TestNewReaderBadIndex(t *testing.T) {
+	for _, idx := range []string{
+		"",
+		"notindex\nt",
+		Magic + "x",
+		Magic + "t" + "short",
+	} {
+		if _, err := NewReader(strings.NewReader(""), strings.NewReader(idx), int64(len(idx))); err == nil {
+			t.Errorf("NewReader(%q): got err = nil, want err", idx)
+		}
+	}
+}
+
+func BenchmarkReaderVal(b *testing.B) {
+	var sb strings.Builder
+	for i := range 10000 {
+		fmt.Fprintf(&sb, "(record %d (a b c))\n", i)
+	}
+	r, err := NewBytesReader([]byte(sb.String()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Val(i % r.Len()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestBuildDictMode(t *testing.T) {
+	var buf bytes.Buffer
+	var e blisp.Encoder
+	e.Reset(&buf)
+	e.SetDict(nil)
+	e.EncodeMagic()
+	e.Encode(lisp.Group{lisp.Lit("a"), lisp.Lit("a")})
+	if _, err := NewBytesReader(buf.Bytes()); !errors.Is(err, ErrDictMode) {
+		t.Errorf("NewBytesReader(): got err = %v, want %v", err, ErrDictMode)
+	}
+}
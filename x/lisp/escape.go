@@ -6,7 +6,9 @@ import (
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/ajzaff/lisp"
 	"golang.org/x/text/unicode/rangetable"
 )
 
@@ -56,3 +58,85 @@ func Unescape(s string) string {
 
 	return sb.String()
 }
+
+// Quote returns a Val representing the string s.
+//
+// Quote returns s as a Lit when s is a valid Lit. Otherwise Quote returns
+// a Group (q parts...) where each part is a Lit run of valid runes,
+// (u N) for the code point N of any other rune, or (b N) for the byte N
+// of invalid UTF-8.
+func Quote(s string) lisp.Val {
+	if ValidLit(lisp.Lit(s)) {
+		return lisp.Lit(s)
+	}
+	g := lisp.Group{lisp.Lit("q")}
+	start := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if IsLit(r) && (r != utf8.RuneError || size > 1) {
+			i += size
+			continue
+		}
+		if start < i {
+			g = append(g, lisp.Lit(s[start:i]))
+		}
+		if r == utf8.RuneError && size == 1 {
+			g = append(g, lisp.Group{lisp.Lit("b"), Nat(uint64(s[i]))})
+		} else {
+			g = append(g, lisp.Group{lisp.Lit("u"), Nat(uint64(r))})
+		}
+		i += size
+		start = i
+	}
+	if start < len(s) {
+		g = append(g, lisp.Lit(s[start:]))
+	}
+	return g
+}
+
+// Unquote returns the string represented by the Val v as returned by Quote.
+func Unquote(v lisp.Val) (string, error) {
+	switch v := v.(type) {
+	case lisp.Lit:
+		return string(v), nil
+	case lisp.Group:
+		if len(v) == 0 || v[0] != lisp.Lit("q") {
+			return "", fmt.Errorf("unquote: expected (q ...), got %v", v)
+		}
+		var sb strings.Builder
+		for _, x := range v[1:] {
+			switch x := x.(type) {
+			case lisp.Lit:
+				sb.WriteString(string(x))
+			case lisp.Group:
+				if len(x) == 2 && x[0] == lisp.Lit("b") {
+					n, ok := x[1].(lisp.Lit)
+					if !ok {
+						return "", fmt.Errorf("unquote: expected (b N), got %v", x)
+					}
+					b, err := strconv.ParseUint(string(n), 10, 8)
+					if err != nil {
+						return "", fmt.Errorf("unquote: bad byte %v", n)
+					}
+					sb.WriteByte(byte(b))
+					continue
+				}
+				if len(x) != 2 || x[0] != lisp.Lit("u") {
+					return "", fmt.Errorf("unquote: expected (u N), got %v", x)
+				}
+				n, ok := x[1].(lisp.Lit)
+				if !ok {
+					return "", fmt.Errorf("unquote: expected (u N), got %v", x)
+				}
+				u, err := strconv.ParseUint(string(n), 10, 32)
+				if err != nil || u > unicode.MaxRune {
+					return "", fmt.Errorf("unquote: bad code point %v", n)
+				}
+				sb.WriteRune(rune(u))
+			}
+		}
+		return sb.String(), nil
+	default:
+		return "", fmt.Errorf("unquote: unexpected Val %v", v)
+	}
+}
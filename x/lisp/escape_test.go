@@ -3,6 +3,7 @@ package lisp
 import (
 	"testing"
 
+	"github.com/ajzaff/lisp"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -36,3 +37,44 @@ func TestUnescape(t *testing.T) {
 		t.Errorf("Unescape() got diff:\n%s", diff)
 	}
 }
+
+func TestQuote(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  lisp.Val
+	}{
+		{"abc", lisp.Lit("abc")},
+		{"", lisp.Group{lisp.Lit("q")}},
+		{"a b", lisp.Group{lisp.Lit("q"), lisp.Lit("a"), lisp.Group{lisp.Lit("u"), lisp.Lit("32")}, lisp.Lit("b")}},
+		{"(x)", lisp.Group{lisp.Lit("q"), lisp.Group{lisp.Lit("u"), lisp.Lit("40")}, lisp.Lit("x"), lisp.Group{lisp.Lit("u"), lisp.Lit("41")}}},
+		{"\uFFFDa", lisp.Group{lisp.Lit("q"), lisp.Group{lisp.Lit("u"), lisp.Lit("65533")}, lisp.Lit("a")}},
+		{"a\xffb\xbd", lisp.Group{lisp.Lit("q"), lisp.Lit("a"), lisp.Group{lisp.Lit("b"), lisp.Lit("255")}, lisp.Lit("b"), lisp.Group{lisp.Lit("b"), lisp.Lit("189")}}},
+	} {
+		got := Quote(tc.input)
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("Quote(%q): got diff (-want, +got):\n%v", tc.input, diff)
+		}
+		s, err := Unquote(got)
+		if err != nil {
+			t.Fatalf("Unquote(%q): got err = %v", tc.input, err)
+		}
+		if s != tc.input {
+			t.Errorf("Unquote(%q): got %q", tc.input, s)
+		}
+	}
+}
+
+func TestUnquoteErrors(t *testing.T) {
+	for _, v := range []lisp.Val{
+		lisp.Group{},
+		lisp.Group{lisp.Lit("x")},
+		lisp.Group{lisp.Lit("q"), lisp.Group{lisp.Lit("u")}},
+		lisp.Group{lisp.Lit("q"), lisp.Group{lisp.Lit("u"), lisp.Lit("x")}},
+		lisp.Group{lisp.Lit("q"), lisp.Group{lisp.Lit("u"), lisp.Lit("99999999")}},
+		lisp.Group{lisp.Lit("q"), lisp.Group{lisp.Lit("b"), lisp.Lit("256")}},
+	} {
+		if _, err := Unquote(v); err == nil {
+			t.Errorf("Unquote(%v): got err = nil, want err", v)
+		}
+	}
+}
@@ -11,7 +11,8 @@ import (
 // by only considering Lits in a left-most in-order reading of the Val.
 func LexicalCompare(a, b lisp.Val) int {
 	// Fast compare check.
-	if a == b {
+	// Groups are not comparable.
+	if a, ok := a.(lisp.Lit); ok && a == b {
 		return 0
 	}
 	// Slower compare check.
@@ -0,0 +1,51 @@
+package lisp
+
+import (
+	"testing"
+
+	"github.com/ajzaff/lisp"
+)
+
+func TestLexicalCompare(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b lisp.Val
+		want int
+	}{{
+		name: "equal lits",
+		a:    lisp.Lit("a"),
+		b:    lisp.Lit("a"),
+		want: 0,
+	}, {
+		name: "lits",
+		a:    lisp.Lit("a"),
+		b:    lisp.Lit("b"),
+		want: -1,
+	}, {
+		name: "equal groups",
+		a:    lisp.Group{lisp.Lit("a")},
+		b:    lisp.Group{lisp.Lit("a")},
+		want: 0,
+	}, {
+		name: "groups",
+		a:    lisp.Group{lisp.Lit("b")},
+		b:    lisp.Group{lisp.Lit("a")},
+		want: 1,
+	}, {
+		name: "nested group and lit",
+		a:    lisp.Group{lisp.Group{lisp.Lit("a")}},
+		b:    lisp.Lit("b"),
+		want: -1,
+	}, {
+		name: "lit and nested group",
+		a:    lisp.Lit("b"),
+		b:    lisp.Group{lisp.Group{lisp.Lit("a")}},
+		want: 1,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := LexicalCompare(tc.a, tc.b); got != tc.want {
+				t.Errorf("LexicalCompare(%q): got %d, want %d", tc.name, got, tc.want)
+			}
+		})
+	}
+}
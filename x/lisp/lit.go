@@ -14,3 +14,19 @@ func Nat(i uint64) lisp.Lit {
 	x := strconv.FormatUint(i, 10)
 	return Id(x)
 }
+
+// ParseNat returns the value of x if x is a Nat in canonical form.
+//
+// Nats in canonical form have no leading zeros and fit in a uint64.
+func ParseNat(x lisp.Lit) (uint64, bool) {
+	if len(x) == 0 || len(x) > 1 && x[0] == '0' {
+		return 0, false
+	}
+	for i := 0; i < len(x); i++ {
+		if x[i] < '0' || '9' < x[i] {
+			return 0, false
+		}
+	}
+	n, err := strconv.ParseUint(string(x), 10, 64)
+	return n, err == nil
+}
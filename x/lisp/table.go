@@ -1,18 +1,11 @@
 package lisp
 
 import (
-	"unicode"
-
-	"golang.org/x/text/unicode/rangetable"
+	"github.com/ajzaff/lisp"
 )
 
-var idTab *unicode.RangeTable
-
-func init() {
-	idTab = rangetable.Merge(
-		unicode.Letter,
-		rangetable.New('0', '1', '2', '3', '4', '5', '6', '7', '8', '9'),
-	)
-}
+// IsLit returns whether r is a valid Lit rune.
+func IsLit(r rune) bool { return lisp.IsLitRune(r) }
 
-func IsLit(r rune) bool { return unicode.Is(idTab, r) }
+// ValidLit returns whether x is a nonempty Lit comprising only valid Lit runes.
+func ValidLit(x lisp.Lit) bool { return lisp.ValidLit(x) }
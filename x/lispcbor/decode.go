@@ -0,0 +1,362 @@
+package lispcbor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// maxDepth bounds the nesting of data items accepted by the Decoder.
+const maxDepth = 10000
+
+// Lits used for foreign data items.
+const (
+	negLit       lisp.Lit = "neg"
+	bytesLit     lisp.Lit = "bytes"
+	floatLit     lisp.Lit = "float"
+	mapLit       lisp.Lit = "map"
+	tagLit       lisp.Lit = "tag"
+	trueLit      lisp.Lit = "true"
+	falseLit     lisp.Lit = "false"
+	nullLit      lisp.Lit = "null"
+	undefinedLit lisp.Lit = "undefined"
+	simpleLit    lisp.Lit = "simple"
+	quoteLit     lisp.Lit = "q"
+	arrayLit     lisp.Lit = "array"
+)
+
+// reserved reports whether x is the head of a tagged Group.
+func reserved(x lisp.Lit) bool {
+	switch x {
+	case negLit, bytesLit, floatLit, mapLit, tagLit, trueLit, falseLit,
+		nullLit, undefinedLit, simpleLit, quoteLit, arrayLit:
+		return true
+	default:
+		return false
+	}
+}
+
+// errBreak is returned by item when it reads a break code.
+var errBreak = errors.New("lispcbor: unexpected break")
+
+// Decoder reads Lisp values from CBOR.
+type Decoder struct {
+	Options
+
+	r   *bufio.Reader
+	off int64
+	err error
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader, opts Options) *Decoder {
+	return &Decoder{Options: opts, r: bufio.NewReader(r)}
+}
+
+// Err returns the first error encountered by the Decoder other than io.EOF.
+func (d *Decoder) Err() error { return d.err }
+
+// Decode decodes the next value.
+//
+// Decode returns io.EOF when no more values are available.
+func (d *Decoder) Decode() (lisp.Val, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if _, err := d.r.Peek(1); err != nil {
+		if err != io.EOF {
+			d.err = err
+		}
+		return nil, err
+	}
+	v, err := d.item(0, true)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+		return nil, err
+	}
+	return v, nil
+}
+
+// Values returns an iteration over the decoded values.
+//
+// Errors are reported by Err.
+func (d *Decoder) Values() iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		for {
+			v, err := d.Decode()
+			if err != nil || !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err == nil {
+		d.off++
+	}
+	return b, err
+}
+
+func (d *Decoder) readFull(buf []byte) error {
+	n, err := io.ReadFull(d.r, buf)
+	d.off += int64(n)
+	return err
+}
+
+// readN reads n bytes.
+//
+// The bytes are read incrementally rather than trust n for the allocation.
+func (d *Decoder) readN(n uint64) ([]byte, error) {
+	buf, err := io.ReadAll(io.LimitReader(d.r, int64(n)))
+	d.off += int64(len(buf))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(buf)) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf, nil
+}
+
+// head reads the initial byte and argument of a data item.
+func (d *Decoder) head() (major, info byte, n uint64, err error) {
+	b, err := d.readByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b>>5, b&0x1f
+	var buf [8]byte
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		err = d.readFull(buf[:1])
+		n = uint64(buf[0])
+	case info == 25:
+		err = d.readFull(buf[:2])
+		n = uint64(binary.BigEndian.Uint16(buf[:2]))
+	case info == 26:
+		err = d.readFull(buf[:4])
+		n = uint64(binary.BigEndian.Uint32(buf[:4]))
+	case info == 27:
+		err = d.readFull(buf[:8])
+		n = binary.BigEndian.Uint64(buf[:8])
+	case info == infoIndefinite:
+		if major == majorUint || major == majorNeg || major == majorTag {
+			return 0, 0, 0, fmt.Errorf("lispcbor: invalid indefinite length at offset %d", d.off-1)
+		}
+	default:
+		return 0, 0, 0, fmt.Errorf("lispcbor: reserved additional information %d at offset %d", info, d.off-1)
+	}
+	return major, info, n, err
+}
+
+// str reads a definite or indefinite length string of the given major type.
+func (d *Decoder) str(major, info byte, n uint64) ([]byte, error) {
+	if info != infoIndefinite {
+		if n > maxLen {
+			return nil, fmt.Errorf("lispcbor: string length %d too large at offset %d", n, d.off)
+		}
+		return d.readN(n)
+	}
+	var buf []byte
+	for {
+		m, info, n, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m == majorSimple && info == infoIndefinite {
+			return buf, nil
+		}
+		if m != major || info == infoIndefinite {
+			return nil, fmt.Errorf("lispcbor: bad string chunk at offset %d", d.off)
+		}
+		if uint64(len(buf))+n > maxLen {
+			return nil, fmt.Errorf("lispcbor: string length too large at offset %d", d.off)
+		}
+		chunk, err := d.readN(n)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, chunk...)
+	}
+}
+
+// items reads the elements of a definite or indefinite length array or map.
+func (d *Decoder) items(depth int, info byte, n uint64, yield func(lisp.Val) error) error {
+	if info != infoIndefinite {
+		if n > maxLen {
+			return fmt.Errorf("lispcbor: length %d too large at offset %d", n, d.off)
+		}
+		for range n {
+			v, err := d.item(depth+1, false)
+			if err != nil {
+				return err
+			}
+			if err := yield(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for {
+		v, err := d.item(depth+1, false)
+		if err == errBreak {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := yield(v); err != nil {
+			return err
+		}
+	}
+}
+
+// floatVal returns the (float X) of f using the shortest text which preserves its value.
+func floatVal(f float64) lisp.Val {
+	return lisp.Group{floatLit, xlisp.Quote(strconv.FormatFloat(f, 'g', -1, 64))}
+}
+
+// item reads a data item.
+func (d *Decoder) item(depth int, top bool) (lisp.Val, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("lispcbor: nesting too deep at offset %d", d.off)
+	}
+	major, info, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case majorUint:
+		return xlisp.Nat(n), nil
+	case majorNeg:
+		if n == math.MaxUint64 {
+			return lisp.Group{negLit, lisp.Lit("18446744073709551616")}, nil
+		}
+		return lisp.Group{negLit, xlisp.Nat(n + 1)}, nil
+	case majorBytes:
+		b, err := d.str(major, info, n)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == 0 {
+			return lisp.Group{bytesLit}, nil
+		}
+		return lisp.Group{bytesLit, lisp.Lit(hex.EncodeToString(b))}, nil
+	case majorText:
+		b, err := d.str(major, info, n)
+		if err != nil {
+			return nil, err
+		}
+		if !utf8.Valid(b) {
+			return nil, fmt.Errorf("lispcbor: invalid UTF-8 text at offset %d", d.off)
+		}
+		if _, ok := xlisp.ParseNat(lisp.Lit(b)); ok {
+			// Text would be read as a Nat.
+			return lisp.Group{quoteLit, lisp.Lit(b)}, nil
+		}
+		return xlisp.Quote(string(b)), nil
+	case majorArray:
+		g := make(lisp.Group, 0, min(n, 1024))
+		err := d.items(depth, info, n, func(v lisp.Val) error {
+			g = append(g, v)
+			return nil
+		})
+		if len(g) > 0 {
+			if head, ok := g[0].(lisp.Lit); ok && reserved(head) {
+				g = append(lisp.Group{arrayLit}, g...)
+			}
+		}
+		return g, err
+	case majorMap:
+		g := lisp.Group{mapLit}
+		var k lisp.Val
+		if n > maxLen {
+			return nil, fmt.Errorf("lispcbor: map length %d too large at offset %d", n, d.off)
+		}
+		err := d.items(depth, info, 2*n, func(v lisp.Val) error {
+			if k == nil {
+				k = v
+				return nil
+			}
+			g = append(g, lisp.Group{k, v})
+			k = nil
+			return nil
+		})
+		if err == nil && k != nil {
+			err = fmt.Errorf("lispcbor: map missing value at offset %d", d.off)
+		}
+		return g, err
+	case majorTag:
+		v, err := d.item(depth+1, false)
+		if err != nil {
+			if err == errBreak {
+				err = fmt.Errorf("lispcbor: unexpected break at offset %d", d.off)
+			}
+			return nil, err
+		}
+		if top && d.TagNum != 0 && n == d.TagNum {
+			return v, nil
+		}
+		return lisp.Group{tagLit, xlisp.Nat(n), v}, nil
+	default: // majorSimple
+		switch info {
+		case 20:
+			return lisp.Group{falseLit}, nil
+		case 21:
+			return lisp.Group{trueLit}, nil
+		case 22:
+			return lisp.Group{nullLit}, nil
+		case 23:
+			return lisp.Group{undefinedLit}, nil
+		case 25:
+			return floatVal(float64(float16(uint16(n)))), nil
+		case 26:
+			return floatVal(float64(math.Float32frombits(uint32(n)))), nil
+		case 27:
+			return floatVal(math.Float64frombits(n)), nil
+		case infoIndefinite:
+			if top {
+				return nil, fmt.Errorf("lispcbor: unexpected break at offset %d", d.off)
+			}
+			return nil, errBreak
+		default:
+			return lisp.Group{simpleLit, xlisp.Nat(n)}, nil
+		}
+	}
+}
+
+// float16 returns the value of the IEEE 754 half-precision float h.
+func float16(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch exp {
+	case 0:
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+	}
+}
@@ -0,0 +1,163 @@
+package lispcbor
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Errors returned by the Encoder streaming methods.
+var (
+	ErrInvalidLit = errors.New("invalid Lit")
+	ErrUnbalanced = errors.New("unbalanced Group")
+)
+
+// Encoder writes Lisp values as CBOR.
+type Encoder struct {
+	Options
+
+	w     *bufio.Writer
+	buf   []byte
+	depth int          // Open Groups from BeginGroup.
+	stack []lisp.Group // Open Groups buffered in Deterministic mode.
+	err   error        // Sticky error.
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer, opts Options) *Encoder {
+	return &Encoder{Options: opts, w: bufio.NewWriter(w)}
+}
+
+// Err returns the first error encountered by the Encoder.
+func (e *Encoder) Err() error { return e.err }
+
+func (e *Encoder) setErr(err error) error {
+	if e.err == nil {
+		e.err = err
+	}
+	return e.err
+}
+
+func (e *Encoder) write(buf []byte) error {
+	if _, err := e.w.Write(buf); err != nil {
+		return e.setErr(err)
+	}
+	return nil
+}
+
+func (e *Encoder) appendTag(buf []byte) []byte {
+	if e.TagNum != 0 {
+		buf = appendHead(buf, majorTag, e.TagNum)
+	}
+	return buf
+}
+
+// Encode writes the value v and flushes the Encoder.
+//
+// Tagged Groups such as (neg M) are encoded as their CBOR data items.
+// Invalid Lits return ErrInvalidLit as with WriteLit.
+func (e *Encoder) Encode(v lisp.Val) error {
+	if e.err != nil {
+		return e.err
+	}
+	if v == nil {
+		return nil
+	}
+	buf, err := appendVal(e.appendTag(e.buf[:0]), v, e.Deterministic)
+	if err != nil {
+		return e.setErr(err)
+	}
+	e.buf = buf
+	if err := e.write(e.buf); err != nil {
+		return err
+	}
+	return e.Flush()
+}
+
+// BeginGroup opens a new Group incrementally.
+//
+// Groups are written with indefinite lengths unless Deterministic is set.
+// Groups written incrementally are always arrays even when they look like tagged Groups.
+func (e *Encoder) BeginGroup() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.Deterministic {
+		e.stack = append(e.stack, lisp.Group{})
+		e.depth++
+		return nil
+	}
+	buf := e.buf[:0]
+	if e.depth == 0 {
+		buf = e.appendTag(buf)
+	}
+	e.depth++
+	e.buf = append(buf, majorArray<<5|infoIndefinite)
+	return e.write(e.buf)
+}
+
+// WriteLit writes the Lit x incrementally.
+func (e *Encoder) WriteLit(x lisp.Lit) error {
+	if e.err != nil {
+		return e.err
+	}
+	if !xlisp.ValidLit(x) {
+		return e.setErr(ErrInvalidLit)
+	}
+	if n := len(e.stack); n > 0 {
+		e.stack[n-1] = append(e.stack[n-1], x)
+		return nil
+	}
+	buf := e.buf[:0]
+	if e.depth == 0 {
+		buf = e.appendTag(buf)
+	}
+	e.buf = appendLit(buf, x)
+	return e.write(e.buf)
+}
+
+// EndGroup closes the innermost Group opened by BeginGroup.
+func (e *Encoder) EndGroup() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.depth == 0 {
+		return e.setErr(ErrUnbalanced)
+	}
+	e.depth--
+	if !e.Deterministic {
+		e.buf = append(e.buf[:0], breakByte)
+		return e.write(e.buf)
+	}
+	n := len(e.stack) - 1
+	g := e.stack[n]
+	e.stack = e.stack[:n]
+	if n > 0 {
+		e.stack[n-1] = append(e.stack[n-1], g)
+		return nil
+	}
+	e.buf = appendArray(e.appendTag(e.buf[:0]), g)
+	return e.write(e.buf)
+}
+
+// Flush flushes buffered output to the underlying writer.
+func (e *Encoder) Flush() error {
+	if err := e.w.Flush(); err != nil {
+		return e.setErr(err)
+	}
+	return e.err
+}
+
+// Close flushes the Encoder and reports an error if any Group remains open.
+func (e *Encoder) Close() error {
+	if err := e.Flush(); err != nil {
+		return err
+	}
+	if e.depth != 0 {
+		return e.setErr(ErrUnbalanced)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+// Package lispcbor implements encoding of Lisp values as CBOR (RFC 8949).
+//
+// Lisp values are mapped to CBOR as follows:
+//
+//	Nat Lit   unsigned integer (major type 0)
+//	Id Lit    text string (major type 3)
+//	Group     array (major type 4)
+//
+// Nat Lits are Lits in canonical decimal form which fit in a uint64.
+//
+// The Decoder maps other CBOR data items to tagged Groups:
+//
+//	negative integer -1-N   (neg M) where M is N+1
+//	byte string             (bytes H) where H is lowercase hexadecimal
+//	float                   (float X) where X is the quoted decimal text
+//	map                     (map (k v) ...)
+//	tag N                   (tag N v)
+//	true, false, null       (true) (false) (null)
+//	undefined               (undefined)
+//	other simple values     (simple N)
+//
+// Text strings which are not valid Lits or which would be read as a Nat
+// are decoded as quoted text (see xlisp.Quote).
+// Arrays whose first element is one of the Lits above, q or array
+// are decoded as (array ...).
+//
+// Encode maps these Groups back to their CBOR data items
+// so CBOR to Lisp to CBOR conversion preserves values.
+// Floats are encoded in the shortest form which preserves their value.
+package lispcbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Major types.
+const (
+	majorUint   = 0
+	majorNeg    = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+const (
+	infoIndefinite = 31
+	breakByte      = 0xff
+)
+
+// maxLen bounds the length of strings accepted by the Decoder.
+const maxLen = 1 << 28
+
+// Options for the Encoder and Decoder.
+type Options struct {
+	// Deterministic uses definite lengths for Groups written incrementally
+	// by buffering them until they are closed (RFC 8949 Section 4.2).
+	// Encode always uses definite lengths and the shortest form of integers
+	// and in Deterministic mode sorts map entries by their encoded keys
+	// and rejects duplicate keys.
+	Deterministic bool
+
+	// TagNum is a tag number wrapping each top-level value when nonzero.
+	// The Decoder removes the tag from top-level values which carry it.
+	TagNum uint64
+}
+
+// appendHead appends the initial byte and argument n in the shortest form.
+func appendHead(buf []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= 0xff:
+		return append(buf, major|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, major|27), n)
+	}
+}
+
+func appendLit(buf []byte, x lisp.Lit) []byte {
+	if n, ok := xlisp.ParseNat(x); ok {
+		return appendHead(buf, majorUint, n)
+	}
+	return appendText(buf, string(x))
+}
+
+func appendText(buf []byte, s string) []byte {
+	buf = appendHead(buf, majorText, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendArray appends the Group g as nested arrays without interpreting tagged Groups.
+//
+// The Lits of g must be valid.
+func appendArray(buf []byte, g lisp.Group) []byte {
+	buf = appendHead(buf, majorArray, uint64(len(g)))
+	for _, x := range g {
+		switch x := x.(type) {
+		case lisp.Lit:
+			buf = appendLit(buf, x)
+		case lisp.Group:
+			buf = appendArray(buf, x)
+		}
+	}
+	return buf
+}
+
+// appendGroup appends the elements of g as an array.
+func appendGroup(buf []byte, g lisp.Group, det bool) ([]byte, error) {
+	buf = appendHead(buf, majorArray, uint64(len(g)))
+	for _, x := range g {
+		var err error
+		if buf, err = appendVal(buf, x, det); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// appendVal appends v mapping tagged Groups to their CBOR data items.
+//
+// When det is set map entries are sorted by their encoded keys.
+func appendVal(buf []byte, v lisp.Val, det bool) ([]byte, error) {
+	switch v := v.(type) {
+	case lisp.Lit:
+		if !xlisp.ValidLit(v) {
+			return nil, ErrInvalidLit
+		}
+		return appendLit(buf, v), nil
+	case lisp.Group:
+		if len(v) == 0 {
+			return appendHead(buf, majorArray, 0), nil
+		}
+		head, _ := v[0].(lisp.Lit)
+		switch head {
+		case quoteLit:
+			s, err := xlisp.Unquote(v)
+			if err != nil {
+				return nil, fmt.Errorf("lispcbor: %w", err)
+			}
+			return appendText(buf, s), nil
+		case arrayLit:
+			return appendGroup(buf, v[1:], det)
+		case negLit:
+			if len(v) != 2 {
+				return nil, fmt.Errorf("lispcbor: malformed (neg M): %v", v)
+			}
+			if v[1] == lisp.Lit("18446744073709551616") {
+				return appendHead(buf, majorNeg, math.MaxUint64), nil
+			}
+			m, ok := v[1].(lisp.Lit)
+			n, nat := xlisp.ParseNat(m)
+			if !ok || !nat || n == 0 {
+				return nil, fmt.Errorf("lispcbor: malformed (neg M): %v", v)
+			}
+			return appendHead(buf, majorNeg, n-1), nil
+		case bytesLit:
+			var b []byte
+			switch len(v) {
+			case 1:
+			case 2:
+				h, ok := v[1].(lisp.Lit)
+				var err error
+				if b, err = hex.DecodeString(string(h)); !ok || err != nil {
+					return nil, fmt.Errorf("lispcbor: malformed (bytes H): %v", v)
+				}
+			default:
+				return nil, fmt.Errorf("lispcbor: malformed (bytes H): %v", v)
+			}
+			buf = appendHead(buf, majorBytes, uint64(len(b)))
+			return append(buf, b...), nil
+		case floatLit:
+			if len(v) != 2 {
+				return nil, fmt.Errorf("lispcbor: malformed (float X): %v", v)
+			}
+			s, err := xlisp.Unquote(v[1])
+			if err != nil {
+				return nil, fmt.Errorf("lispcbor: %w", err)
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("lispcbor: invalid float %q", s)
+			}
+			return appendFloat(buf, f), nil
+		case mapLit:
+			return appendMap(buf, v[1:], det)
+		case tagLit:
+			if len(v) != 3 {
+				return nil, fmt.Errorf("lispcbor: malformed (tag N v): %v", v)
+			}
+			m, _ := v[1].(lisp.Lit)
+			n, ok := xlisp.ParseNat(m)
+			if !ok {
+				return nil, fmt.Errorf("lispcbor: malformed (tag N v): %v", v)
+			}
+			return appendVal(appendHead(buf, majorTag, n), v[2], det)
+		case falseLit, trueLit, nullLit, undefinedLit:
+			if len(v) != 1 {
+				return nil, fmt.Errorf("lispcbor: malformed (%v): %v", head, v)
+			}
+			return append(buf, majorSimple<<5|simpleValues[head]), nil
+		case simpleLit:
+			m, _ := v[len(v)-1].(lisp.Lit)
+			n, ok := xlisp.ParseNat(m)
+			if len(v) != 2 || !ok || n > 0xff || 24 <= n && n < 32 {
+				return nil, fmt.Errorf("lispcbor: malformed (simple N): %v", v)
+			}
+			return appendHead(buf, majorSimple, n), nil
+		}
+		return appendGroup(buf, v, det)
+	default:
+		return nil, fmt.Errorf("lispcbor: unexpected Val %v", v)
+	}
+}
+
+// appendMap appends the (k v) entries as a map.
+//
+// When det is set the entries are sorted by their encoded keys (RFC 8949 Section 4.2.1)
+// and duplicate keys are rejected.
+func appendMap(buf []byte, entries lisp.Group, det bool) ([]byte, error) {
+	type entry struct {
+		key  lisp.Val
+		k, v []byte // Encoded key and value.
+	}
+	es := make([]entry, len(entries))
+	for i, x := range entries {
+		kv, ok := x.(lisp.Group)
+		if !ok || len(kv) != 2 {
+			return nil, fmt.Errorf("lispcbor: malformed map entry: %v", x)
+		}
+		es[i].key = kv[0]
+		var err error
+		if es[i].k, err = appendVal(nil, kv[0], det); err != nil {
+			return nil, err
+		}
+		if es[i].v, err = appendVal(nil, kv[1], det); err != nil {
+			return nil, err
+		}
+	}
+	if det {
+		slices.SortFunc(es, func(a, b entry) int { return bytes.Compare(a.k, b.k) })
+		for i := 1; i < len(es); i++ {
+			if bytes.Equal(es[i-1].k, es[i].k) {
+				return nil, fmt.Errorf("lispcbor: duplicate map key: %v", es[i].key)
+			}
+		}
+	}
+	buf = appendHead(buf, majorMap, uint64(len(es)))
+	for _, e := range es {
+		buf = append(append(buf, e.k...), e.v...)
+	}
+	return buf, nil
+}
+
+// simpleValues maps Lits to the simple values false, true, null and undefined.
+var simpleValues = map[lisp.Lit]byte{
+	falseLit:     20,
+	trueLit:      21,
+	nullLit:      22,
+	undefinedLit: 23,
+}
+
+// appendFloat appends f using the shortest float which preserves its value.
+func appendFloat(buf []byte, f float64) []byte {
+	if math.IsNaN(f) {
+		return append(buf, majorSimple<<5|25, 0x7e, 0x00)
+	}
+	if f32 := float32(f); float64(f32) == f {
+		if h, ok := toFloat16(f32); ok {
+			return binary.BigEndian.AppendUint16(append(buf, majorSimple<<5|25), h)
+		}
+		return binary.BigEndian.AppendUint32(append(buf, majorSimple<<5|26), math.Float32bits(f32))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, majorSimple<<5|27), math.Float64bits(f))
+}
+
+// toFloat16 returns the IEEE 754 half-precision bits of f if f is exactly representable.
+func toFloat16(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	frac := bits & 0x7fffff
+	switch {
+	case exp == 0xff: // Inf.
+		return sign | 0x7c00, frac == 0
+	case exp == 0 && frac == 0: // Zero.
+		return sign, true
+	case exp == 0: // Float32 subnormals are too small.
+		return 0, false
+	}
+	switch e := exp - 127; {
+	case -14 <= e && e <= 15:
+		return sign | uint16(e+15)<<10 | uint16(frac>>13), frac&0x1fff == 0
+	case -24 <= e && e < -14:
+		mant := 1<<23 | frac
+		shift := -(e + 1)
+		return sign | uint16(mant>>shift), mant&(1<<shift-1) == 0
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,363 @@
+package lispcbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/ajzaff/lisp/x/fuzzutil"
+	"github.com/ajzaff/lisp/x/print"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParse(t *testing.T, src string) (val lisp.Val) {
+	t.Helper()
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		val = n.Val
+		break
+	}
+	if err := sc.Err(); err != nil {
+		panic(fmt.Sprintf("mustParse: failed to parse: %q: %v", src, err))
+	}
+	return val
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Assert the Encoder implements the streaming interface.
+var _ print.StreamWriter = (*Encoder)(nil)
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{{
+		name:  "Nat",
+		input: "1",
+		want:  "01",
+	}, {
+		name:  "Nat uses shortest form",
+		input: "500",
+		want:  "19 01f4",
+	}, {
+		name:  "max uint64",
+		input: "18446744073709551615",
+		want:  "1b ffffffffffffffff",
+	}, {
+		name:  "Nat with leading zeros uses text",
+		input: "01",
+		want:  "62 3031",
+	}, {
+		name:  "Id",
+		input: "abc",
+		want:  "63 616263",
+	}, {
+		name:  "Group",
+		input: "(a 1 ())",
+		want:  "83 6161 01 80",
+	}, {
+		name:  "tag",
+		input: "(a)",
+		opts:  Options{TagNum: 55799},
+		want:  "d9d9f7 81 6161",
+	}, {
+		name:  "map keeps order",
+		input: "(map (b 1) (a 2))",
+		want:  "a2 6162 01 6161 02",
+	}, {
+		name:  "deterministic map",
+		input: "(map (b 1) (a 2))",
+		opts:  Options{Deterministic: true},
+		want:  "a2 6161 02 6162 01",
+	}, {
+		name:  "deterministic sorted map",
+		input: "(map (a 2) (b 1))",
+		opts:  Options{Deterministic: true},
+		want:  "a2 6161 02 6162 01",
+	}, {
+		name:  "deterministic map sorts encoded keys",
+		input: "(map (aa 1) (b 2) (10 3) (() ()))",
+		opts:  Options{Deterministic: true},
+		want:  "a4 0a 03 6162 02 626161 01 80 80",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := NewEncoder(&buf, tc.opts)
+			if err := e.Encode(mustParse(t, tc.input)); err != nil {
+				t.Fatalf("Encode(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(mustHex(tc.want), buf.Bytes()); diff != "" {
+				t.Errorf("Encode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func writeStream(e *Encoder) error {
+	e.BeginGroup()
+	e.WriteLit("a")
+	e.BeginGroup()
+	e.WriteLit("1")
+	e.EndGroup()
+	e.EndGroup()
+	e.WriteLit("b")
+	return e.Close()
+}
+
+func TestEncoderStream(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts Options
+		want string
+	}{{
+		name: "indefinite",
+		want: "9f 6161 9f 01 ff ff 6162",
+	}, {
+		name: "deterministic",
+		opts: Options{Deterministic: true},
+		want: "82 6161 81 01 6162",
+	}, {
+		name: "deterministic tag",
+		opts: Options{Deterministic: true, TagNum: 1000},
+		want: "d903e8 82 6161 81 01 d903e8 6162",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := NewEncoder(&buf, tc.opts)
+			if err := writeStream(e); err != nil {
+				t.Fatalf("Close(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(mustHex(tc.want), buf.Bytes()); diff != "" {
+				t.Errorf("Encode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+			d := NewDecoder(&buf, tc.opts)
+			got := slices.Collect(d.Values())
+			if err := d.Err(); err != nil {
+				t.Fatalf("Decode(%q): got err = %v", tc.name, err)
+			}
+			want := []lisp.Val{mustParse(t, "(a (1))"), lisp.Lit("b")}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("Decode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestEncoderStreamErrors(t *testing.T) {
+	e := NewEncoder(io.Discard, Options{})
+	if err := e.EndGroup(); err != ErrUnbalanced {
+		t.Errorf("EndGroup(): got err = %v, want %v", err, ErrUnbalanced)
+	}
+	e = NewEncoder(io.Discard, Options{})
+	if err := e.WriteLit("a b"); err != ErrInvalidLit {
+		t.Errorf("WriteLit(): got err = %v, want %v", err, ErrInvalidLit)
+	}
+	e = NewEncoder(io.Discard, Options{})
+	e.BeginGroup()
+	if err := e.Close(); err != ErrUnbalanced {
+		t.Errorf("Close(): got err = %v, want %v", err, ErrUnbalanced)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{
+		{name: "negative", input: "20", want: "(neg 1)"},
+		{name: "min negative", input: "3b ffffffffffffffff", want: "(neg 18446744073709551616)"},
+		{name: "empty bytes", input: "40", want: "(bytes)"},
+		{name: "bytes", input: "43 010aff", want: "(bytes 010aff)"},
+		{name: "text", input: "63 612062", want: "(q a(u 32)b)"},
+		{name: "indefinite text", input: "7f 6161 6162 ff", want: "ab"},
+		{name: "indefinite bytes", input: "5f 4101 4102 ff", want: "(bytes 0102)"},
+		{name: "half float", input: "f9 3c00", want: "(float 1)"},
+		{name: "float", input: "fb 3ff8000000000000", want: "(float (q 1(u 46)5))"},
+		{name: "simple values", input: "84 f4 f5 f6 f7", want: "((false) (true) (null) (undefined))"},
+		{name: "simple", input: "f0", want: "(simple 16)"},
+		{name: "map", input: "a2 6161 01 6162 80", want: "(map (a 1) (b ()))"},
+		{name: "indefinite map", input: "bf 6161 01 ff", want: "(map (a 1))"},
+		{name: "foreign tag", input: "c1 01", want: "(tag 1 1)"},
+		{name: "tag removed", input: "d9d9f7 01", opts: Options{TagNum: 55799}, want: "1"},
+		{name: "nested tag kept", input: "81 d9d9f7 01", opts: Options{TagNum: 55799}, want: "((tag 55799 1))"},
+		{name: "Nat text is quoted", input: "62 3132", want: "(q 12)"},
+		{name: "reserved head array", input: "82 636e6567 01", want: "(array neg 1)"},
+		{name: "single float", input: "fa 3dcccccd", want: "(float (q 0(u 46)10000000149011612))"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDecoder(bytes.NewReader(mustHex(tc.input)), tc.opts)
+			got, err := d.Decode()
+			if err != nil {
+				t.Fatalf("Decode(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(mustParse(t, tc.want), got); diff != "" {
+				t.Errorf("Decode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+			if _, err := d.Decode(); err != io.EOF {
+				t.Errorf("Decode(%q): got err = %v, want EOF", tc.name, err)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{"truncated array", "82 01"},
+		{"truncated text", "63 61"},
+		{"truncated head", "19 01"},
+		{"stray break", "ff"},
+		{"reserved info", "1c"},
+		{"indefinite integer", "1f"},
+		{"bad chunk", "7f 01 ff"},
+		{"map missing value", "bf 01 ff"},
+		{"invalid UTF-8", "61 ff"},
+		{"huge text", "7a 0f ff ff ff 61"},
+		{"huge text chunk", "7f 7a 0f ff ff ff 61"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDecoder(bytes.NewReader(mustHex(tc.input)), Options{})
+			if _, err := d.Decode(); err == nil || err == io.EOF {
+				t.Errorf("Decode(%q): got err = %v, want err", tc.name, err)
+			}
+		})
+	}
+}
+
+func TestDecodeHugeLength(t *testing.T) {
+	for _, input := range []string{"7a 0f ff ff ff 61", "7f 7a 0f ff ff ff 61"} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		d := NewDecoder(bytes.NewReader(mustHex(input)), Options{})
+		_, err := d.Decode()
+		runtime.ReadMemStats(&after)
+		if err == nil {
+			t.Errorf("Decode(%q): got err = nil, want err", input)
+		}
+		if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+			t.Errorf("Decode(%q): allocated %d bytes for a truncated string", input, n)
+		}
+	}
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{"negative", "20"},
+		{"large negative", "3b 00000001 00000000"},
+		{"min negative", "3b ffffffffffffffff"},
+		{"empty bytes", "40"},
+		{"bytes", "43 010aff"},
+		{"text", "63 612062"},
+		{"empty text", "60"},
+		{"Nat text", "62 3132"},
+		{"half float", "f9 3c00"},
+		{"half subnormal", "f9 0001"},
+		{"half infinity", "f9 fc00"},
+		{"half NaN", "f9 7e00"},
+		{"single float", "fa 3dcccccd"},
+		{"double float", "fb 3fb999999999999a"},
+		{"simple values", "84 f4 f5 f6 f7"},
+		{"simple", "f0"},
+		{"simple byte", "f8 ff"},
+		{"map", "a2 6161 01 6162 80"},
+		{"tag", "c1 01"},
+		{"nested", "82 a1 20 c2 41ff 81 f5"},
+		{"reserved head array", "82 636e6567 01"},
+		{"quote head array", "81 6171"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			input := mustHex(tc.input)
+			d := NewDecoder(bytes.NewReader(input), Options{})
+			v, err := d.Decode()
+			if err != nil {
+				t.Fatalf("Decode(%q): got err = %v", tc.name, err)
+			}
+			var buf bytes.Buffer
+			if err := NewEncoder(&buf, Options{}).Encode(v); err != nil {
+				t.Fatalf("Encode(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(input, buf.Bytes()); diff != "" {
+				t.Errorf("Encode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input lisp.Val
+	}{
+		{"invalid Lit", lisp.Lit("a b")},
+		{"invalid Lit in Group", lisp.Group{lisp.Lit("")}},
+		{"nil in Group", lisp.Group{nil}},
+		{"neg zero", mustParse(t, "(neg 0)")},
+		{"neg missing", mustParse(t, "(neg)")},
+		{"bytes not hex", mustParse(t, "(bytes xyz)")},
+		{"float not a number", mustParse(t, "(float abc)")},
+		{"map entry", mustParse(t, "(map a)")},
+		{"tag missing value", mustParse(t, "(tag 1)")},
+		{"true with args", mustParse(t, "(true 1)")},
+		{"reserved simple", mustParse(t, "(simple 24)")},
+		{"bad quote", mustParse(t, "(q (x))")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := NewEncoder(io.Discard, Options{}).Encode(tc.input); err == nil {
+				t.Errorf("Encode(%q): got err = nil, want err", tc.name)
+			}
+		})
+	}
+	e := NewEncoder(io.Discard, Options{Deterministic: true})
+	if err := e.Encode(mustParse(t, "(map (a 1) (b 2) (a 3))")); err == nil {
+		t.Errorf("Encode(duplicate key): got err = nil, want err")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 5
+	var want []lisp.Val
+	for range 100 {
+		want = append(want, g.Next())
+	}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, Options{TagNum: 55799})
+	for _, v := range want {
+		if err := e.Encode(v); err != nil {
+			t.Fatalf("Encode(): got err = %v", err)
+		}
+	}
+	d := NewDecoder(&buf, Options{TagNum: 55799})
+	got := slices.Collect(d.Values())
+	if err := d.Err(); err != nil {
+		t.Fatalf("Decode(): got err = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Decode(): got diff (-want, +got):\n%v", diff)
+	}
+}
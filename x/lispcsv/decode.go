@@ -0,0 +1,88 @@
+package lispcsv
+
+import (
+	"encoding/csv"
+	"io"
+	"iter"
+
+	"github.com/ajzaff/lisp"
+)
+
+// Decoder reads CSV records as Lisp rows.
+type Decoder struct {
+	Options
+
+	r      recordReader
+	header lisp.Group
+	err    error
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader, opts Options) *Decoder {
+	if opts.Comma == '\t' {
+		return &Decoder{Options: opts, r: newTSVReader(r)}
+	}
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.ReuseRecord = true
+	return &Decoder{Options: opts, r: cr}
+}
+
+// Names returns the header names read by the Decoder or nil
+// if Header is not set or no header was read.
+func (d *Decoder) Names() lisp.Group { return d.header }
+
+// Decode decodes the next row.
+//
+// Decode returns io.EOF when no more rows are available.
+func (d *Decoder) Decode() (lisp.Val, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.Header && d.header == nil {
+		rec, err := d.r.Read()
+		if err != nil {
+			return nil, d.setErr(err)
+		}
+		d.header = quoteFields(rec)
+	}
+	rec, err := d.r.Read()
+	if err != nil {
+		return nil, d.setErr(err)
+	}
+	row := quoteFields(rec)
+	if d.header == nil {
+		return row, nil
+	}
+	g := make(lisp.Group, len(row))
+	for i, x := range row {
+		g[i] = lisp.Group{d.header[i], x}
+	}
+	return g, nil
+}
+
+func (d *Decoder) setErr(err error) error {
+	if err != io.EOF {
+		d.err = err
+	}
+	return err
+}
+
+// Values returns an iteration over the decoded rows.
+//
+// Errors are reported by Err.
+func (d *Decoder) Values() iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		for {
+			v, err := d.Decode()
+			if err != nil || !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the first error encountered by the Decoder other than io.EOF.
+func (d *Decoder) Err() error { return d.err }
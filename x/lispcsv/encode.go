@@ -0,0 +1,130 @@
+package lispcsv
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/ajzaff/lisp"
+)
+
+// Encoder writes Lisp rows as CSV records.
+type Encoder struct {
+	Options
+
+	w       recordWriter
+	header  []string
+	started bool // Whether the first record was written.
+	n       int  // Fields per record.
+	rec     []string
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer, opts Options) *Encoder {
+	if opts.Comma == '\t' {
+		return &Encoder{Options: opts, w: newTSVWriter(w)}
+	}
+	cw := newCSVWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	return &Encoder{Options: opts, w: cw}
+}
+
+// csvWriter is a csv.Writer which quotes a record with one empty field.
+//
+// csv.Writer writes such a record as an empty line which csv.Reader skips.
+type csvWriter struct {
+	*csv.Writer
+	w *bufio.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	bw := bufio.NewWriter(w)
+	return &csvWriter{Writer: csv.NewWriter(bw), w: bw}
+}
+
+func (w *csvWriter) Write(record []string) error {
+	if len(record) != 1 || record[0] != "" {
+		return w.Writer.Write(record)
+	}
+	w.Writer.Flush()
+	if err := w.Writer.Error(); err != nil {
+		return err
+	}
+	_, err := w.w.WriteString("\"\"\n")
+	return err
+}
+
+func (w *csvWriter) Flush() {
+	w.Writer.Flush()
+	w.w.Flush()
+}
+
+func (w *csvWriter) Error() error {
+	if err := w.Writer.Error(); err != nil {
+		return err
+	}
+	// Flush the buffer again to report its sticky error.
+	return w.w.Flush()
+}
+
+// Encode writes the row v as a CSV record.
+//
+// Records are buffered until Flush or Close is called.
+// Every row must have the same number of fields as the first.
+// When Header is set, a header record is written before the first row
+// and every row must have the same names in the same order.
+func (e *Encoder) Encode(v lisp.Val) error {
+	row, ok := v.(lisp.Group)
+	if !ok {
+		return fmt.Errorf("lispcsv: expected row Group, got %v", v)
+	}
+	if e.started && len(row) != e.n {
+		return fmt.Errorf("lispcsv: expected %d fields, got %d", e.n, len(row))
+	}
+	e.rec = e.rec[:0]
+	if !e.started {
+		e.header = e.header[:0]
+	}
+	for i, x := range row {
+		if e.Header {
+			kv, ok := x.(lisp.Group)
+			if !ok || len(kv) != 2 {
+				return fmt.Errorf("lispcsv: malformed field: %v", x)
+			}
+			k, err := unquote(kv[0])
+			if err != nil {
+				return err
+			}
+			if !e.started {
+				e.header = append(e.header, k)
+			} else if k != e.header[i] {
+				return fmt.Errorf("lispcsv: expected field %q, got %q", e.header[i], k)
+			}
+			x = kv[1]
+		}
+		s, err := unquote(x)
+		if err != nil {
+			return err
+		}
+		e.rec = append(e.rec, s)
+	}
+	if !e.started && e.Header {
+		if err := e.w.Write(e.header); err != nil {
+			return err
+		}
+	}
+	e.started, e.n = true, len(row)
+	return e.w.Write(e.rec)
+}
+
+// Flush flushes buffered records to the underlying writer.
+func (e *Encoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// Close flushes the Encoder.
+func (e *Encoder) Close() error { return e.Flush() }
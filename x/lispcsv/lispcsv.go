@@ -0,0 +1,51 @@
+// Package lispcsv implements conversion between CSV and Lisp rows.
+//
+// Each CSV record is mapped to a row Group of fields:
+//
+//	a,b c,d   (a (q b(u 32)c) d)
+//
+// Fields are quoted using xlisp.Quote so that any field text is preserved.
+// When Header is set the first record names the fields and each following
+// record is mapped to a Group of (name field) pairs:
+//
+//	x,y
+//	1,2       ((x 1) (y 2))
+//
+// The Encoder writes rows of uniform shape back to CSV.
+package lispcsv
+
+import (
+	"fmt"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Options for the Encoder and Decoder.
+type Options struct {
+	// Comma is the field delimiter. Use '\t' for TSV.
+	// Comma defaults to ','.
+	// TSV records are split on newlines and tabs without quoting,
+	// so the Encoder rejects TSV fields containing tabs or newlines.
+	Comma rune
+
+	// Header maps records to Groups of (name field) pairs
+	// using the names from the header record.
+	Header bool
+}
+
+func quoteFields(fields []string) lisp.Group {
+	g := make(lisp.Group, len(fields))
+	for i, f := range fields {
+		g[i] = xlisp.Quote(f)
+	}
+	return g
+}
+
+func unquote(v lisp.Val) (string, error) {
+	s, err := xlisp.Unquote(v)
+	if err != nil {
+		return "", fmt.Errorf("lispcsv: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,233 @@
+package lispcsv
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParseMultiple(t *testing.T, src string) []lisp.Val {
+	t.Helper()
+	var vs []lisp.Val
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		vs = append(vs, n.Val)
+	}
+	if err := sc.Err(); err != nil {
+		panic(fmt.Sprintf("mustParse: failed to parse: %q: %v", src, err))
+	}
+	return vs
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name:  "rows",
+		input: "a,b\n1,2\n",
+		want:  "(a b) (1 2)",
+	}, {
+		name:  "quoted fields",
+		input: "\"x, y\",,\"a\"\"b\"\n",
+		want:  "((q x(u 44)(u 32)y) (q) (q a(u 34)b))",
+	}, {
+		name:  "TSV",
+		input: "a b\tc\n",
+		opts:  Options{Comma: '\t'},
+		want:  "((q a(u 32)b) c)",
+	}, {
+		name:  "TSV bare quote",
+		input: "5\" pipe\ta\"b\n",
+		opts:  Options{Comma: '\t'},
+		want:  "((q 5(u 34)(u 32)pipe) (q a(u 34)b))",
+	}, {
+		name:  "TSV leading quote",
+		input: "\"quoted\" word\tx\r\n\n\"a\tb\n",
+		opts:  Options{Comma: '\t'},
+		want:  "((q (u 34)quoted(u 34)(u 32)word) x) ((q (u 34)a) b)",
+	}, {
+		name:  "TSV single column empty field",
+		input: "a\n\nb\n",
+		opts:  Options{Comma: '\t'},
+		want:  "(a) ((q)) (b)",
+	}, {
+		name:  "TSV leading empty lines",
+		input: "\n\na\tb\nc\td\n",
+		opts:  Options{Comma: '\t'},
+		want:  "(a b) (c d)",
+	}, {
+		name:  "header",
+		input: "name,age\nann,30\nbob,41\n",
+		opts:  Options{Header: true},
+		want:  "((name ann) (age 30)) ((name bob) (age 41))",
+	}, {
+		name:  "header only",
+		input: "name,age\n",
+		opts:  Options{Header: true},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tc.input), tc.opts)
+			got := slices.Collect(d.Values())
+			if err := d.Err(); err != nil {
+				t.Fatalf("Decode(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(mustParseMultiple(t, tc.want), got); diff != "" {
+				t.Errorf("Decode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestDecodeRaggedRows(t *testing.T) {
+	for _, comma := range []rune{',', '\t'} {
+		input := "a" + string(comma) + "b\n1\n"
+		d := NewDecoder(strings.NewReader(input), Options{Comma: comma})
+		for range d.Values() {
+		}
+		if d.Err() == nil {
+			t.Errorf("Decode(%q): got err = nil, want err", input)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+	}{
+		{"CSV single column empty field", "((q)) (a) ((q))", Options{}},
+		{"TSV single column empty field", "(a) ((q)) ((q))", Options{Comma: '\t'}},
+		{"TSV empty fields", "((q) (q)) (a b)", Options{Comma: '\t'}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			want := mustParseMultiple(t, tc.input)
+			var sb strings.Builder
+			e := NewEncoder(&sb, tc.opts)
+			for _, v := range want {
+				if err := e.Encode(v); err != nil {
+					t.Fatalf("Encode(%q): got err = %v", tc.name, err)
+				}
+			}
+			if err := e.Close(); err != nil {
+				t.Fatalf("Close(%q): got err = %v", tc.name, err)
+			}
+			d := NewDecoder(strings.NewReader(sb.String()), tc.opts)
+			got := slices.Collect(d.Values())
+			if err := d.Err(); err != nil {
+				t.Fatalf("Decode(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("Decode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{{
+		name:  "rows",
+		input: "(a b) (1 2)",
+		want:  "a,b\n1,2\n",
+	}, {
+		name:  "quoted fields",
+		input: "((q x(u 44)(u 32)y) (q) (q a(u 34)b))",
+		want:  "\"x, y\",,\"a\"\"b\"\n",
+	}, {
+		name:  "TSV",
+		input: "((q a(u 32)b) c)",
+		opts:  Options{Comma: '\t'},
+		want:  "a b\tc\n",
+	}, {
+		name:  "TSV is not quoted",
+		input: "((q (u 34)a(u 32)b) (q c(u 44)d))",
+		opts:  Options{Comma: '\t'},
+		want:  "\"a b\tc,d\n",
+	}, {
+		name:  "header",
+		input: "((name ann) (age 30)) ((name bob) (age 41))",
+		opts:  Options{Header: true},
+		want:  "name,age\nann,30\nbob,41\n",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			e := NewEncoder(&sb, tc.opts)
+			for _, v := range mustParseMultiple(t, tc.input) {
+				if err := e.Encode(v); err != nil {
+					t.Fatalf("Encode(%q): got err = %v", tc.name, err)
+				}
+			}
+			if err := e.Close(); err != nil {
+				t.Fatalf("Close(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(tc.want, sb.String()); diff != "" {
+				t.Errorf("Encode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestEncodeFlush(t *testing.T) {
+	var sb strings.Builder
+	e := NewEncoder(&sb, Options{})
+	for _, v := range mustParseMultiple(t, "(a b) (1 2)") {
+		if err := e.Encode(v); err != nil {
+			t.Fatalf("Encode(): got err = %v", err)
+		}
+	}
+	if sb.Len() != 0 {
+		t.Errorf("Encode(): got %q before Flush, want buffered output", sb.String())
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Flush(): got err = %v", err)
+	}
+	if want := "a,b\n1,2\n"; sb.String() != want {
+		t.Errorf("Flush(): got %q, want %q", sb.String(), want)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+	}{
+		{"not a row", "a", Options{}},
+		{"nested Group", "(a (b))", Options{}},
+		{"ragged rows", "(a b) (1)", Options{}},
+		{"malformed field", "(a)", Options{Header: true}},
+		{"mismatched names", "((x 1)) ((y 2))", Options{Header: true}},
+		{"TSV field with tab", "((q a(u 9)b))", Options{Comma: '\t'}},
+		{"TSV first record empty field", "((q)) (a)", Options{Comma: '\t'}},
+		{"TSV header empty name", "(((q) a))", Options{Comma: '\t', Header: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEncoder(&strings.Builder{}, tc.opts)
+			var err error
+			for _, v := range mustParseMultiple(t, tc.input) {
+				if err = e.Encode(v); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				t.Errorf("Encode(%q): got err = nil, want err", tc.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package lispcsv
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// recordReader is implemented by csv.Reader and tsvReader.
+type recordReader interface {
+	Read() ([]string, error)
+}
+
+// recordWriter is implemented by csv.Writer and tsvWriter.
+type recordWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+// tsvReader reads TSV records.
+//
+// Records are split on newlines and fields on tabs without quote handling.
+// Every record must have the same number of fields as the first.
+// Empty lines before the first record are skipped. After the first record,
+// an empty line is a record with one empty field if records have a single field
+// and is skipped otherwise.
+type tsvReader struct {
+	r    *bufio.Reader
+	line int
+	n    int // Fields per record or 0 before the first record.
+	rec  []string
+}
+
+func newTSVReader(r io.Reader) *tsvReader { return &tsvReader{r: bufio.NewReader(r)} }
+
+// Read returns the next record reusing the slice returned by the previous call.
+func (r *tsvReader) Read() ([]string, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		r.line++
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+		if len(line) == 0 && r.n != 1 {
+			continue
+		}
+		r.rec = append(r.rec[:0], strings.Split(string(line), "\t")...)
+		if r.n == 0 {
+			r.n = len(r.rec)
+		} else if len(r.rec) != r.n {
+			return nil, fmt.Errorf("lispcsv: record on line %d: wrong number of fields", r.line)
+		}
+		return r.rec, nil
+	}
+}
+
+// errTSVField is returned when a TSV field contains a tab or newline.
+var errTSVField = errors.New("lispcsv: TSV field contains tab or newline")
+
+// errTSVEmptyFirst is returned when the first TSV record is a single empty field.
+//
+// The record would be written as an empty line which tsvReader skips.
+var errTSVEmptyFirst = errors.New("lispcsv: first TSV record is a single empty field")
+
+// tsvWriter writes TSV records.
+type tsvWriter struct {
+	w       *bufio.Writer
+	started bool // Whether the first record was written.
+	err     error
+}
+
+func newTSVWriter(w io.Writer) *tsvWriter { return &tsvWriter{w: bufio.NewWriter(w)} }
+
+func (w *tsvWriter) Write(record []string) error {
+	if !w.started && len(record) == 1 && record[0] == "" {
+		return errTSVEmptyFirst
+	}
+	w.started = true
+	for i, f := range record {
+		if strings.ContainsAny(f, "\t\r\n") {
+			return errTSVField
+		}
+		if i > 0 {
+			w.w.WriteByte('\t')
+		}
+		w.w.WriteString(f)
+	}
+	_, err := w.w.WriteString("\n")
+	return err
+}
+
+func (w *tsvWriter) Flush() { w.err = w.w.Flush() }
+
+func (w *tsvWriter) Error() error { return w.err }
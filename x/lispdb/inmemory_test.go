@@ -46,6 +46,10 @@ func TestGenericStoreMultipleInMemory(t *testing.T) {
 		name:    "1{3}",
 		input:   mustParseMultiple(t, "1 1 1"),
 		wantLen: 1,
+	}, {
+		name:    "Group with nil",
+		input:   []lisp.Val{lisp.Group{lisp.Lit("a"), nil, lisp.Group{nil}}},
+		wantLen: 3,
 	}} {
 		t.Run(tc.name, func(t *testing.T) {
 			db := NewInMemory()
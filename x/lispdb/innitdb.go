@@ -19,9 +19,6 @@ type LoadInterface interface {
 }
 
 func Load(db LoadInterface, v lisp.Val) float64 {
-	var h hash.MapHash
-	h.SetSeed(db.Seed())
-	h.WriteVal(v)
-	_, w := db.Load(h.Sum64())
+	_, w := db.Load(hash.SumVal(db.Seed(), v))
 	return w
 }
@@ -118,10 +118,7 @@ func Query(db QueryInterface, q string) *QueryResult {
 	if len(qv) != 1 {
 		panic("union of multiple query expressions is not yet supported")
 	}
-	var h hash.MapHash
-	h.SetSeed(db.Seed())
-	qh := h.Sum64()
-	h.WriteVal(qv[0])
+	qh := hash.SumVal(db.Seed(), qv[0])
 	if _, w := db.Load(qh); w > 0 {
 		// Exact match.
 		r.matches = [][]ID{{qh}}
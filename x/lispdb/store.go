@@ -2,7 +2,6 @@ package lispdb
 
 import (
 	"github.com/ajzaff/lisp"
-	"github.com/ajzaff/lisp/visit"
 	"github.com/ajzaff/lisp/x/hash"
 )
 
@@ -22,46 +21,26 @@ type TVal struct {
 //
 // Store writes to s in a single transaction built in memory.
 func Store(s StoreInterface, vals []lisp.Val, w float64) error {
-	var (
-		stack []*TVal
-		t     []*TVal
-		h     hash.MapHash
-		v     visit.Visitor
-	)
-	h.SetSeed(s.Seed())
-
-	v.SetBeforeGroupVisitor(func(e lisp.Group) {
-		h.Reset()
-		h.WriteVal(e)
-		id := h.Sum64()
-		entry := &TVal{ID: id}
-		if len(stack) > 0 {
-			stack[len(stack)-1].Refs = append(stack[len(stack)-1].Refs, id)
-			entry.InverseRefs = append(entry.InverseRefs, stack[len(stack)-1].ID)
-		}
-		stack = append(stack, entry)
-	})
-	v.SetAfterGroupVisitor(func(e lisp.Group) {
-		entry := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-		t = append(t, entry)
-	})
-	v.SetLitVisitor(func(e lisp.Lit) {
-		h.Reset()
-		h.WriteVal(e)
-		id := h.Sum64()
-		entry := &TVal{ID: id, Lit: e}
-		if len(stack) > 0 {
-			stack[len(stack)-1].Refs = append(stack[len(stack)-1].Refs, id)
-			entry.InverseRefs = append(entry.InverseRefs, stack[len(stack)-1].ID)
-		}
-		t = append(t, entry)
-	})
+	var t []*TVal
 	for _, val := range vals {
-		v.Visit(val)
-		if len(stack) > 0 {
-			panic("internal error: stack not empty after Visit")
+		subs := hash.Subtrees(s.Seed(), val)
+		entries := make([]*TVal, len(subs))
+		for i, st := range subs {
+			entries[i] = &TVal{ID: st.ID}
+			if x, ok := st.Val.(lisp.Lit); ok {
+				entries[i].Lit = x
+			}
+		}
+		// Subtrees are in post-order so Refs are appended in element order.
+		for i, st := range subs {
+			if st.Parent < 0 {
+				continue
+			}
+			parent := entries[st.Parent]
+			parent.Refs = append(parent.Refs, st.ID)
+			entries[i].InverseRefs = append(entries[i].InverseRefs, parent.ID)
 		}
+		t = append(t, entries...)
 	}
 	return s.Store(t, w)
 }
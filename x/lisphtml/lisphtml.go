@@ -0,0 +1,61 @@
+// Package lisphtml renders Lisp markup as HTML5 and parses HTML into Lisp markup.
+//
+// Markup uses the same shape as package lispxml:
+//
+//	(name (attr (a v) ...) children ...)   Element
+//	text                                   Quoted text (see xlisp.Quote)
+//	(comment text)                         <!--text-->
+//	(directive text)                       <!text> such as <!DOCTYPE html>
+//	(raw text)                             Text written without escaping
+//
+// Elements named by one of the reserved Lits use the quoted form (q name) as the head.
+//
+// Render is safe by default: text and attribute values are escaped,
+// names are checked and only elements in an allow-list of common
+// text, structure, table and media elements are written.
+// Script and style elements, event handler, srcdoc and style attributes,
+// javascript:, vbscript: and data: URLs, http-equiv refresh and raw nodes
+// are rejected unless enabled in Options.
+//
+// Parse and ParseFragment use the HTML5 parsing algorithm.
+package lisphtml
+
+import (
+	"io"
+
+	"github.com/ajzaff/lisp"
+)
+
+// Reserved Lits used by the markup.
+const (
+	quoteLit     lisp.Lit = "q"
+	attrLit      lisp.Lit = "attr"
+	commentLit   lisp.Lit = "comment"
+	piLit        lisp.Lit = "pi"
+	directiveLit lisp.Lit = "directive"
+	rawLit       lisp.Lit = "raw"
+)
+
+// Options for Render.
+type Options struct {
+	// AllowScript permits script and style elements, event handler,
+	// srcdoc and style attributes, javascript:, vbscript: and data: URLs
+	// and http-equiv refresh.
+	AllowScript bool
+
+	// AllowRaw permits (raw text) nodes.
+	AllowRaw bool
+
+	// Elements lists element names permitted in addition to the default allow-list.
+	// Names are matched case insensitively.
+	Elements []string
+}
+
+// Render writes the HTML for v to w.
+func Render(w io.Writer, v lisp.Val, opts Options) error {
+	r := newRenderer(w, opts)
+	if err := r.render(v); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
@@ -0,0 +1,189 @@
+package lisphtml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParseMultiple(t *testing.T, src string) []lisp.Val {
+	t.Helper()
+	var vs []lisp.Val
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		vs = append(vs, n.Val)
+	}
+	if err := sc.Err(); err != nil {
+		panic(fmt.Sprintf("mustParse: failed to parse: %q: %v", src, err))
+	}
+	return vs
+}
+
+func TestRender(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{{
+		name:  "element",
+		input: "(p (attr (class x)) hello (b world))",
+		want:  `<p class="x">hello<b>world</b></p>`,
+	}, {
+		name:  "escaped text",
+		input: "(p (q (u 60)b(u 62)(u 38)))",
+		want:  `<p>&lt;b&gt;&amp;</p>`,
+	}, {
+		name:  "escaped attribute",
+		input: "(a (attr (title (q (u 34)(u 62)x))))",
+		want:  `<a title="&#34;&gt;x"></a>`,
+	}, {
+		name:  "void elements",
+		input: "(p a (br) (img (attr (src (q x(u 46)png)))))",
+		want:  `<p>a<br><img src="x.png"></p>`,
+	}, {
+		name:  "doctype and comment",
+		input: "(html (directive (q DOCTYPE(u 32)html)) (comment note))",
+		want:  `<html><!DOCTYPE html><!--note--></html>`,
+	}, {
+		name:  "reserved element name",
+		input: "((q raw) x)",
+		opts:  Options{Elements: []string{"raw"}},
+		want:  `<raw>x</raw>`,
+	}, {
+		name:  "allowed element",
+		input: "(IFRAME (attr (src (q https(u 58)(u 47)(u 47)x))))",
+		opts:  Options{Elements: []string{"iframe"}},
+		want:  `<IFRAME src="https://x"></IFRAME>`,
+	}, {
+		name:  "data URL allowed",
+		input: "(img (attr (src (q data(u 58)x))))",
+		opts:  Options{AllowScript: true},
+		want:  `<img src="data:x">`,
+	}, {
+		name:  "style attribute allowed",
+		input: "(p (attr (style (q color(u 58)red))) x)",
+		opts:  Options{AllowScript: true},
+		want:  `<p style="color:red">x</p>`,
+	}, {
+		name:  "raw allowed",
+		input: "(p (raw (q (u 60)hr(u 62))))",
+		opts:  Options{AllowRaw: true},
+		want:  `<p><hr></p>`,
+	}, {
+		name:  "script allowed",
+		input: "(script (q a(u 60)b))",
+		opts:  Options{AllowScript: true},
+		want:  `<script>a<b</script>`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := Render(&sb, mustParseMultiple(t, tc.input)[0], tc.opts); err != nil {
+				t.Fatalf("Render(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(tc.want, sb.String()); diff != "" {
+				t.Errorf("Render(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+	}{
+		{"script", "(script x)", Options{}},
+		{"style", "(STYLE x)", Options{}},
+		{"event handler", "(a (attr (onclick x)))", Options{}},
+		{"srcdoc", "(iframe (attr (srcdoc x)))", Options{Elements: []string{"iframe"}}},
+		{"style attribute", "(p (attr (style (q color(u 58)red))))", Options{}},
+		{"style attribute upper case", "(p (attr (STYLE x)))", Options{}},
+		{"javascript URL", "(a (attr (href (q (u 32)JavaScript(u 58)x))))", Options{}},
+		{"object data URL", "(object (attr (data (q javascript(u 58)x))))", Options{Elements: []string{"object"}}},
+		{"data URL", "(img (attr (src (q data(u 58)x))))", Options{}},
+		{"srcset data URL", "(img (attr (srcset (q a(u 32)1x(u 44)(u 32)DATA(u 58)x(u 32)2x))))", Options{}},
+		{"meta refresh", "(meta (attr ((q http(u 45)equiv) (q (u 32)Refresh)) (content x)))", Options{Elements: []string{"meta"}}},
+		{"object", "(object)", Options{}},
+		{"embed", "(embed)", Options{}},
+		{"iframe", "(iframe)", Options{}},
+		{"base", "(base)", Options{}},
+		{"unknown element", "(blink x)", Options{}},
+		{"raw", "(raw x)", Options{}},
+		{"script end tag", "(script (q (u 60)(u 47)script(u 62)))", Options{AllowScript: true}},
+		{"invalid element name", "((q a(u 32)b))", Options{}},
+		{"invalid attribute name", "(a (attr ((q x(u 34)) 1)))", Options{}},
+		{"void element with children", "(br x)", Options{}},
+		{"comment end", "(comment (q a(u 45)(u 45)(u 62)))", Options{}},
+		{"empty Group", "()", Options{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := Render(&sb, mustParseMultiple(t, tc.input)[0], tc.opts); err == nil {
+				t.Errorf("Render(%q): got err = nil, want err", tc.name)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	const src = `<!DOCTYPE html><title>t</title><P Class=x>a &amp; b<br></P>`
+	got, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse(%q): got err = %v", src, err)
+	}
+	want := mustParseMultiple(t, "(directive (q DOCTYPE(u 32)html)) (html (head (title t)) (body (p (attr (class x)) (q a(u 32)(u 38)(u 32)b) (br))))")
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Parse(%q): got diff (-want, +got):\n%v", src, diff)
+	}
+	var sb strings.Builder
+	for _, v := range got {
+		if err := Render(&sb, v, Options{}); err != nil {
+			t.Fatalf("Render(%q): got err = %v", src, err)
+		}
+	}
+	const wantHTML = `<!DOCTYPE html><html><head><title>t</title></head><body><p class="x">a &amp; b<br></p></body></html>`
+	if diff := cmp.Diff(wantHTML, sb.String()); diff != "" {
+		t.Errorf("Render(%q): got diff (-want, +got):\n%v", src, diff)
+	}
+}
+
+func TestParseFragment(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  string
+	}{{
+		name:  "mismatched case",
+		input: "<P>hi</p>",
+		want:  "(p hi)",
+	}, {
+		name:  "implied end tags",
+		input: "<ul><li>a<li>b</ul><p>c<p>d",
+		want:  "(ul (li a) (li b)) (p c) (p d)",
+	}, {
+		name:  "void element",
+		input: "a<br>b<img src=x>",
+		want:  "a (br) b (img (attr (src x)))",
+	}, {
+		name:  "reserved element name",
+		input: "<raw>y</raw><!--c-->",
+		want:  "((q raw) y) (comment c)",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseFragment(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatalf("ParseFragment(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(mustParseMultiple(t, tc.want), got); diff != "" {
+				t.Errorf("ParseFragment(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package lisphtml
+
+import (
+	"io"
+	"strings"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+	"github.com/ajzaff/lisp/x/lispxml"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Parse parses the HTML document from r into Lisp markup.
+//
+// Parse follows HTML5: implied html, head and body elements, implied end tags
+// and void elements are handled as browsers do. Names are lower cased.
+// The result is the doctype, if any, followed by comments and the html element.
+func Parse(r io.Reader) ([]lisp.Val, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	var vs []lisp.Val
+	for n := doc.FirstChild; n != nil; n = n.NextSibling {
+		if v := node(n); v != nil {
+			vs = append(vs, v)
+		}
+	}
+	return vs, nil
+}
+
+// ParseFragment parses the HTML fragment from r into Lisp markup
+// as if it were the content of a body element.
+func ParseFragment(r io.Reader) ([]lisp.Val, error) {
+	ns, err := html.ParseFragment(r, &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var vs []lisp.Val
+	for _, n := range ns {
+		if v := node(n); v != nil {
+			vs = append(vs, v)
+		}
+	}
+	return vs, nil
+}
+
+// doctype returns the text of the doctype node n.
+func doctype(n *html.Node) string {
+	var sb strings.Builder
+	sb.WriteString("DOCTYPE ")
+	sb.WriteString(n.Data)
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "public":
+			sb.WriteString(` PUBLIC "`)
+		case "system":
+			sb.WriteString(` "`)
+		default:
+			continue
+		}
+		sb.WriteString(a.Val)
+		sb.WriteByte('"')
+	}
+	return sb.String()
+}
+
+// node returns the markup for n or nil if n has none.
+func node(n *html.Node) lisp.Val {
+	switch n.Type {
+	case html.TextNode:
+		if n.Data == "" {
+			return nil
+		}
+		return xlisp.Quote(n.Data)
+	case html.CommentNode:
+		return lisp.Group{commentLit, xlisp.Quote(n.Data)}
+	case html.DoctypeNode:
+		return lisp.Group{directiveLit, xlisp.Quote(doctype(n))}
+	case html.ElementNode:
+		g := lisp.Group{lispxml.QuoteName(n.Data)}
+		if len(n.Attr) > 0 {
+			attrs := lisp.Group{attrLit}
+			for _, a := range n.Attr {
+				k := a.Key
+				if a.Namespace != "" {
+					k = a.Namespace + ":" + k
+				}
+				attrs = append(attrs, lisp.Group{xlisp.Quote(k), xlisp.Quote(a.Val)})
+			}
+			g = append(g, attrs)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if v := node(c); v != nil {
+				g = append(g, v)
+			}
+		}
+		return g
+	default:
+		return nil
+	}
+}
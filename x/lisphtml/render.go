@@ -0,0 +1,296 @@
+package lisphtml
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+	"github.com/ajzaff/lisp/x/lispxml"
+)
+
+// voidElements are written without an end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// safeElements are the elements permitted by default.
+//
+// Elements which load or embed other documents, such as object, embed,
+// iframe and base, and form controls are not included.
+var safeElements = map[string]bool{
+	"html": true, "head": true, "title": true, "body": true,
+	"main": true, "header": true, "footer": true, "nav": true, "section": true,
+	"article": true, "aside": true, "address": true, "div": true, "span": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"hgroup": true, "p": true, "br": true, "hr": true, "wbr": true, "pre": true,
+	"blockquote": true, "figure": true, "figcaption": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "cite": true,
+	"code": true, "data": true, "del": true, "dfn": true, "em": true, "i": true,
+	"ins": true, "kbd": true, "mark": true, "q": true, "rp": true, "rt": true,
+	"ruby": true, "s": true, "samp": true, "small": true, "strong": true,
+	"sub": true, "sup": true, "time": true, "u": true, "var": true,
+	"table": true, "caption": true, "colgroup": true, "col": true,
+	"thead": true, "tbody": true, "tfoot": true, "tr": true, "th": true, "td": true,
+	"img": true, "picture": true, "source": true, "audio": true, "video": true,
+	"track": true, "map": true, "area": true, "details": true, "summary": true,
+}
+
+// urlAttrs hold URLs which are checked for unsafe schemes.
+var urlAttrs = map[string]bool{
+	"action": true, "archive": true, "background": true, "cite": true,
+	"classid": true, "codebase": true, "data": true, "dynsrc": true,
+	"formaction": true, "href": true, "icon": true, "longdesc": true,
+	"lowsrc": true, "manifest": true, "ping": true, "poster": true,
+	"profile": true, "src": true, "usemap": true, "xlink:href": true,
+}
+
+// srcsetAttrs hold comma separated lists of URLs with descriptors.
+var srcsetAttrs = map[string]bool{
+	"srcset": true, "imagesrcset": true,
+}
+
+type renderer struct {
+	Options
+
+	w *bufio.Writer
+}
+
+func newRenderer(w io.Writer, opts Options) *renderer {
+	return &renderer{Options: opts, w: bufio.NewWriter(w)}
+}
+
+func unquote(v lisp.Val) (string, error) {
+	s, err := xlisp.Unquote(v)
+	if err != nil {
+		return "", fmt.Errorf("lisphtml: %w", err)
+	}
+	return s, nil
+}
+
+// validName reports whether s is a safe element or attribute name.
+func validName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case r == '-', r == '_', r == ':', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unsafeURL reports whether the URL s uses a script or data scheme.
+func unsafeURL(s string) bool {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(s))
+	return strings.HasPrefix(s, "javascript:") || strings.HasPrefix(s, "vbscript:") ||
+		strings.HasPrefix(s, "data:")
+}
+
+// unsafeSrcset reports whether any URL in the srcset s is unsafe.
+func unsafeSrcset(s string) bool {
+	for _, c := range strings.Split(s, ",") {
+		if unsafeURL(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowElement reports whether the element with the lower cased name is permitted.
+func (r *renderer) allowElement(lower string) bool {
+	if safeElements[lower] {
+		return true
+	}
+	for _, name := range r.Elements {
+		if strings.ToLower(name) == lower {
+			return true
+		}
+	}
+	return false
+}
+
+// arg returns the only argument of the node v.
+func arg(v lisp.Group) (string, error) {
+	if len(v) != 2 {
+		return "", fmt.Errorf("lisphtml: malformed (%v): %v", v[0], v)
+	}
+	return unquote(v[1])
+}
+
+func (r *renderer) render(v lisp.Val) error {
+	switch v := v.(type) {
+	case lisp.Lit:
+		r.w.WriteString(html.EscapeString(string(v)))
+		return nil
+	case lisp.Group:
+		if len(v) == 0 {
+			return fmt.Errorf("lisphtml: unexpected empty Group")
+		}
+		switch v[0] {
+		case quoteLit:
+			s, err := unquote(v)
+			if err != nil {
+				return err
+			}
+			r.w.WriteString(html.EscapeString(s))
+			return nil
+		case commentLit:
+			s, err := arg(v)
+			if err != nil {
+				return err
+			}
+			if strings.Contains(s, "--") || strings.HasPrefix(s, ">") || strings.HasPrefix(s, "->") {
+				return fmt.Errorf("lisphtml: invalid comment text %q", s)
+			}
+			r.w.WriteString("<!--")
+			r.w.WriteString(s)
+			r.w.WriteString("-->")
+			return nil
+		case directiveLit:
+			s, err := arg(v)
+			if err != nil {
+				return err
+			}
+			if strings.ContainsAny(s, "<>") {
+				return fmt.Errorf("lisphtml: invalid directive text %q", s)
+			}
+			r.w.WriteString("<!")
+			r.w.WriteString(s)
+			r.w.WriteByte('>')
+			return nil
+		case rawLit:
+			if !r.AllowRaw {
+				return fmt.Errorf("lisphtml: raw node not allowed")
+			}
+			s, err := arg(v)
+			if err != nil {
+				return err
+			}
+			r.w.WriteString(s)
+			return nil
+		case attrLit, piLit:
+			return fmt.Errorf("lisphtml: unexpected (%v) node: %v", v[0], v)
+		}
+		return r.element(v)
+	default:
+		return fmt.Errorf("lisphtml: unexpected Val %v", v)
+	}
+}
+
+// elemName returns the name of the element with the given head.
+func elemName(head lisp.Val) (string, error) {
+	if g, ok := head.(lisp.Group); ok && len(g) == 2 && lispxml.IsReserved(g[1]) {
+		return string(g[1].(lisp.Lit)), nil
+	}
+	return unquote(head)
+}
+
+func (r *renderer) element(v lisp.Group) error {
+	name, err := elemName(v[0])
+	if err != nil {
+		return err
+	}
+	if !validName(name) {
+		return fmt.Errorf("lisphtml: invalid element name %q", name)
+	}
+	lower := strings.ToLower(name)
+	rawText := lower == "script" || lower == "style"
+	if rawText && !r.AllowScript || !rawText && !r.allowElement(lower) {
+		return fmt.Errorf("lisphtml: %s element not allowed", lower)
+	}
+	r.w.WriteByte('<')
+	r.w.WriteString(name)
+	children := v[1:]
+	if len(children) > 0 {
+		if attrs, ok := children[0].(lisp.Group); ok && len(attrs) > 0 && attrs[0] == attrLit {
+			children = children[1:]
+			if err := r.attrs(attrs[1:]); err != nil {
+				return err
+			}
+		}
+	}
+	r.w.WriteByte('>')
+	if voidElements[lower] {
+		if len(children) > 0 {
+			return fmt.Errorf("lisphtml: void element %s has children", lower)
+		}
+		return nil
+	}
+	if rawText {
+		// The content of script and style is not escaped.
+		for _, x := range children {
+			s, err := unquote(x)
+			if err != nil {
+				return err
+			}
+			if strings.Contains(strings.ToLower(s), "</"+lower) {
+				return fmt.Errorf("lisphtml: %s text contains end tag", lower)
+			}
+			r.w.WriteString(s)
+		}
+	} else {
+		for _, x := range children {
+			if err := r.render(x); err != nil {
+				return err
+			}
+		}
+	}
+	r.w.WriteString("</")
+	r.w.WriteString(name)
+	r.w.WriteByte('>')
+	return nil
+}
+
+func (r *renderer) attrs(attrs lisp.Group) error {
+	for _, x := range attrs {
+		kv, ok := x.(lisp.Group)
+		if !ok || len(kv) != 2 {
+			return fmt.Errorf("lisphtml: malformed attribute: %v", x)
+		}
+		k, err := unquote(kv[0])
+		if err != nil {
+			return err
+		}
+		val, err := unquote(kv[1])
+		if err != nil {
+			return err
+		}
+		if !validName(k) {
+			return fmt.Errorf("lisphtml: invalid attribute name %q", k)
+		}
+		lower := strings.ToLower(k)
+		if !r.AllowScript {
+			if strings.HasPrefix(lower, "on") || lower == "srcdoc" || lower == "style" {
+				return fmt.Errorf("lisphtml: %s attribute not allowed", lower)
+			}
+			if urlAttrs[lower] && unsafeURL(val) || srcsetAttrs[lower] && unsafeSrcset(val) {
+				return fmt.Errorf("lisphtml: unsafe URL not allowed in %s attribute", lower)
+			}
+			if lower == "http-equiv" && strings.EqualFold(strings.TrimSpace(val), "refresh") {
+				return fmt.Errorf("lisphtml: http-equiv refresh not allowed")
+			}
+		}
+		r.w.WriteByte(' ')
+		r.w.WriteString(k)
+		r.w.WriteString(`="`)
+		r.w.WriteString(html.EscapeString(val))
+		r.w.WriteByte('"')
+	}
+	return nil
+}
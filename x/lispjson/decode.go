@@ -1,59 +1,131 @@
 package lispjson
 
 import (
-	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"iter"
 
 	"github.com/ajzaff/lisp"
-	"github.com/ajzaff/lisp/scan"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
 )
 
+// Decoder reads a stream of JSON values as Lisp values.
 type Decoder struct {
-	sc scan.Scanner
+	d   *json.Decoder
+	err error
 }
 
+// NewDecoder returns a Decoder reading from r.
 func NewDecoder(r io.Reader) *Decoder {
-	var d Decoder
-	d.decodeSrc(r)
-	return &d
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	return &Decoder{d: d}
 }
 
-func (d *Decoder) decodeSrc(r io.Reader) {
-	// TODO: Implement JSON <-> Lisp readers
-	//       That don't buffer the full reader.
-	// Transform JSON source to Lisp in-place.
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	src := buf.Bytes()
-	for i, b := range src {
-		switch {
-		case b == '[':
-			src[i] = '('
-		case b == ']':
-			src[i] = ')'
-		case b == ',':
-			src[i] = ' '
-		case b == '"':
-			// Unquote literals by replacing '"".
-			// FIXME: Creative, but this may cause issues.
-			src[i] = ' '
+// Decode decodes the next JSON value.
+//
+// Decode returns io.EOF when no more values are available.
+func (d *Decoder) Decode() (lisp.Val, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	tok, err := d.d.Token()
+	if err != nil {
+		if err != io.EOF {
+			d.err = err
+		}
+		return nil, err
+	}
+	v, err := d.decode(tok)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+		return nil, err
+	}
+	return v, nil
+}
+
+func (d *Decoder) decode(tok json.Token) (lisp.Val, error) {
+	switch tok := tok.(type) {
+	case string:
+		return xlisp.Quote(tok), nil
+	case json.Number:
+		return lisp.Group{numLit, xlisp.Quote(tok.String())}, nil
+	case bool:
+		if tok {
+			return lisp.Group{trueLit}, nil
+		}
+		return lisp.Group{falseLit}, nil
+	case nil:
+		return lisp.Group{nullLit}, nil
+	case json.Delim:
+		switch tok {
+		case '[':
+			g := lisp.Group{}
+			for d.d.More() {
+				v, err := d.next()
+				if err != nil {
+					return nil, err
+				}
+				g = append(g, v)
+			}
+			if _, err := d.d.Token(); err != nil { // ]
+				return nil, err
+			}
+			if len(g) > 0 && isReserved(g[0]) {
+				g = append(lisp.Group{arrayLit}, g...)
+			}
+			return g, nil
+		case '{':
+			g := lisp.Group{objectLit}
+			for d.d.More() {
+				k, err := d.d.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := k.(string)
+				if !ok {
+					return nil, fmt.Errorf("lispjson: unexpected object key %v", k)
+				}
+				v, err := d.next()
+				if err != nil {
+					return nil, err
+				}
+				g = append(g, lisp.Group{xlisp.Quote(key), v})
+			}
+			if _, err := d.d.Token(); err != nil { // }
+				return nil, err
+			}
+			return g, nil
 		}
 	}
-	// Tokenize and parse normally.
-	var sc scan.Scanner
-	sc.Reset(bytes.NewReader(src))
-	d.sc = sc
+	return nil, fmt.Errorf("lispjson: unexpected token %v", tok)
+}
+
+func (d *Decoder) next() (lisp.Val, error) {
+	tok, err := d.d.Token()
+	if err != nil {
+		return nil, err
+	}
+	return d.decode(tok)
 }
 
+// Values returns an iteration over the decoded values.
+//
+// Errors are reported by Err.
 func (d *Decoder) Values() iter.Seq[lisp.Val] {
 	return func(yield func(lisp.Val) bool) {
-		for n := range d.sc.Nodes() {
-			if !yield(n.Val) {
-				break
+		for {
+			v, err := d.Decode()
+			if err != nil || !yield(v) {
+				return
 			}
 		}
 	}
 }
 
-func (d *Decoder) Err() error { return d.sc.Err() }
+// Err returns the first error encountered by the Decoder other than io.EOF.
+func (d *Decoder) Err() error { return d.err }
@@ -1,32 +1,140 @@
 package lispjson
 
 import (
+	"bufio"
+	"encoding/json"
+	"fmt"
 	"io"
 
 	"github.com/ajzaff/lisp"
-	"github.com/ajzaff/lisp/x/stringer"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
 )
 
-type Encoder struct{ w io.Writer }
+// Encoder writes Lisp values as JSON Lines.
+//
+// Tokens are written as the value is walked without buffering the whole value.
+// The first error is sticky and returned from all subsequent calls.
+type Encoder struct {
+	buf *bufio.Writer
+	err error
+}
 
+// NewEncoder returns an Encoder writing to w.
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w}
+	return &Encoder{buf: bufio.NewWriter(w)}
+}
+
+// Encode writes the JSON value of v followed by a new line and flushes the Encoder.
+//
+// When v cannot be encoded, part of the value may already have been written.
+func (e *Encoder) Encode(v lisp.Val) error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := e.encode(v); err != nil {
+		e.buf.Flush()
+		e.err = err
+		return err
+	}
+	e.buf.WriteByte('\n')
+	if err := e.buf.Flush(); err != nil {
+		e.err = err
+	}
+	return e.err
 }
 
-func (e *Encoder) Encode(v lisp.Val) {
+// validNumber returns whether s matches the JSON number grammar.
+//
+// The range of the number is not checked so that any number read by
+// the Decoder can be written back.
+func validNumber(s string) bool {
+	if s == "" || s[0] != '-' && !isDigit(s[0]) || !isDigit(s[len(s)-1]) {
+		return false
+	}
+	return json.Valid([]byte(s))
+}
+
+func isDigit(b byte) bool { return '0' <= b && b <= '9' }
+
+func (e *Encoder) encodeString(s string) {
+	b, _ := json.Marshal(s)
+	e.buf.Write(b)
+}
+
+func (e *Encoder) encode(v lisp.Val) error {
 	switch v := v.(type) {
 	case lisp.Lit:
-		// FIXME: No need to Quote the Id if its valid.
-		// (Number | Letter) & ~Print = {}.
-		e.w.Write([]byte(stringer.Lit(v)))
+		e.encodeString(string(v))
+		return nil
 	case lisp.Group:
-		e.w.Write([]byte{'['})
+		var head lisp.Val
+		if len(v) > 0 {
+			head = v[0]
+		}
+		switch head {
+		case quoteLit:
+			s, err := xlisp.Unquote(v)
+			if err != nil {
+				return fmt.Errorf("lispjson: %w", err)
+			}
+			e.encodeString(s)
+			return nil
+		case numLit:
+			if len(v) != 2 {
+				return fmt.Errorf("lispjson: malformed (num X): %v", v)
+			}
+			s, err := xlisp.Unquote(v[1])
+			if err != nil {
+				return fmt.Errorf("lispjson: %w", err)
+			}
+			if !validNumber(s) {
+				return fmt.Errorf("lispjson: invalid number %q", s)
+			}
+			e.buf.WriteString(s)
+			return nil
+		case trueLit, falseLit, nullLit:
+			if len(v) != 1 {
+				return fmt.Errorf("lispjson: malformed (%v): %v", head, v)
+			}
+			e.buf.WriteString(string(head.(lisp.Lit)))
+			return nil
+		case objectLit:
+			e.buf.WriteByte('{')
+			for i, x := range v[1:] {
+				kv, ok := x.(lisp.Group)
+				if !ok || len(kv) != 2 {
+					return fmt.Errorf("lispjson: malformed object entry: %v", x)
+				}
+				k, err := xlisp.Unquote(kv[0])
+				if err != nil {
+					return fmt.Errorf("lispjson: %w", err)
+				}
+				if i > 0 {
+					e.buf.WriteByte(',')
+				}
+				e.encodeString(k)
+				e.buf.WriteByte(':')
+				if err := e.encode(kv[1]); err != nil {
+					return err
+				}
+			}
+			e.buf.WriteByte('}')
+			return nil
+		case arrayLit:
+			v = v[1:]
+		}
+		e.buf.WriteByte('[')
 		for i, x := range v {
 			if i > 0 {
-				e.w.Write([]byte{','})
+				e.buf.WriteByte(',')
+			}
+			if err := e.encode(x); err != nil {
+				return err
 			}
-			e.Encode(x)
 		}
-		e.w.Write([]byte{']'})
+		e.buf.WriteByte(']')
+		return nil
+	default:
+		return fmt.Errorf("lispjson: unexpected Val %v", v)
 	}
 }
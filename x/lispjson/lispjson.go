@@ -0,0 +1,44 @@
+// Package lispjson implements streaming conversion between JSON and Lisp.
+//
+// JSON values are mapped to Lisp values as follows:
+//
+//	"text"          Quoted text (see xlisp.Quote)
+//	1.5             (num X) where X is the quoted number text
+//	true            (true)
+//	false           (false)
+//	null            (null)
+//	{"k": v, ...}   (object (k v) ...) where k is quoted text
+//	[v, ...]        (v ...)
+//
+// Arrays whose first element is one of the reserved Lits
+// q, num, true, false, null, object or array are written as (array v ...).
+//
+// Converting JSON to Lisp and back preserves the JSON values,
+// including number text and the order of object keys.
+// Converting Lisp to JSON and back preserves any Lisp value
+// produced by the Decoder or without reserved heads.
+//
+// Streams of multiple JSON values such as JSON Lines are supported.
+package lispjson
+
+import "github.com/ajzaff/lisp"
+
+// Reserved Lits used by the mapping.
+const (
+	quoteLit  lisp.Lit = "q"
+	numLit    lisp.Lit = "num"
+	trueLit   lisp.Lit = "true"
+	falseLit  lisp.Lit = "false"
+	nullLit   lisp.Lit = "null"
+	objectLit lisp.Lit = "object"
+	arrayLit  lisp.Lit = "array"
+)
+
+func isReserved(v lisp.Val) bool {
+	switch v {
+	case quoteLit, numLit, trueLit, falseLit, nullLit, objectLit, arrayLit:
+		return true
+	default:
+		return false
+	}
+}
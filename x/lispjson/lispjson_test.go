@@ -0,0 +1,201 @@
+package lispjson
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/ajzaff/lisp/x/fuzzutil"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParseMultiple(t *testing.T, src string) []lisp.Val {
+	t.Helper()
+	var vs []lisp.Val
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		vs = append(vs, n.Val)
+	}
+	if err := sc.Err(); err != nil {
+		panic(fmt.Sprintf("mustParse: failed to parse: %q: %v", src, err))
+	}
+	return vs
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name:  "string",
+		input: `"abc"`,
+		want:  "abc",
+	}, {
+		name:  "string with JSON syntax",
+		input: `"[a,\"b\"]"`,
+		want:  "(q (u 91)a(u 44)(u 34)b(u 34)(u 93))",
+	}, {
+		name:  "numbers",
+		input: `12 -1.5e3`,
+		want:  "(num 12) (num (q (u 45)1(u 46)5e3))",
+	}, {
+		name:  "literals",
+		input: `true false null`,
+		want:  "(true) (false) (null)",
+	}, {
+		name:  "array",
+		input: `["a", ["b"], []]`,
+		want:  "(a (b) ())",
+	}, {
+		name:  "array with reserved head",
+		input: `["true", 1]`,
+		want:  "(array true (num 1))",
+	}, {
+		name:  "object",
+		input: `{"b": 1, "a b": {"c": null}}`,
+		want:  "(object (b (num 1)) ((q a(u 32)b) (object (c (null)))))",
+	}, {
+		name:  "JSON Lines",
+		input: "{\"a\": 1}\n{\"a\": 2}\n",
+		want:  "(object (a (num 1))) (object (a (num 2)))",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tc.input))
+			got := slices.Collect(d.Values())
+			if err := d.Err(); err != nil {
+				t.Fatalf("Decode(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(mustParseMultiple(t, tc.want), got); diff != "" {
+				t.Errorf("Decode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	input := `"x" "" 0 -0.25 1E+10 1e400 -1E-400 true false null [] {} ["q", "num"] {"z": [1, {"": "a\nb"}], "a": "é\u0000"}` + "\n"
+	var sb strings.Builder
+	e := NewEncoder(&sb)
+	d := NewDecoder(strings.NewReader(input))
+	for v := range d.Values() {
+		if err := e.Encode(v); err != nil {
+			t.Fatalf("Encode(%v): got err = %v", v, err)
+		}
+	}
+	if err := d.Err(); err != nil {
+		t.Fatalf("Decode(): got err = %v", err)
+	}
+	want := `"x"
+""
+0
+-0.25
+1E+10
+1e400
+-1E-400
+true
+false
+null
+[]
+{}
+["q","num"]
+{"z":[1,{"":"a\nb"}],"a":"é\u0000"}
+`
+	if diff := cmp.Diff(want, sb.String()); diff != "" {
+		t.Errorf("Encode(): got diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestLispRoundTrip(t *testing.T) {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 5
+	want := mustParseMultiple(t, "(array q) (object) (num 1) (true)")
+	for range 100 {
+		v := g.Next()
+		if g, ok := v.(lisp.Group); ok && len(g) > 0 && isReserved(g[0]) {
+			continue
+		}
+		want = append(want, v)
+	}
+	var sb strings.Builder
+	e := NewEncoder(&sb)
+	for _, v := range want {
+		if err := e.Encode(v); err != nil {
+			t.Fatalf("Encode(%v): got err = %v", v, err)
+		}
+	}
+	d := NewDecoder(strings.NewReader(sb.String()))
+	got := slices.Collect(d.Values())
+	if err := d.Err(); err != nil {
+		t.Fatalf("Decode(): got err = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Decode(): got diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, input := range []string{
+		"(num)",
+		"(num abc)",
+		"(num (q (u 34) x (u 34)))",
+		"(num (q 1 (u 32)))",
+		"(num 01)",
+		"(true 1)",
+		"(object a)",
+		"(object (a))",
+		"(q (x))",
+		"((num 1 2))",
+	} {
+		e := NewEncoder(new(strings.Builder))
+		if err := e.Encode(mustParseMultiple(t, input)[0]); err == nil {
+			t.Errorf("Encode(%q): got err = nil, want err", input)
+		}
+	}
+}
+
+// countWriter counts calls to Write.
+type countWriter struct {
+	strings.Builder
+	n int
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n++
+	return w.Builder.Write(p)
+}
+
+func TestEncodeStreams(t *testing.T) {
+	v := lisp.Group{lisp.Lit("array")}
+	for range 10000 {
+		v = append(v, lisp.Lit("abc"))
+	}
+	var w countWriter
+	if err := NewEncoder(&w).Encode(v); err != nil {
+		t.Fatalf("Encode(): got err = %v", err)
+	}
+	if w.n < 2 {
+		t.Errorf("Encode(): got %d writes, want value written incrementally", w.n)
+	}
+	if want := len(`["abc"`) + 9999*len(`,"abc"`) + len("]\n"); w.Len() != want {
+		t.Errorf("Encode(): got %d bytes, want %d", w.Len(), want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, input := range []string{`[1,`, `{"a"}`, `]`, `[1] x`} {
+		d := NewDecoder(strings.NewReader(input))
+		for range d.Values() {
+		}
+		if d.Err() == nil {
+			t.Errorf("Decode(%q): got err = nil, want err", input)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package lispxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Decoder reads a stream of XML nodes as Lisp values.
+type Decoder struct {
+	Options
+
+	d   *xml.Decoder
+	tok xml.Token // Token read ahead after text.
+	err error
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader, opts Options) *Decoder {
+	return &Decoder{Options: opts, d: xml.NewDecoder(r)}
+}
+
+// Decode decodes the next top-level XML node.
+//
+// Decode returns io.EOF when no more values are available.
+func (d *Decoder) Decode() (lisp.Val, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	for {
+		tok, err := d.next()
+		if err != nil {
+			if err != io.EOF {
+				d.err = err
+			}
+			return nil, err
+		}
+		v, err := d.decode(tok)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			d.err = err
+			return nil, err
+		}
+		if v != nil {
+			return v, nil
+		}
+	}
+}
+
+// Values returns an iteration over the decoded values.
+//
+// Errors are reported by Err.
+func (d *Decoder) Values() iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		for {
+			v, err := d.Decode()
+			if err != nil || !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the first error encountered by the Decoder other than io.EOF.
+func (d *Decoder) Err() error { return d.err }
+
+func (d *Decoder) next() (xml.Token, error) {
+	if tok := d.tok; tok != nil {
+		d.tok = nil
+		return tok, nil
+	}
+	if d.Namespace == NamespaceRaw {
+		return d.d.RawToken()
+	}
+	return d.d.Token()
+}
+
+func (d *Decoder) name(n xml.Name) string {
+	switch {
+	case n.Space == "":
+		return n.Local
+	case d.Namespace == NamespaceRaw:
+		return n.Space + ":" + n.Local
+	case d.Namespace == NamespaceExpand:
+		return "{" + n.Space + "}" + n.Local
+	default:
+		return n.Local
+	}
+}
+
+func isXMLNS(n xml.Name) bool {
+	return n.Space == "xmlns" || n.Space == "" && n.Local == "xmlns"
+}
+
+// decode returns the value for the node beginning with tok
+// or nil if the node is dropped.
+func (d *Decoder) decode(tok xml.Token) (lisp.Val, error) {
+	switch tok := tok.(type) {
+	case xml.StartElement:
+		return d.element(tok)
+	case xml.CharData:
+		return d.text(tok)
+	case xml.Comment:
+		return lisp.Group{commentLit, xlisp.Quote(string(tok))}, nil
+	case xml.ProcInst:
+		return lisp.Group{piLit, xlisp.Quote(tok.Target), xlisp.Quote(string(tok.Inst))}, nil
+	case xml.Directive:
+		return lisp.Group{directiveLit, xlisp.Quote(string(tok))}, nil
+	case xml.EndElement:
+		return nil, fmt.Errorf("lispxml: unexpected end element </%s>", d.name(tok.Name))
+	default:
+		return nil, fmt.Errorf("lispxml: unexpected token %v", tok)
+	}
+}
+
+// text merges adjacent CharData beginning with tok into a single text node.
+func (d *Decoder) text(tok xml.CharData) (lisp.Val, error) {
+	var sb strings.Builder
+	sb.Write(tok)
+	for {
+		next, err := d.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		cd, ok := next.(xml.CharData)
+		if !ok {
+			d.tok = next
+			break
+		}
+		sb.Write(cd)
+	}
+	s := sb.String()
+	if !d.KeepSpace && strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	return xlisp.Quote(s), nil
+}
+
+func (d *Decoder) element(start xml.StartElement) (lisp.Val, error) {
+	name := d.name(start.Name)
+	g := lisp.Group{QuoteName(name)}
+	if len(start.Attr) > 0 {
+		attrs := lisp.Group{attrLit}
+		for _, a := range start.Attr {
+			if d.Namespace != NamespaceRaw && isXMLNS(a.Name) {
+				continue
+			}
+			attrs = append(attrs, lisp.Group{xlisp.Quote(d.name(a.Name)), xlisp.Quote(a.Value)})
+		}
+		if len(attrs) > 1 {
+			g = append(g, attrs)
+		}
+	}
+	for {
+		tok, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		if end, ok := tok.(xml.EndElement); ok {
+			// RawToken does not check that elements match.
+			if got := d.name(end.Name); got != name {
+				return nil, fmt.Errorf("lispxml: element <%s> closed by </%s>", name, got)
+			}
+			return g, nil
+		}
+		v, err := d.decode(tok)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			g = append(g, v)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package lispxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Encoder writes Lisp values as XML.
+type Encoder struct {
+	Options
+
+	e *xml.Encoder
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer, opts Options) *Encoder {
+	return &Encoder{Options: opts, e: xml.NewEncoder(w)}
+}
+
+// Encode writes the XML node for v and flushes the Encoder.
+func (e *Encoder) Encode(v lisp.Val) error {
+	if err := e.encode(v); err != nil {
+		return err
+	}
+	return e.e.Flush()
+}
+
+func (e *Encoder) name(s string) xml.Name {
+	switch e.Namespace {
+	case NamespaceExpand:
+		if strings.HasPrefix(s, "{") {
+			if i := strings.IndexByte(s, '}'); i > 0 {
+				return xml.Name{Space: s[1:i], Local: s[i+1:]}
+			}
+		}
+	}
+	return xml.Name{Local: s}
+}
+
+func unquote(v lisp.Val) (string, error) {
+	s, err := xlisp.Unquote(v)
+	if err != nil {
+		return "", fmt.Errorf("lispxml: %w", err)
+	}
+	return s, nil
+}
+
+// elemName returns the name of the element with the given head.
+func elemName(head lisp.Val) (string, error) {
+	if g, ok := head.(lisp.Group); ok && len(g) == 2 && IsReserved(g[1]) {
+		return string(g[1].(lisp.Lit)), nil
+	}
+	return unquote(head)
+}
+
+func (e *Encoder) encode(v lisp.Val) error {
+	switch v := v.(type) {
+	case lisp.Lit:
+		return e.e.EncodeToken(xml.CharData(v))
+	case lisp.Group:
+		if len(v) == 0 {
+			return fmt.Errorf("lispxml: unexpected empty Group")
+		}
+		switch v[0] {
+		case quoteLit:
+			s, err := unquote(v)
+			if err != nil {
+				return err
+			}
+			return e.e.EncodeToken(xml.CharData(s))
+		case commentLit:
+			s, err := e.arg(v, 1)
+			if err != nil {
+				return err
+			}
+			return e.e.EncodeToken(xml.Comment(s))
+		case piLit:
+			target, err := e.arg(v, 2)
+			if err != nil {
+				return err
+			}
+			inst, err := unquote(v[2])
+			if err != nil {
+				return err
+			}
+			return e.e.EncodeToken(xml.ProcInst{Target: target, Inst: []byte(inst)})
+		case directiveLit:
+			s, err := e.arg(v, 1)
+			if err != nil {
+				return err
+			}
+			return e.e.EncodeToken(xml.Directive(s))
+		case attrLit:
+			return fmt.Errorf("lispxml: unexpected attr outside element: %v", v)
+		case rawLit:
+			return fmt.Errorf("lispxml: unexpected raw node: %v", v)
+		}
+		return e.element(v)
+	default:
+		return fmt.Errorf("lispxml: unexpected Val %v", v)
+	}
+}
+
+// arg returns the first argument of the node v with n arguments.
+func (e *Encoder) arg(v lisp.Group, n int) (string, error) {
+	if len(v) != n+1 {
+		return "", fmt.Errorf("lispxml: malformed (%v): %v", v[0], v)
+	}
+	return unquote(v[1])
+}
+
+func (e *Encoder) element(v lisp.Group) error {
+	name, err := elemName(v[0])
+	if err != nil {
+		return err
+	}
+	start := xml.StartElement{Name: e.name(name)}
+	children := v[1:]
+	if len(children) > 0 {
+		if attrs, ok := children[0].(lisp.Group); ok && len(attrs) > 0 && attrs[0] == attrLit {
+			children = children[1:]
+			for _, x := range attrs[1:] {
+				kv, ok := x.(lisp.Group)
+				if !ok || len(kv) != 2 {
+					return fmt.Errorf("lispxml: malformed attribute: %v", x)
+				}
+				k, err := unquote(kv[0])
+				if err != nil {
+					return err
+				}
+				val, err := unquote(kv[1])
+				if err != nil {
+					return err
+				}
+				start.Attr = append(start.Attr, xml.Attr{Name: e.name(k), Value: val})
+			}
+		}
+	}
+	if err := e.e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, x := range children {
+		if err := e.encode(x); err != nil {
+			return err
+		}
+	}
+	return e.e.EncodeToken(start.End())
+}
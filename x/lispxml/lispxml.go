@@ -0,0 +1,83 @@
+// Package lispxml implements streaming conversion between XML and Lisp.
+//
+// XML nodes are mapped to Lisp values in a shape similar to SXML:
+//
+//	<name a="v" ...>children</name>   (name (attr (a v) ...) children ...)
+//	text                              Quoted text (see xlisp.Quote)
+//	<!--text-->                       (comment text)
+//	<?target inst?>                   (pi target inst)
+//	<!text>                           (directive text)
+//
+// Names, attribute values and text are quoted using xlisp.Quote.
+// The attr Group is omitted for elements without attributes.
+// Elements named by one of the reserved Lits q, attr, comment, pi,
+// directive or raw use the quoted form (q name) as the head.
+// The raw Lit is reserved for package lisphtml.
+//
+// Adjacent text and CDATA sections are merged into a single text node.
+// Text consisting only of white space is dropped unless KeepSpace is set.
+//
+// Converting Lisp to XML and back preserves any Lisp value
+// produced by the Decoder with the same Options.
+package lispxml
+
+import (
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Reserved Lits used by the mapping.
+const (
+	quoteLit     lisp.Lit = "q"
+	attrLit      lisp.Lit = "attr"
+	commentLit   lisp.Lit = "comment"
+	piLit        lisp.Lit = "pi"
+	directiveLit lisp.Lit = "directive"
+	rawLit       lisp.Lit = "raw"
+)
+
+// IsReserved returns whether v is one of the reserved Lits used by the mapping.
+func IsReserved(v lisp.Val) bool {
+	switch v {
+	case quoteLit, attrLit, commentLit, piLit, directiveLit, rawLit:
+		return true
+	default:
+		return false
+	}
+}
+
+// NamespaceMode controls how XML namespaces are mapped to names.
+type NamespaceMode int
+
+const (
+	// NamespaceRaw keeps names as written including any prefix
+	// such as "p:name" and keeps xmlns attributes.
+	NamespaceRaw NamespaceMode = iota
+
+	// NamespaceLocal drops namespaces and xmlns attributes
+	// keeping only the local part of names.
+	NamespaceLocal
+
+	// NamespaceExpand resolves prefixes and writes names
+	// as "{uri}name" and drops xmlns attributes.
+	// The Encoder declares the namespaces as needed.
+	NamespaceExpand
+)
+
+// Options for the Encoder and Decoder.
+type Options struct {
+	// Namespace controls the mapping of namespaced names.
+	Namespace NamespaceMode
+
+	// KeepSpace keeps text consisting only of white space.
+	KeepSpace bool
+}
+
+// QuoteName returns the head used for an element with the given name.
+func QuoteName(name string) lisp.Val {
+	v := xlisp.Quote(name)
+	if IsReserved(v) {
+		return lisp.Group{quoteLit, v}
+	}
+	return v
+}
@@ -0,0 +1,198 @@
+package lispxml
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParseMultiple(t *testing.T, src string) []lisp.Val {
+	t.Helper()
+	var vs []lisp.Val
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		vs = append(vs, n.Val)
+	}
+	if err := sc.Err(); err != nil {
+		panic(fmt.Sprintf("mustParse: failed to parse: %q: %v", src, err))
+	}
+	return vs
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name:  "element",
+		input: `<a><b>text</b><c/></a>`,
+		want:  "(a (b text) (c))",
+	}, {
+		name:  "attributes",
+		input: `<a x="1" data-y="a b"/>`,
+		want:  "(a (attr (x 1) ((q data(u 45)y) (q a(u 32)b))))",
+	}, {
+		name:  "text with entities and CDATA",
+		input: `<a>x &lt; <![CDATA[<y>]]></a>`,
+		want:  "(a (q x(u 32)(u 60)(u 32)(u 60)y(u 62)))",
+	}, {
+		name:  "space dropped",
+		input: "<a>\n  <b/>\n</a>\n",
+		want:  "(a (b))",
+	}, {
+		name:  "space kept",
+		input: "<a> <b/></a>",
+		opts:  Options{KeepSpace: true},
+		want:  "(a (q (u 32)) (b))",
+	}, {
+		name:  "reserved element names",
+		input: `<q><attr/></q>`,
+		want:  "((q q) ((q attr)))",
+	}, {
+		name:  "comment, pi and directive",
+		input: `<?xml version="1.0"?><!DOCTYPE a><!--c--><a/>`,
+		want:  "(pi xml (q version(u 61)(u 34)1(u 46)0(u 34))) (directive (q DOCTYPE(u 32)a)) (comment c) (a)",
+	}, {
+		name:  "namespace raw",
+		input: `<p:a xmlns:p="urn" p:x="1"/>`,
+		want:  "((q p(u 58)a) (attr ((q xmlns(u 58)p) urn) ((q p(u 58)x) 1)))",
+	}, {
+		name:  "namespace local",
+		input: `<p:a xmlns:p="urn" p:x="1"/>`,
+		opts:  Options{Namespace: NamespaceLocal},
+		want:  "(a (attr (x 1)))",
+	}, {
+		name:  "namespace expand",
+		input: `<a xmlns="urn"><b/></a>`,
+		opts:  Options{Namespace: NamespaceExpand},
+		want:  "((q (u 123)urn(u 125)a) ((q (u 123)urn(u 125)b)))",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tc.input), tc.opts)
+			got := slices.Collect(d.Values())
+			if err := d.Err(); err != nil {
+				t.Fatalf("Decode(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(mustParseMultiple(t, tc.want), got); diff != "" {
+				t.Errorf("Decode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+	}{
+		{"unclosed element", `<a>`, Options{}},
+		{"mismatched raw element", `<a></b>`, Options{}},
+		{"mismatched element", `<a></b>`, Options{Namespace: NamespaceLocal}},
+		{"stray end element", `</a>`, Options{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tc.input), tc.opts)
+			for range d.Values() {
+			}
+			if d.Err() == nil {
+				t.Errorf("Decode(%q): got err = nil, want err", tc.name)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{{
+		name:  "element",
+		input: "(a (attr (x 1)) hello (b))",
+		want:  `<a x="1">hello<b></b></a>`,
+	}, {
+		name:  "escaped text",
+		input: "(a (q x(u 60)(u 38)))",
+		want:  `<a>x&lt;&amp;</a>`,
+	}, {
+		name:  "reserved element name",
+		input: "((q q) (comment c))",
+		want:  `<q><!--c--></q>`,
+	}, {
+		name:  "namespace expand",
+		input: "((q (u 123)urn(u 125)a))",
+		opts:  Options{Namespace: NamespaceExpand},
+		want:  `<a xmlns="urn"></a>`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			e := NewEncoder(&sb, tc.opts)
+			for _, v := range mustParseMultiple(t, tc.input) {
+				if err := e.Encode(v); err != nil {
+					t.Fatalf("Encode(%q): got err = %v", tc.name, err)
+				}
+			}
+			if diff := cmp.Diff(tc.want, sb.String()); diff != "" {
+				t.Errorf("Encode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{"empty Group", "()"},
+		{"attr outside element", "(attr (x 1))"},
+		{"malformed attribute", "(a (attr x))"},
+		{"malformed comment", "(comment)"},
+		{"bad quote", "(q (x))"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEncoder(&strings.Builder{}, Options{})
+			if err := e.Encode(mustParseMultiple(t, tc.input)[0]); err == nil {
+				t.Errorf("Encode(%q): got err = nil, want err", tc.name)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	const src = `<?xml version="1.0"?><!DOCTYPE r><r xmlns:p="urn:p"><p:q a="1 &amp; 2">x<![CDATA[ < ]]>y</p:q><!-- note --><q/> </r>`
+	for _, opts := range []Options{{}, {KeepSpace: true}, {Namespace: NamespaceLocal}} {
+		d := NewDecoder(strings.NewReader(src), opts)
+		want := slices.Collect(d.Values())
+		if err := d.Err(); err != nil {
+			t.Fatalf("Decode(%+v): got err = %v", opts, err)
+		}
+		var sb strings.Builder
+		e := NewEncoder(&sb, opts)
+		for _, v := range want {
+			if err := e.Encode(v); err != nil {
+				t.Fatalf("Encode(%+v): got err = %v", opts, err)
+			}
+		}
+		d = NewDecoder(strings.NewReader(sb.String()), opts)
+		got := slices.Collect(d.Values())
+		if err := d.Err(); err != nil {
+			t.Fatalf("Decode(%+v): got err = %v", opts, err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("RoundTrip(%+v): got diff (-want, +got):\n%v", opts, diff)
+		}
+	}
+}
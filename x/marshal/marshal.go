@@ -0,0 +1,530 @@
+// Package marshal implements conversion between Go values and Lisp values.
+//
+// Go values are mapped to Lisp values as follows:
+//
+//	bool            true or false
+//	uint, int       Nat Lit, or (neg N) for negative integers
+//	float           Quoted decimal text
+//	string          Quoted text (see xlisp.Quote)
+//	slice, array    Group of elements
+//	map             Group of (k v) pairs sorted by key
+//	struct          Group of (name v) pairs in field order
+//	pointer         The pointed-to value, or () for nil
+//	lisp.Val        The Val itself
+//
+// Struct fields may be customized with tags:
+//
+//	Field int `lisp:"name,omitempty,inline"`
+//
+// Where name overrides the field name, omitempty omits zero values,
+// and inline flattens the pairs of a struct field into the parent.
+// Embedded structs without a name are inlined. The name "-" skips the field.
+//
+// Types implementing Marshaler or Unmarshaler control their own conversion.
+// Otherwise types implementing encoding.TextMarshaler or encoding.TextUnmarshaler
+// are converted as quoted text.
+package marshal
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Marshaler is implemented by types that convert themselves to Lisp values.
+type Marshaler interface {
+	MarshalLisp() (lisp.Val, error)
+}
+
+// Unmarshaler is implemented by types that convert themselves from Lisp values.
+type Unmarshaler interface {
+	UnmarshalLisp(lisp.Val) error
+}
+
+var (
+	valType           = reflect.TypeFor[lisp.Val]()
+	marshalerType     = reflect.TypeFor[Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// Lits used by the mapping.
+const (
+	trueLit  lisp.Lit = "true"
+	falseLit lisp.Lit = "false"
+	negLit   lisp.Lit = "neg"
+)
+
+// Marshal returns the Lisp value of x.
+//
+// Marshal returns an error if x contains a cycle of pointers, maps or slices.
+func Marshal(x any) (lisp.Val, error) {
+	return marshal(reflect.ValueOf(x), make(map[visit]bool))
+}
+
+// visit identifies a pointer, map or slice being marshaled.
+type visit struct {
+	ptr uintptr
+	len int // Slice length.
+	typ reflect.Type
+}
+
+func marshal(v reflect.Value, seen map[visit]bool) (lisp.Val, error) {
+	if !v.IsValid() {
+		return lisp.Group{}, nil
+	}
+	t := v.Type()
+	if t.Implements(valType) && t.Kind() != reflect.Interface && t.Kind() != reflect.Pointer {
+		return v.Interface().(lisp.Val), nil
+	}
+	if t.Kind() != reflect.Pointer && v.CanAddr() {
+		if pt := reflect.PointerTo(t); pt.Implements(marshalerType) || pt.Implements(textMarshalerType) {
+			v, t = v.Addr(), pt
+		}
+	}
+	if t.Implements(marshalerType) {
+		if t.Kind() == reflect.Pointer && v.IsNil() {
+			return lisp.Group{}, nil
+		}
+		return v.Interface().(Marshaler).MarshalLisp()
+	}
+	if t.Implements(textMarshalerType) {
+		if t.Kind() == reflect.Pointer && v.IsNil() {
+			return lisp.Group{}, nil
+		}
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return xlisp.Quote(string(text)), nil
+	}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			break
+		}
+		k := visit{v.Pointer(), 0, t}
+		if t.Kind() == reflect.Slice {
+			k.len = v.Len()
+		}
+		if seen[k] {
+			return nil, fmt.Errorf("marshal: encountered a cycle via %v", t)
+		}
+		seen[k] = true
+		defer delete(seen, k)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return trueLit, nil
+		}
+		return falseLit, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := v.Int(); i < 0 {
+			return lisp.Group{negLit, xlisp.Nat(uint64(-(i + 1)) + 1)}, nil
+		}
+		return xlisp.Nat(uint64(v.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return xlisp.Nat(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return xlisp.Quote(strconv.FormatFloat(v.Float(), 'g', -1, t.Bits())), nil
+	case reflect.String:
+		return xlisp.Quote(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		g := make(lisp.Group, 0, v.Len())
+		for i := range v.Len() {
+			x, err := marshal(v.Index(i), seen)
+			if err != nil {
+				return nil, err
+			}
+			g = append(g, x)
+		}
+		return g, nil
+	case reflect.Map:
+		g := make(lisp.Group, 0, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			k, err := marshal(it.Key(), seen)
+			if err != nil {
+				return nil, err
+			}
+			x, err := marshal(it.Value(), seen)
+			if err != nil {
+				return nil, err
+			}
+			g = append(g, lisp.Group{k, x})
+		}
+		slices.SortFunc(g, func(a, b lisp.Val) int {
+			return xlisp.Compare(a.(lisp.Group)[0], b.(lisp.Group)[0])
+		})
+		return g, nil
+	case reflect.Struct:
+		g := lisp.Group{}
+		for _, f := range cachedFields(t) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			x, err := marshal(fv, seen)
+			if err != nil {
+				return nil, err
+			}
+			g = append(g, lisp.Group{f.key, x})
+		}
+		return g, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return lisp.Group{}, nil
+		}
+		return marshal(v.Elem(), seen)
+	default:
+		return nil, fmt.Errorf("marshal: unsupported type %v", t)
+	}
+}
+
+// fieldByIndex returns the field at index or false if it is inside a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// field describes a struct field.
+type field struct {
+	name      string
+	key       lisp.Val // Quoted name.
+	index     []int
+	tag       bool // Whether the name is from a tag.
+	omitEmpty bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+func cachedFields(t reflect.Type) []field {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.([]field)
+	}
+	fs, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return fs.([]field)
+}
+
+// typeFields returns the fields of the struct type t including inlined fields.
+//
+// Conflicting names are resolved as in encoding/json: the shallowest field wins,
+// then a tagged field, otherwise all fields with the name are dropped.
+func typeFields(t reflect.Type) []field {
+	var fs []field
+	collectFields(t, nil, map[reflect.Type]bool{t: true}, &fs)
+
+	type dominant struct {
+		i     int // Index in fs or -1 when the name is dropped.
+		depth int
+		tag   bool
+	}
+	names := make(map[string]*dominant)
+	for i, f := range fs {
+		depth := len(f.index)
+		d, ok := names[f.name]
+		switch {
+		case !ok || depth < d.depth:
+			names[f.name] = &dominant{i, depth, f.tag}
+		case depth > d.depth:
+		case f.tag && !d.tag:
+			d.i, d.tag = i, true
+		case f.tag == d.tag:
+			d.i = -1
+		}
+	}
+	out := fs[:0]
+	for i, f := range fs {
+		if names[f.name].i == i {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// collectFields appends the fields of t to fs in index order.
+//
+// Inlined struct types already on the path are skipped to avoid cycles.
+func collectFields(t reflect.Type, index []int, path map[reflect.Type]bool, fs *[]field) {
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("lisp")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if !sf.IsExported() && !(sf.Anonymous && name == "") {
+			continue
+		}
+		idx := append(index[:len(index):len(index)], i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		inline := hasOpt(opts, "inline") || sf.Anonymous && name == ""
+		if inline && ft.Kind() == reflect.Struct {
+			if !path[ft] {
+				path[ft] = true
+				collectFields(ft, idx, path, fs)
+				delete(path, ft)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		tagged := name != ""
+		if !tagged {
+			name = sf.Name
+		}
+		*fs = append(*fs, field{
+			name:      name,
+			key:       xlisp.Quote(name),
+			index:     idx,
+			tag:       tagged,
+			omitEmpty: hasOpt(opts, "omitempty"),
+		})
+	}
+}
+
+func hasOpt(opts, opt string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// Unmarshal stores the Go value of v in the value pointed to by x.
+func Unmarshal(v lisp.Val, x any) error {
+	rv := reflect.ValueOf(x)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("marshal: Unmarshal requires a non-nil pointer")
+	}
+	if v == nil {
+		return errors.New("marshal: cannot unmarshal nil Val")
+	}
+	return unmarshal(v, rv.Elem())
+}
+
+func typeError(v lisp.Val, t reflect.Type) error {
+	return fmt.Errorf("marshal: cannot unmarshal %v into Go value of type %v", v, t)
+}
+
+func isEmptyGroup(v lisp.Val) bool {
+	g, ok := v.(lisp.Group)
+	return ok && len(g) == 0
+}
+
+func unmarshal(v lisp.Val, dst reflect.Value) error {
+	t := dst.Type()
+	if t == valType || t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		dst.Set(reflect.ValueOf(&v).Elem())
+		return nil
+	}
+	if t.Kind() == reflect.Pointer {
+		if isEmptyGroup(v) {
+			dst.SetZero()
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(t.Elem()))
+		}
+		return unmarshal(v, dst.Elem())
+	}
+	if dst.CanAddr() {
+		switch p := dst.Addr().Interface().(type) {
+		case Unmarshaler:
+			return p.UnmarshalLisp(v)
+		case *lisp.Lit, *lisp.Group:
+			if !reflect.ValueOf(v).Type().AssignableTo(t) {
+				return typeError(v, t)
+			}
+			dst.Set(reflect.ValueOf(v))
+			return nil
+		case encoding.TextUnmarshaler:
+			s, err := xlisp.Unquote(v)
+			if err != nil {
+				return fmt.Errorf("marshal: %w", err)
+			}
+			return p.UnmarshalText([]byte(s))
+		}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		switch v {
+		case trueLit:
+			dst.SetBool(true)
+		case falseLit:
+			dst.SetBool(false)
+		default:
+			return typeError(v, t)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := parseInt(v, t.Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		x, ok := v.(lisp.Lit)
+		if !ok {
+			return typeError(v, t)
+		}
+		u, err := strconv.ParseUint(string(x), 10, t.Bits())
+		if err != nil {
+			return fmt.Errorf("marshal: %w", err)
+		}
+		dst.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		s, err := xlisp.Unquote(v)
+		if err != nil {
+			return fmt.Errorf("marshal: %w", err)
+		}
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return fmt.Errorf("marshal: %w", err)
+		}
+		dst.SetFloat(f)
+	case reflect.String:
+		s, err := xlisp.Unquote(v)
+		if err != nil {
+			return fmt.Errorf("marshal: %w", err)
+		}
+		dst.SetString(s)
+	case reflect.Slice:
+		g, ok := v.(lisp.Group)
+		if !ok {
+			return typeError(v, t)
+		}
+		s := reflect.MakeSlice(t, len(g), len(g))
+		for i, x := range g {
+			if err := unmarshal(x, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+	case reflect.Array:
+		g, ok := v.(lisp.Group)
+		if !ok || len(g) != t.Len() {
+			return typeError(v, t)
+		}
+		for i, x := range g {
+			if err := unmarshal(x, dst.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		g, ok := v.(lisp.Group)
+		if !ok {
+			return typeError(v, t)
+		}
+		m := reflect.MakeMapWithSize(t, len(g))
+		for _, e := range g {
+			kv, ok := e.(lisp.Group)
+			if !ok || len(kv) != 2 {
+				return fmt.Errorf("marshal: expected (k v) pair, got %v", e)
+			}
+			k := reflect.New(t.Key()).Elem()
+			if err := unmarshal(kv[0], k); err != nil {
+				return err
+			}
+			x := reflect.New(t.Elem()).Elem()
+			if err := unmarshal(kv[1], x); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, x)
+		}
+		dst.Set(m)
+	case reflect.Struct:
+		g, ok := v.(lisp.Group)
+		if !ok {
+			return typeError(v, t)
+		}
+		fs := cachedFields(t)
+		for _, e := range g {
+			kv, ok := e.(lisp.Group)
+			if !ok || len(kv) != 2 {
+				return fmt.Errorf("marshal: expected (name v) pair, got %v", e)
+			}
+			name, err := xlisp.Unquote(kv[0])
+			if err != nil {
+				return fmt.Errorf("marshal: %w", err)
+			}
+			i := slices.IndexFunc(fs, func(f field) bool { return f.name == name })
+			if i < 0 {
+				continue // Unknown fields are ignored.
+			}
+			fv, err := allocFieldByIndex(dst, fs[i].index)
+			if err != nil {
+				return err
+			}
+			if err := unmarshal(kv[1], fv); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("marshal: unsupported type %v", t)
+	}
+	return nil
+}
+
+// allocFieldByIndex returns the field at index allocating nil embedded pointers.
+func allocFieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("marshal: cannot set embedded pointer to unexported struct %v", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+func parseInt(v lisp.Val, bits int) (int64, error) {
+	switch v := v.(type) {
+	case lisp.Lit:
+		i, err := strconv.ParseInt(string(v), 10, bits)
+		if err != nil {
+			return 0, fmt.Errorf("marshal: %w", err)
+		}
+		return i, nil
+	case lisp.Group:
+		if len(v) != 2 || v[0] != negLit {
+			break
+		}
+		x, ok := v[1].(lisp.Lit)
+		if !ok {
+			break
+		}
+		i, err := strconv.ParseInt("-"+string(x), 10, bits)
+		if err != nil {
+			return 0, fmt.Errorf("marshal: %w", err)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("marshal: cannot unmarshal %v into Go integer", v)
+}
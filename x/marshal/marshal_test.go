@@ -0,0 +1,375 @@
+package marshal
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParse(t *testing.T, src string) (val lisp.Val) {
+	t.Helper()
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		val = n.Val
+		break
+	}
+	if err := sc.Err(); err != nil {
+		panic(fmt.Sprintf("mustParse: failed to parse: %q: %v", src, err))
+	}
+	return val
+}
+
+type Inner struct {
+	C int
+}
+
+type Point struct {
+	X, Y int
+}
+
+// MarshalLisp encodes the Point as (pt X Y).
+func (p Point) MarshalLisp() (lisp.Val, error) {
+	return Marshal([]any{lisp.Lit("pt"), p.X, p.Y})
+}
+
+func (p *Point) UnmarshalLisp(v lisp.Val) error {
+	var xs []lisp.Val
+	if err := Unmarshal(v, &xs); err != nil {
+		return err
+	}
+	if len(xs) != 3 || xs[0] != lisp.Lit("pt") {
+		return fmt.Errorf("bad Point %v", v)
+	}
+	if err := Unmarshal(xs[1], &p.X); err != nil {
+		return err
+	}
+	return Unmarshal(xs[2], &p.Y)
+}
+
+type Record struct {
+	Name    string
+	Age     uint8          `lisp:"age"`
+	Skip    int            `lisp:"-"`
+	Note    string         `lisp:"note,omitempty"`
+	Tags    []string       `lisp:"tags"`
+	Attrs   map[string]int `lisp:"attrs"`
+	Nested  Inner          `lisp:"nested,inline"`
+	Pt      Point          `lisp:"pt"`
+	Ptr     *int           `lisp:"ptr"`
+	Enabled bool           `lisp:"enabled"`
+	Addr    netip.Addr     `lisp:"addr"`
+	Val     lisp.Val       `lisp:"val"`
+	private int
+}
+
+func TestMarshal(t *testing.T) {
+	n := -3
+	lit := lisp.Lit("a")
+	for _, tc := range []struct {
+		name  string
+		input any
+		want  string
+	}{{
+		name:  "bool",
+		input: true,
+		want:  "true",
+	}, {
+		name:  "uint",
+		input: uint(42),
+		want:  "42",
+	}, {
+		name:  "negative int",
+		input: -7,
+		want:  "(neg 7)",
+	}, {
+		name:  "min int64",
+		input: int64(math.MinInt64),
+		want:  "(neg 9223372036854775808)",
+	}, {
+		name:  "float",
+		input: 1.5,
+		want:  "(q 1(u 46)5)",
+	}, {
+		name:  "string Lit",
+		input: "abc",
+		want:  "abc",
+	}, {
+		name:  "quoted string",
+		input: "a b!",
+		want:  "(q a(u 32)b(u 33))",
+	}, {
+		name:  "empty string",
+		input: "",
+		want:  "(q)",
+	}, {
+		name:  "slice",
+		input: []int{1, 2, -3},
+		want:  "(1 2(neg 3))",
+	}, {
+		name:  "map sorted by key",
+		input: map[string]int{"b": 2, "a": 1},
+		want:  "((a 1)(b 2))",
+	}, {
+		name:  "nil pointer",
+		input: (*int)(nil),
+		want:  "()",
+	}, {
+		name:  "Lit pointer",
+		input: &lit,
+		want:  "a",
+	}, {
+		name: "Lit pointer field",
+		input: struct {
+			P *lisp.Lit
+			G *lisp.Group
+		}{P: &lit},
+		want: "((P a)(G ()))",
+	}, {
+		name: "struct",
+		input: Record{
+			Name:    "bob",
+			Age:     30,
+			Skip:    1,
+			Tags:    []string{"x"},
+			Attrs:   map[string]int{"k": 1},
+			Nested:  Inner{C: 3},
+			Pt:      Point{1, 2},
+			Ptr:     &n,
+			Enabled: true,
+			Addr:    netip.MustParseAddr("1.2.3.4"),
+			Val:     lisp.Group{lisp.Lit("v")},
+		},
+		want: "((Name bob)(age 30)(tags (x))(attrs ((k 1)))(C 3)(pt (pt 1 2))(ptr (neg 3))(enabled true)(addr (q 1(u 46)2(u 46)3(u 46)4))(val (v)))",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Marshal(tc.input)
+			if err != nil {
+				t.Fatalf("Marshal(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(mustParse(t, tc.want), got); diff != "" {
+				t.Errorf("Marshal(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	n := 5
+	for _, tc := range []struct {
+		name  string
+		input any
+	}{
+		{"bool", false},
+		{"int8", int8(-128)},
+		{"uint64", uint64(math.MaxUint64)},
+		{"float32", float32(-2.25)},
+		{"string", "hello, 世界 (x)"},
+		{"array", [2]string{"a", ""}},
+		{"slice of slices", [][]int{{1}, {}, {2, 3}}},
+		{"map", map[int]bool{-1: true, 2: false}},
+		{"pointer", &n},
+		{"Lit", lisp.Lit("x")},
+		{"Group", lisp.Group{lisp.Lit("x"), lisp.Group{}}},
+		{"struct", Record{
+			Name:   "a b",
+			Tags:   []string{"c"},
+			Attrs:  map[string]int{},
+			Nested: Inner{C: -1},
+			Pt:     Point{3, 4},
+			Ptr:    &n,
+			Addr:   netip.MustParseAddr("::1"),
+			Val:    lisp.Lit("v"),
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := Marshal(tc.input)
+			if err != nil {
+				t.Fatalf("Marshal(%q): got err = %v", tc.name, err)
+			}
+			got := reflect.New(reflect.TypeOf(tc.input))
+			if err := Unmarshal(v, got.Interface()); err != nil {
+				t.Fatalf("Unmarshal(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(tc.input, got.Elem().Interface(), cmp.AllowUnexported(Record{}), cmp.Comparer(func(a, b netip.Addr) bool { return a == b })); diff != "" {
+				t.Errorf("Unmarshal(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+type node struct{ Next *node }
+
+func TestMarshalCycle(t *testing.T) {
+	n := &node{}
+	n.Next = n
+	m := map[string]any{}
+	m["m"] = m
+	s := []any{nil}
+	s[0] = s
+	for _, input := range []any{n, m, s} {
+		if _, err := Marshal(input); err == nil {
+			t.Errorf("Marshal(%T): got err = nil, want cycle error", input)
+		}
+	}
+	// Shared values are not cycles.
+	shared := &node{}
+	if _, err := Marshal([]*node{shared, shared}); err != nil {
+		t.Errorf("Marshal(shared): got err = %v", err)
+	}
+}
+
+type Rec struct {
+	*Rec
+	X int
+}
+
+type Base struct {
+	Name string
+	Id   int
+	Ext  int `lisp:"ext"`
+}
+
+type Tagged struct {
+	Alt string `lisp:"Name"`
+}
+
+type Derived struct {
+	Base
+	Tagged
+	Id  string
+	Ext string
+}
+
+type Other struct {
+	C, D int
+}
+
+type Ambiguous struct {
+	Inner
+	Other
+}
+
+func TestEmbedded(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input any
+		want  string
+	}{{
+		name:  "recursive",
+		input: Rec{X: 1},
+		want:  "((X 1))",
+	}, {
+		name:  "recursive set",
+		input: Rec{Rec: &Rec{X: 2}, X: 1},
+		want:  "((X 1))",
+	}, {
+		name:  "shadowed",
+		input: Derived{Base: Base{Name: "a", Id: 1, Ext: 2}, Tagged: Tagged{Alt: "b"}, Id: "c", Ext: "d"},
+		want:  "((ext 2) (Name b) (Id c) (Ext d))",
+	}, {
+		name:  "ambiguous",
+		input: Ambiguous{Inner: Inner{C: 1}, Other: Other{C: 2, D: 3}},
+		want:  "((D 3))",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := Marshal(tc.input)
+			if err != nil {
+				t.Fatalf("Marshal(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(mustParse(t, tc.want), v); diff != "" {
+				t.Errorf("Marshal(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+			got := reflect.New(reflect.TypeOf(tc.input))
+			if err := Unmarshal(v, got.Interface()); err != nil {
+				t.Fatalf("Unmarshal(%q): got err = %v", tc.name, err)
+			}
+			again, err := Marshal(got.Elem().Interface())
+			if err != nil {
+				t.Fatalf("Marshal(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(v, again); diff != "" {
+				t.Errorf("Marshal(Unmarshal(%q)): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		dst   any
+	}{
+		{"bool", "yes", new(bool)},
+		{"int overflow", "300", new(int8)},
+		{"negative uint", "(neg 1)", new(uint)},
+		{"slice from Lit", "a", new([]int)},
+		{"array length", "(1 2 3)", new([2]int)},
+		{"bad pair", "(a)", new(map[string]int)},
+		{"bad quote", "(x)", new(string)},
+		{"Lit from Group", "(a)", new(lisp.Lit)},
+		{"Unmarshaler", "(pt 1)", new(Point)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Unmarshal(mustParse(t, tc.input), tc.dst); err == nil {
+				t.Errorf("Unmarshal(%q): got err = nil, want err", tc.name)
+			}
+		})
+	}
+	if err := Unmarshal(lisp.Lit("1"), 0); err == nil {
+		t.Errorf("Unmarshal(non-pointer): got err = nil, want err")
+	}
+}
+
+func TestTextMarshaler(t *testing.T) {
+	for _, input := range []string{"a", "()", "(a b(c)()1)"} {
+		v := mustParse(t, input)
+		var (
+			text []byte
+			err  error
+			got  lisp.Val
+		)
+		switch v := v.(type) {
+		case lisp.Lit:
+			text, err = v.MarshalText()
+			var x lisp.Lit
+			if err == nil {
+				err = x.UnmarshalText(text)
+			}
+			got = x
+		case lisp.Group:
+			text, err = v.MarshalText()
+			var x lisp.Group
+			if err == nil {
+				err = x.UnmarshalText(text)
+			}
+			got = x
+		}
+		if err != nil {
+			t.Fatalf("MarshalText(%q): got err = %v", input, err)
+		}
+		if string(text) != input {
+			t.Errorf("MarshalText(%q): got %q", input, text)
+		}
+		if diff := cmp.Diff(v, got); diff != "" {
+			t.Errorf("UnmarshalText(%q): got diff (-want, +got):\n%v", input, diff)
+		}
+	}
+	var g lisp.Group
+	for _, input := range []string{"", "a", "(a", "a)", "(a) b", "(a!)"} {
+		if err := g.UnmarshalText([]byte(input)); err == nil {
+			t.Errorf("UnmarshalText(%q): got err = nil, want err", input)
+		}
+	}
+	if _, err := (lisp.Group{lisp.Lit("a b")}).MarshalText(); err == nil {
+		t.Errorf("MarshalText(invalid Lit): got err = nil, want err")
+	}
+}
@@ -0,0 +1,213 @@
+package print
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// LayoutRule controls how a Group with a given head Lit is broken across lines.
+type LayoutRule struct {
+	// Args is the number of elements after the head
+	// kept on the head line when the Group is broken.
+	Args int
+}
+
+// docKind is the kind of a layout document.
+type docKind uint8
+
+const (
+	docText   docKind = iota // Text.
+	docLine                  // Text when flat or a new line otherwise.
+	docConcat                // Concatenation of docs.
+	docNest                  // Indent docs[0] n columns from the current column.
+	docGroup                 // Lay out docs[0] flat if it fits or else broken.
+)
+
+// doc is a layout document in the style of Wadler's "A prettier printer".
+type doc struct {
+	kind docKind
+	text string
+	n    int
+	docs []*doc
+}
+
+// litLike reports whether v is printed as a Lit and delimited from adjacent Lits.
+func (p *Printer) litLike(v lisp.Val) bool {
+	switch v.(type) {
+	case lisp.Lit:
+		return true
+	case nil:
+		return xlisp.ValidLit(lisp.Lit(p.Nil))
+	default:
+		return false
+	}
+}
+
+// makeDoc returns the layout document for v at the given Group depth.
+func (p *Printer) makeDoc(v lisp.Val, depth int) *doc {
+	switch v := v.(type) {
+	case lisp.Lit:
+		return &doc{kind: docText, text: string(v)}
+	case nil:
+		return &doc{kind: docText, text: p.Nil}
+	case lisp.Group:
+		if len(v) == 0 {
+			return &doc{kind: docText, text: "()"}
+		}
+		if p.MaxDepth > 0 && depth > p.MaxDepth {
+			return &doc{kind: docText, text: "(" + p.ellipsis() + ")"}
+		}
+		args := 0
+		if head, ok := v[0].(lisp.Lit); ok {
+			args = p.Rules[head].Args
+		}
+		elems := v
+		if p.MaxElems > 0 && len(elems) > p.MaxElems {
+			elems = elems[:p.MaxElems]
+		}
+		docs := []*doc{{kind: docText, text: "("}, p.makeDoc(v[0], depth+1)}
+		lit := p.litLike(v[0])
+		for i := 1; i <= len(elems); i++ {
+			kind := docLine
+			if i <= args {
+				kind = docText
+			}
+			var (
+				d    *doc
+				next bool
+			)
+			if i == len(elems) {
+				if len(elems) == len(v) {
+					break
+				}
+				// Elements were cut by MaxElems.
+				d, next = &doc{kind: docText, text: p.ellipsis()}, true
+			} else {
+				d = p.makeDoc(v[i], depth+1)
+				next = p.litLike(v[i])
+			}
+			// Adjacent Lits are separated by a space.
+			text := ""
+			if lit && next {
+				text = " "
+			}
+			docs = append(docs, &doc{kind: kind, text: text}, d)
+			lit = next
+		}
+		docs = append(docs, &doc{kind: docText, text: ")"})
+		indent := p.Indent
+		if indent == 0 {
+			indent = 2
+		}
+		return &doc{kind: docGroup, docs: []*doc{{
+			kind: docNest,
+			n:    indent,
+			docs: []*doc{{kind: docConcat, docs: docs}},
+		}}}
+	default:
+		panic("Unexpected Val type")
+	}
+}
+
+// layoutItem is a document to be laid out at the given indent.
+type layoutItem struct {
+	indent int
+	flat   bool
+	d      *doc
+}
+
+// fits reports whether x fits in the remaining width followed by the rest
+// of the documents up to the next new line.
+func fits(width int, x layoutItem, rest []layoutItem) bool {
+	stack := []layoutItem{x}
+	for width >= 0 {
+		if len(stack) == 0 {
+			if len(rest) == 0 {
+				return true
+			}
+			stack = append(stack, rest[len(rest)-1])
+			rest = rest[:len(rest)-1]
+		}
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch it.d.kind {
+		case docText:
+			width -= utf8.RuneCountInString(it.d.text)
+		case docLine:
+			if !it.flat {
+				return true
+			}
+			width -= len(it.d.text)
+		case docConcat:
+			for i := len(it.d.docs) - 1; i >= 0; i-- {
+				stack = append(stack, layoutItem{it.indent, it.flat, it.d.docs[i]})
+			}
+		case docNest, docGroup:
+			stack = append(stack, layoutItem{it.indent, it.flat, it.d.docs[0]})
+		}
+	}
+	return false
+}
+
+// layout writes the document d fit to the Width.
+// When Width is not positive, d is written on one line.
+func (p *Printer) layout(d *doc) {
+	width := p.Width - utf8.RuneCountInString(p.Prefix)
+	col := 0
+	// n is the number of bytes written for MaxBytes.
+	n := 0
+	write := func(s string) bool {
+		if p.MaxBytes <= 0 || n+len(s) <= p.MaxBytes {
+			p.w.WriteString(s)
+			n += len(s)
+			return true
+		}
+		// Cut s at a rune boundary within the budget.
+		i := p.MaxBytes - n
+		for i > 0 && !utf8.RuneStart(s[i]) {
+			i--
+		}
+		p.w.WriteString(s[:i])
+		p.w.WriteString(p.ellipsis())
+		return false
+	}
+	stack := []layoutItem{{d: d}}
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch it.d.kind {
+		case docText:
+			if !write(it.d.text) {
+				return
+			}
+			col += utf8.RuneCountInString(it.d.text)
+		case docLine:
+			if it.flat {
+				if !write(it.d.text) {
+					return
+				}
+				col += len(it.d.text)
+				continue
+			}
+			if !write("\n" + p.Prefix + strings.Repeat(" ", it.indent)) {
+				return
+			}
+			col = it.indent
+		case docConcat:
+			for i := len(it.d.docs) - 1; i >= 0; i-- {
+				stack = append(stack, layoutItem{it.indent, it.flat, it.d.docs[i]})
+			}
+		case docNest:
+			stack = append(stack, layoutItem{col + it.d.n, it.flat, it.d.docs[0]})
+		case docGroup:
+			x := layoutItem{it.indent, true, it.d.docs[0]}
+			if p.Width > 0 && !it.flat && !fits(width-col, x, stack) {
+				x.flat = false
+			}
+			stack = append(stack, x)
+		}
+	}
+}
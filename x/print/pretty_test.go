@@ -0,0 +1,218 @@
+package print
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/ajzaff/lisp/x/fuzzutil"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParse(t *testing.T, src string) (val lisp.Val) {
+	t.Helper()
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		val = n.Val
+		break
+	}
+	if err := sc.Err(); err != nil {
+		panic(fmt.Sprintf("mustParse: failed to parse: %q: %v", src, err))
+	}
+	return val
+}
+
+func TestPrettyPrint(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  PrinterOptions
+		want  string
+	}{{
+		name:  "fits",
+		input: "(a b (c d))",
+		opts:  PrinterOptions{Width: 20},
+		want:  "(a b(c d))",
+	}, {
+		name:  "break",
+		input: "(add (mul 1 2) (mul 3 4))",
+		opts:  PrinterOptions{Width: 16},
+		want:  "(add\n  (mul 1 2)\n  (mul 3 4))",
+	}, {
+		name:  "break nested",
+		input: "(a (bbbb cccc dddd) e)",
+		opts:  PrinterOptions{Width: 10},
+		want:  "(a\n  (bbbb\n    cccc\n    dddd)\n  e)",
+	}, {
+		name:  "closing parens count",
+		input: "(a (b c))",
+		opts:  PrinterOptions{Width: 7},
+		want:  "(a\n  (b\n    c))",
+	}, {
+		name:  "indent",
+		input: "(a b c)",
+		opts:  PrinterOptions{Width: 4, Indent: 4},
+		want:  "(a\n    b\n    c)",
+	}, {
+		name:  "layout rule",
+		input: "(define (f x) (g x) (h x))",
+		opts: PrinterOptions{
+			Width: 16,
+			Rules: map[lisp.Lit]LayoutRule{"define": {Args: 1}},
+		},
+		want: "(define(f x)\n  (g x)\n  (h x))",
+	}, {
+		name:  "prefix and new line",
+		input: "(a b)",
+		opts:  PrinterOptions{Width: 5, Prefix: "; ", NewLine: true},
+		want:  "; (a\n;   b)\n",
+	}, {
+		name:  "Lit",
+		input: "abc",
+		opts:  PrinterOptions{Width: 1},
+		want:  "abc",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			var p Printer
+			p.PrinterOptions = tc.opts
+			p.Reset(&sb)
+			p.Print(mustParse(t, tc.input))
+			if diff := cmp.Diff(tc.want, sb.String()); diff != "" {
+				t.Errorf("Print(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestPrettyPrintReparse(t *testing.T) {
+	g := fuzzutil.NewGenerator(rand.New(rand.NewSource(1337)))
+	g.GroupMaxDepth = 6
+	for i := range 200 {
+		want := g.Next()
+		var sb strings.Builder
+		var p Printer
+		p.Width = 1 + i%40
+		p.Rules = map[lisp.Lit]LayoutRule{"a": {Args: 2}}
+		p.Reset(&sb)
+		p.Print(want)
+		if got := mustParse(t, sb.String()); !cmp.Equal(want, got) {
+			t.Errorf("Print(%v) with Width %d: reparse got diff (-want, +got):\n%v", want, p.Width, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  PrinterOptions
+		want  string
+	}{{
+		name:  "no limits",
+		input: "(a (b (c)) d)",
+		opts:  PrinterOptions{},
+		want:  "(a(b(c))d)",
+	}, {
+		name:  "max depth",
+		input: "(a (b (c)) () d)",
+		opts:  PrinterOptions{MaxDepth: 2},
+		want:  "(a(b(...))()d)",
+	}, {
+		name:  "max elems",
+		input: "(a b c d e)",
+		opts:  PrinterOptions{MaxElems: 3},
+		want:  "(a b c ...)",
+	}, {
+		name:  "max elems after group",
+		input: "(a (b) c)",
+		opts:  PrinterOptions{MaxElems: 2},
+		want:  "(a(b)...)",
+	}, {
+		name:  "max elems nested",
+		input: "((a b c) (d e f) (g))",
+		opts:  PrinterOptions{MaxElems: 2},
+		want:  "((a b ...)(d e ...)...)",
+	}, {
+		name:  "max bytes",
+		input: "(abc def ghi)",
+		opts:  PrinterOptions{MaxBytes: 6},
+		want:  "(abc d...",
+	}, {
+		name:  "max bytes fits",
+		input: "(abc def)",
+		opts:  PrinterOptions{MaxBytes: 9},
+		want:  "(abc def)",
+	}, {
+		name:  "max bytes rune boundary",
+		input: "(αβγ)",
+		opts:  PrinterOptions{MaxBytes: 4},
+		want:  "(α...",
+	}, {
+		name:  "ellipsis",
+		input: "(a b c)",
+		opts:  PrinterOptions{MaxElems: 1, Ellipsis: "etc"},
+		want:  "(a etc)",
+	}, {
+		name:  "pretty",
+		input: "(add (mul 1 2 3 4) (mul 3 4))",
+		opts:  PrinterOptions{Width: 16, MaxElems: 3},
+		want:  "(add\n  (mul 1 2 ...)\n  (mul 3 4))",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			p := Printer{PrinterOptions: tc.opts}
+			p.Reset(&sb)
+			p.Print(mustParse(t, tc.input))
+			got := sb.String()
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Print(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestTruncateFunc(t *testing.T) {
+	got := Truncate(mustParse(t, "(a b c d e f)"), 6)
+	if want := "(a b c..."; got != want {
+		t.Errorf("Truncate(): got %q, want %q", got, want)
+	}
+}
+
+func TestPrettyPrintNil(t *testing.T) {
+	input := lisp.Group{lisp.Lit("a"), nil, lisp.Lit("b"), lisp.Group{nil}}
+	for _, tc := range []struct {
+		name string
+		opts PrinterOptions
+		want string
+	}{{
+		name: "nil",
+		opts: PrinterOptions{Nil: "()", Width: 20},
+		want: "(a()b(()))",
+	}, {
+		name: "Lit-like nil",
+		opts: PrinterOptions{Nil: "nil", Width: 20},
+		want: "(a nil b(nil))",
+	}, {
+		name: "truncated",
+		opts: PrinterOptions{Nil: "nil", MaxElems: 2},
+		want: "(a nil ...)",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			p := Printer{PrinterOptions: tc.opts}
+			p.Reset(&sb)
+			p.Print(input)
+			if diff := cmp.Diff(tc.want, sb.String()); diff != "" {
+				t.Errorf("Print(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+	if got, want := Truncate(input, 100), "(a()b(()))"; got != want {
+		t.Errorf("Truncate(): got %q, want %q", got, want)
+	}
+}
@@ -4,6 +4,7 @@ package print
 import (
 	"bufio"
 	"io"
+	"strings"
 	"sync"
 
 	"github.com/ajzaff/lisp"
@@ -25,6 +26,49 @@ type PrinterOptions struct {
 	Nil     string
 	Prefix  string // Prefix added before every line.
 	NewLine bool   // Whether a new line is added after every top-level expression.
+
+	// Width is the target line width for pretty printing.
+	// When Width is positive, Groups which do not fit are broken across lines
+	// with their elements indented under the head.
+	// Zero prints each top-level expression on one line.
+	Width int
+
+	// Indent is the number of columns broken elements are indented
+	// from the opening paren when pretty printing. Zero uses 2.
+	Indent int
+
+	// Rules are layout rules for Groups by head Lit when pretty printing.
+	Rules map[lisp.Lit]LayoutRule
+
+	// MaxDepth limits the depth of nested Groups.
+	// Groups nested deeper are printed as the Ellipsis in parens.
+	// Zero means no limit.
+	MaxDepth int
+
+	// MaxElems limits the number of elements printed in each Group.
+	// Remaining elements are replaced by the Ellipsis.
+	// Zero means no limit.
+	MaxElems int
+
+	// MaxBytes limits the number of bytes printed for each top-level expression.
+	// Text beyond the limit is cut and followed by the Ellipsis.
+	// Zero means no limit.
+	MaxBytes int
+
+	// Ellipsis marks truncated text. Empty uses "...".
+	Ellipsis string
+}
+
+// truncates reports whether any truncation limits are set.
+func (o PrinterOptions) truncates() bool {
+	return o.MaxDepth > 0 || o.MaxElems > 0 || o.MaxBytes > 0
+}
+
+func (o PrinterOptions) ellipsis() string {
+	if o.Ellipsis == "" {
+		return "..."
+	}
+	return o.Ellipsis
 }
 
 func makeStdPrinterOptions() PrinterOptions {
@@ -98,7 +142,26 @@ func (p *Printer) Print(v lisp.Val) {
 		}
 		return
 	}
+	if p.Width > 0 || p.truncates() {
+		p.w.WriteString(p.Prefix)
+		p.layout(p.makeDoc(v, 1))
+		if p.NewLine {
+			p.w.WriteByte('\n')
+		}
+		return
+	}
 	p.once.Do(p.initVisitor)
 	p.w.WriteString(p.Prefix)
 	p.v.Visit(v)
 }
+
+// Truncate returns the text of v on one line
+// cut to at most maxBytes bytes followed by "...".
+// Truncate is useful for logging large values.
+func Truncate(v lisp.Val, maxBytes int) string {
+	var sb strings.Builder
+	p := Printer{PrinterOptions: PrinterOptions{Nil: "()", MaxBytes: maxBytes}}
+	p.Reset(&sb)
+	p.Print(v)
+	return sb.String()
+}
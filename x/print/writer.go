@@ -0,0 +1,193 @@
+package print
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Errors returned by the Writer.
+var (
+	ErrInvalidLit = errors.New("invalid Lit")
+	ErrUnbalanced = errors.New("unbalanced Group")
+)
+
+// StreamWriter is implemented by streaming Lisp writers such as Writer and blisp.Encoder.
+type StreamWriter interface {
+	BeginGroup() error
+	WriteLit(lisp.Lit) error
+	EndGroup() error
+}
+
+// Writer writes Lisp values incrementally without building a Val in memory.
+//
+// Writer validates Lits and balanced nesting of Groups.
+// The first error is sticky and returned from all subsequent calls.
+type Writer struct {
+	w     *bufio.Writer
+	depth int
+	delim bool
+	err   error
+
+	PrinterOptions
+}
+
+// NewWriter returns a Writer which uses the standard PrinterOptions.
+func NewWriter(w io.Writer) *Writer {
+	var sw Writer
+	sw.PrinterOptions = makeStdPrinterOptions()
+	sw.Reset(w)
+	return &sw
+}
+
+// Reset resets the Writer to use the given writer and clears any error.
+func (w *Writer) Reset(dst io.Writer) {
+	w.depth = 0
+	w.delim = false
+	w.err = nil
+	if dst, ok := dst.(*bufio.Writer); ok {
+		w.w = dst
+		return
+	}
+	if w.w == nil {
+		w.w = new(bufio.Writer)
+	}
+	w.w.Reset(dst)
+}
+
+// Depth returns the number of open Groups.
+func (w *Writer) Depth() int { return w.depth }
+
+// Err returns the first error encountered by the Writer.
+func (w *Writer) Err() error { return w.err }
+
+func (w *Writer) setErr(err error) error {
+	if w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+func (w *Writer) beginVal() {
+	if w.depth == 0 {
+		w.w.WriteString(w.Prefix)
+	}
+}
+
+func (w *Writer) endVal() {
+	if w.depth == 0 && w.NewLine {
+		w.w.WriteByte('\n')
+		w.delim = false
+	}
+}
+
+// BeginGroup opens a new Group.
+func (w *Writer) BeginGroup() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.beginVal()
+	w.depth++
+	w.delim = false
+	if err := w.w.WriteByte('('); err != nil {
+		return w.setErr(err)
+	}
+	return nil
+}
+
+// EndGroup closes the innermost open Group.
+func (w *Writer) EndGroup() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.depth == 0 {
+		return w.setErr(ErrUnbalanced)
+	}
+	w.depth--
+	w.delim = false
+	if err := w.w.WriteByte(')'); err != nil {
+		return w.setErr(err)
+	}
+	w.endVal()
+	return nil
+}
+
+// WriteLit writes the Lit x.
+func (w *Writer) WriteLit(x lisp.Lit) error {
+	if w.err != nil {
+		return w.err
+	}
+	if !xlisp.ValidLit(x) {
+		return w.setErr(ErrInvalidLit)
+	}
+	w.beginVal()
+	if w.delim {
+		w.w.WriteByte(' ')
+	}
+	if _, err := w.w.WriteString(string(x)); err != nil {
+		return w.setErr(err)
+	}
+	w.delim = true
+	w.endVal()
+	return nil
+}
+
+// WriteVal writes the complete Val v.
+//
+// A nil Val is written using the Nil option.
+// A Nil which is a valid Lit is delimited from adjacent Lits like a Lit.
+func (w *Writer) WriteVal(v lisp.Val) error {
+	switch v := v.(type) {
+	case lisp.Lit:
+		return w.WriteLit(v)
+	case lisp.Group:
+		if err := w.BeginGroup(); err != nil {
+			return err
+		}
+		for _, e := range v {
+			if err := w.WriteVal(e); err != nil {
+				return err
+			}
+		}
+		return w.EndGroup()
+	case nil:
+		if w.err != nil {
+			return w.err
+		}
+		w.beginVal()
+		lit := xlisp.ValidLit(lisp.Lit(w.Nil))
+		if lit && w.delim {
+			w.w.WriteByte(' ')
+		}
+		if _, err := w.w.WriteString(w.Nil); err != nil {
+			return w.setErr(err)
+		}
+		w.delim = lit
+		w.endVal()
+		return nil
+	default:
+		panic("Unexpected Val type")
+	}
+}
+
+// Flush flushes buffered output to the underlying writer.
+func (w *Writer) Flush() error {
+	if err := w.w.Flush(); err != nil {
+		return w.setErr(err)
+	}
+	return w.err
+}
+
+// Close flushes the Writer and reports an error if any Group remains open.
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if w.depth != 0 {
+		return w.setErr(ErrUnbalanced)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package print
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriter(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		input   func(w *Writer) error
+		want    string
+		wantErr error
+	}{{
+		name:  "empty",
+		input: func(w *Writer) error { return nil },
+	}, {
+		name:  "lit",
+		input: func(w *Writer) error { return w.WriteLit("hello") },
+		want:  "hello\n",
+	}, {
+		name: "group",
+		input: func(w *Writer) error {
+			w.BeginGroup()
+			w.WriteLit("add")
+			w.WriteLit("1")
+			w.WriteLit("2")
+			return w.EndGroup()
+		},
+		want: "(add 1 2)\n",
+	}, {
+		name: "nested group",
+		input: func(w *Writer) error {
+			w.BeginGroup()
+			w.WriteLit("x")
+			w.BeginGroup()
+			w.WriteLit("y")
+			w.EndGroup()
+			w.WriteLit("z")
+			return w.EndGroup()
+		},
+		want: "(x(y)z)\n",
+	}, {
+		name: "multiple top-level values",
+		input: func(w *Writer) error {
+			w.WriteLit("a")
+			return w.WriteVal(lisp.Group{lisp.Lit("b")})
+		},
+		want: "a\n(b)\n",
+	}, {
+		name: "matches StdPrinter",
+		input: func(w *Writer) error {
+			return w.WriteVal(lisp.Group{lisp.Lit("x"), lisp.Group{}, lisp.Lit("y"), lisp.Lit("z")})
+		},
+		want: "(x()y z)\n",
+	}, {
+		name: "nil",
+		input: func(w *Writer) error {
+			return w.WriteVal(lisp.Group{lisp.Lit("a"), nil, lisp.Group{}, nil})
+		},
+		want: "(a()()())\n",
+	}, {
+		name: "Lit-like nil is delimited",
+		input: func(w *Writer) error {
+			w.Nil = "nil"
+			return w.WriteVal(lisp.Group{lisp.Lit("a"), nil, lisp.Lit("b"), lisp.Group{}, nil})
+		},
+		want: "(a nil b()nil)\n",
+	}, {
+		name:    "invalid Lit",
+		input:   func(w *Writer) error { return w.WriteLit("a b") },
+		wantErr: ErrInvalidLit,
+	}, {
+		name:    "empty Lit",
+		input:   func(w *Writer) error { return w.WriteLit("") },
+		wantErr: ErrInvalidLit,
+	}, {
+		name:    "unexpected EndGroup",
+		input:   func(w *Writer) error { return w.EndGroup() },
+		wantErr: ErrUnbalanced,
+	}, {
+		name: "unclosed Group",
+		input: func(w *Writer) error {
+			w.BeginGroup()
+			return w.WriteLit("a")
+		},
+		want:    "(a",
+		wantErr: ErrUnbalanced,
+	}, {
+		name: "errors are sticky",
+		input: func(w *Writer) error {
+			w.WriteLit("")
+			return w.WriteLit("a")
+		},
+		wantErr: ErrInvalidLit,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			w := NewWriter(&sb)
+			err := tc.input(w)
+			if err == nil {
+				err = w.Close()
+			} else {
+				w.Flush()
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Writer(%q): got err = %v, want err = %v", tc.name, err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, sb.String()); diff != "" {
+				t.Errorf("Writer(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestWriterReportsWriteErrors(t *testing.T) {
+	w := NewWriter(errWriter{})
+	w.WriteLit("a")
+	if err := w.Close(); err == nil {
+		t.Errorf("Close(): got err = nil, want write error")
+	}
+	if err := w.WriteLit("b"); err == nil {
+		t.Errorf("WriteLit() after failed write: got err = nil, want sticky error")
+	}
+}
@@ -0,0 +1,276 @@
+// Package reparse implements incremental reparsing of Lisp source for editors.
+//
+// A Tree records the position of every node relative to its parent.
+// After an edit, Apply rescans only the innermost Group enclosing the edit
+// and reuses all other subtrees unchanged.
+package reparse
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+)
+
+// Node is a position-annotated Val.
+//
+// Node positions are relative to the start of the parent Group, or the source for top-level nodes.
+// Nodes are immutable once parsed and may be shared between Trees.
+type Node struct {
+	Off   scan.Pos // Offset of the node from the start of the parent.
+	Len   scan.Pos // Length of the node source in bytes.
+	Val   lisp.Val
+	Nodes []*Node // Child nodes of a Group.
+}
+
+// Tree is a position-annotated parse of Lisp source.
+type Tree struct {
+	Src   []byte
+	Nodes []*Node
+}
+
+// Edit replaces the source range [Pos, End) with Text.
+type Edit struct {
+	Pos  scan.Pos
+	End  scan.Pos
+	Text []byte
+}
+
+// Parse parses src into a Tree.
+func Parse(src []byte) (*Tree, error) {
+	nodes, err := parse(src, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{Src: src, Nodes: nodes}, nil
+}
+
+// parse returns the top-level nodes in src which begins at offset base.
+func parse(src []byte, base scan.Pos) ([]*Node, error) {
+	type frame struct {
+		node  *Node
+		start scan.Pos
+	}
+	var (
+		sc    scan.Scanner
+		root  []*Node
+		stack []frame
+	)
+	appendNode := func(n *Node, pos scan.Pos) {
+		if len(stack) == 0 {
+			n.Off = pos
+			root = append(root, n)
+			return
+		}
+		f := stack[len(stack)-1]
+		n.Off = pos - f.start
+		f.node.Nodes = append(f.node.Nodes, n)
+		f.node.Val = append(f.node.Val.(lisp.Group), n.Val)
+	}
+	sc.Reset(bytes.NewReader(src))
+	for t := range sc.Tokens() {
+		pos := base + t.Pos
+		switch t.Tok {
+		case lisp.LParen:
+			stack = append(stack, frame{&Node{Val: lisp.Group{}}, pos})
+		case lisp.RParen:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unexpected ) at %d", pos)
+			}
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			f.node.Len = pos + 1 - f.start
+			appendNode(f.node, f.start)
+		case lisp.Id:
+			appendNode(&Node{Len: scan.Pos(len(t.Text)), Val: lisp.Lit(t.Text)}, pos)
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", t.Text, pos)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("unclosed ( at %d", stack[len(stack)-1].start)
+	}
+	return root, nil
+}
+
+// Apply returns a new Tree with the Edit e applied.
+//
+// Apply rescans the innermost Group which strictly encloses the edit, falling back to
+// enclosing Groups and finally the affected top-level nodes when the edit unbalances a Group.
+// The receiver is not modified.
+func (t *Tree) Apply(e Edit) (*Tree, error) {
+	if e.Pos < 0 || e.End < e.Pos || int(e.End) > len(t.Src) {
+		return nil, fmt.Errorf("edit [%d, %d) out of range", e.Pos, e.End)
+	}
+	src := make([]byte, 0, len(t.Src)-int(e.End-e.Pos)+len(e.Text))
+	src = append(src, t.Src[:e.Pos]...)
+	src = append(src, e.Text...)
+	src = append(src, t.Src[e.End:]...)
+	delta := scan.Pos(len(e.Text)) - (e.End - e.Pos)
+
+	// Find the path of Groups strictly enclosing the edit.
+	var (
+		path  []int      // Index of each node in its parent.
+		start []scan.Pos // Absolute start of each node.
+	)
+	nodes, base := t.Nodes, scan.Pos(0)
+	for {
+		i := enclosing(nodes, base, e)
+		if i < 0 {
+			break
+		}
+		path = append(path, i)
+		start = append(start, base+nodes[i].Off)
+		nodes, base = nodes[i].Nodes, base+nodes[i].Off
+	}
+
+	// Rescan from the innermost enclosing Group outwards.
+	for k := len(path) - 1; k >= 0; k-- {
+		old := t.node(path[:k+1])
+		n := old.Len + delta
+		ns, err := parse(src[start[k]:start[k]+n], 0)
+		if err != nil || len(ns) != 1 || ns[0].Off != 0 || ns[0].Len != n {
+			continue
+		}
+		if _, ok := ns[0].Val.(lisp.Group); !ok {
+			continue
+		}
+		ns[0].Off = old.Off
+		return &Tree{Src: src, Nodes: t.splice(path[:k+1], ns[0], delta)}, nil
+	}
+
+	// Rescan the affected top-level nodes.
+	i, j := affected(t.Nodes, e)
+	from, to := e.Pos, e.End
+	if i < j {
+		from = min(from, t.Nodes[i].Off)
+		to = max(to, t.Nodes[j-1].Off+t.Nodes[j-1].Len)
+	}
+	ns, err := parse(src[from:to+delta], from)
+	if err != nil {
+		// The edit unbalanced the source; rescan to the end.
+		if ns, err = parse(src[from:], from); err != nil {
+			return nil, err
+		}
+		j = len(t.Nodes)
+	}
+	top := make([]*Node, 0, len(t.Nodes)-(j-i)+len(ns))
+	top = append(top, t.Nodes[:i]...)
+	top = append(top, ns...)
+	for _, n := range t.Nodes[j:] {
+		top = append(top, shift(n, delta))
+	}
+	return &Tree{Src: src, Nodes: top}, nil
+}
+
+// enclosing returns the index of the Group in nodes which strictly encloses e or -1.
+func enclosing(nodes []*Node, base scan.Pos, e Edit) int {
+	for i, n := range nodes {
+		pos := base + n.Off
+		if pos > e.Pos {
+			break
+		}
+		if _, ok := n.Val.(lisp.Group); ok && pos < e.Pos && e.End < pos+n.Len {
+			return i
+		}
+	}
+	return -1
+}
+
+// affected returns the range [i, j) of top-level nodes touching or adjacent to e.
+func affected(nodes []*Node, e Edit) (i, j int) {
+	for i < len(nodes) && nodes[i].Off+nodes[i].Len < e.Pos {
+		i++
+	}
+	for j = i; j < len(nodes) && nodes[j].Off <= e.End; j++ {
+	}
+	return i, j
+}
+
+func (t *Tree) node(path []int) *Node {
+	n := t.Nodes[path[0]]
+	for _, i := range path[1:] {
+		n = n.Nodes[i]
+	}
+	return n
+}
+
+// splice replaces the node at path with n and returns the new top-level nodes.
+//
+// Ancestors of n are copied and their later siblings are shifted by delta.
+func (t *Tree) splice(path []int, n *Node, delta scan.Pos) []*Node {
+	var ancestors []*Node
+	nodes := t.Nodes
+	for _, i := range path[:len(path)-1] {
+		ancestors = append(ancestors, nodes[i])
+		nodes = nodes[i].Nodes
+	}
+	for k := len(path) - 1; ; k-- {
+		nodes = replace(nodes, path[k], n, delta)
+		if k == 0 {
+			return nodes
+		}
+		parent := *ancestors[k-1]
+		parent.Len += delta
+		parent.Nodes = nodes
+		g := make(lisp.Group, len(nodes))
+		copy(g, parent.Val.(lisp.Group))
+		g[path[k]] = n.Val
+		parent.Val = g
+		n = &parent
+		if k > 1 {
+			nodes = ancestors[k-2].Nodes
+		} else {
+			nodes = t.Nodes
+		}
+	}
+}
+
+// replace returns a copy of nodes with nodes[i] replaced by n and later nodes shifted by delta.
+func replace(nodes []*Node, i int, n *Node, delta scan.Pos) []*Node {
+	res := make([]*Node, len(nodes))
+	copy(res, nodes[:i])
+	res[i] = n
+	for j := i + 1; j < len(nodes); j++ {
+		res[j] = shift(nodes[j], delta)
+	}
+	return res
+}
+
+func shift(n *Node, delta scan.Pos) *Node {
+	if delta == 0 {
+		return n
+	}
+	m := *n
+	m.Off += delta
+	return &m
+}
+
+// Walk calls fn for each node in t in order with the absolute position of the node.
+//
+// Walk does not descend into a Group when fn returns false.
+func (t *Tree) Walk(fn func(n *Node, pos scan.Pos) bool) {
+	walk(t.Nodes, 0, fn)
+}
+
+func walk(nodes []*Node, base scan.Pos, fn func(*Node, scan.Pos) bool) {
+	for _, n := range nodes {
+		pos := base + n.Off
+		if fn(n, pos) {
+			walk(n.Nodes, pos, fn)
+		}
+	}
+}
+
+// Values returns the top-level values in t.
+func (t *Tree) Values() []lisp.Val {
+	vs := make([]lisp.Val, len(t.Nodes))
+	for i, n := range t.Nodes {
+		vs[i] = n.Val
+	}
+	return vs
+}
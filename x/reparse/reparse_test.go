@@ -0,0 +1,132 @@
+package reparse
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ajzaff/lisp/scan"
+	"github.com/google/go-cmp/cmp"
+)
+
+type walkNode struct {
+	Pos scan.Pos
+	Len scan.Pos
+}
+
+func walkNodes(t *Tree) (res []walkNode) {
+	t.Walk(func(n *Node, pos scan.Pos) bool {
+		res = append(res, walkNode{pos, n.Len})
+		return true
+	})
+	return res
+}
+
+func checkApply(t *testing.T, name, src string, e Edit) (old, got *Tree) {
+	t.Helper()
+	old, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse(%q): got err = %v", src, err)
+	}
+	got, gotErr := old.Apply(e)
+	want, wantErr := Parse(append(append([]byte(src[:e.Pos]), e.Text...), src[e.End:]...))
+	if (gotErr != nil) != (wantErr != nil) {
+		t.Fatalf("Apply(%q): got err = %v, want err = %v", name, gotErr, wantErr)
+	}
+	if wantErr != nil {
+		return old, nil
+	}
+	if diff := cmp.Diff(string(want.Src), string(got.Src)); diff != "" {
+		t.Errorf("Apply(%q): got src diff (-want, +got):\n%v", name, diff)
+	}
+	if diff := cmp.Diff(want.Values(), got.Values()); diff != "" {
+		t.Errorf("Apply(%q): got Val diff (-want, +got):\n%v", name, diff)
+	}
+	if diff := cmp.Diff(walkNodes(want), walkNodes(got)); diff != "" {
+		t.Errorf("Apply(%q): got pos diff (-want, +got):\n%v", name, diff)
+	}
+	return old, got
+}
+
+func TestApply(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		edit Edit
+	}{{
+		name: "insert into empty",
+		edit: Edit{Text: []byte("(a)")},
+	}, {
+		name: "append to lit",
+		src:  "ab cd",
+		edit: Edit{Pos: 2, End: 2, Text: []byte("x")},
+	}, {
+		name: "join lits",
+		src:  "ab cd",
+		edit: Edit{Pos: 2, End: 3},
+	}, {
+		name: "edit nested lit",
+		src:  "(a (b c) d) (e)",
+		edit: Edit{Pos: 6, End: 7, Text: []byte("xyz")},
+	}, {
+		name: "insert group in nested group",
+		src:  "(a (b c) d) (e)",
+		edit: Edit{Pos: 7, End: 7, Text: []byte(" (f g)")},
+	}, {
+		name: "unbalance nested group",
+		src:  "(a (b c) d) (e)",
+		edit: Edit{Pos: 7, End: 8},
+	}, {
+		name: "delete nested group",
+		src:  "(a (b c) d) (e)",
+		edit: Edit{Pos: 3, End: 8},
+	}, {
+		name: "close paren at top-level",
+		src:  "(a b) c",
+		edit: Edit{Pos: 4, End: 5},
+	}, {
+		name: "invalid edit",
+		src:  "(a)",
+		edit: Edit{Pos: 1, End: 1, Text: []byte("!")},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			checkApply(t, tc.name, tc.src, tc.edit)
+		})
+	}
+}
+
+func TestApplyReusesSubtrees(t *testing.T) {
+	old, got := checkApply(t, "reuse", "(a (b c) (d e)) (f)", Edit{Pos: 6, End: 7, Text: []byte("xy")})
+	if got.Nodes[0] == old.Nodes[0] {
+		t.Errorf("Apply(): edited top-level node was reused")
+	}
+	if got.Nodes[0].Nodes[0] != old.Nodes[0].Nodes[0] {
+		t.Errorf("Apply(): node before the edit was not reused")
+	}
+	if got.Nodes[0].Nodes[2].Nodes[0] != old.Nodes[0].Nodes[2].Nodes[0] {
+		t.Errorf("Apply(): children of node after the edit were not reused")
+	}
+	if got.Nodes[1].Nodes[0] != old.Nodes[1].Nodes[0] {
+		t.Errorf("Apply(): children of top-level node after the edit were not reused")
+	}
+}
+
+func TestApplyRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	alphabet := []byte("ab1 ()")
+	for i := 0; i < 2000; i++ {
+		src := make([]byte, r.Intn(20))
+		for j := range src {
+			src[j] = alphabet[r.Intn(len(alphabet))]
+		}
+		if _, err := Parse(src); err != nil {
+			continue
+		}
+		pos := r.Intn(len(src) + 1)
+		end := pos + r.Intn(len(src)-pos+1)
+		text := make([]byte, r.Intn(4))
+		for j := range text {
+			text[j] = alphabet[r.Intn(len(alphabet))]
+		}
+		checkApply(t, string(src), string(src), Edit{Pos: scan.Pos(pos), End: scan.Pos(end), Text: text})
+	}
+}
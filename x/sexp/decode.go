@@ -0,0 +1,745 @@
+package sexp
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// errClose is returned by value when it reads a closing delimiter.
+var errClose = errors.New("sexp: unexpected closing delimiter")
+
+// Decoder reads foreign s-expressions as Lisp values.
+type Decoder struct {
+	dialect Dialect
+	r       *bufio.Reader
+	off     int64
+	close   rune // Closing delimiter read by value.
+	size    int  // Size of the last rune read.
+	err     error
+}
+
+// NewDecoder returns a Decoder reading the dialect d from r.
+func NewDecoder(r io.Reader, d Dialect) *Decoder {
+	return &Decoder{dialect: d, r: bufio.NewReader(r)}
+}
+
+// Decode decodes the next value.
+//
+// Decode returns io.EOF when no more values are available.
+func (d *Decoder) Decode() (lisp.Val, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err := d.skipSpace(); err != nil {
+		if err != io.EOF {
+			d.err = err
+		}
+		return nil, err
+	}
+	v, err := d.value(0)
+	if err != nil {
+		switch err {
+		case io.EOF:
+			err = io.ErrUnexpectedEOF
+		case errClose:
+			err = fmt.Errorf("sexp: unexpected %q at offset %d", d.close, d.off)
+		}
+		d.err = err
+		return nil, err
+	}
+	return v, nil
+}
+
+// Values returns an iteration over the decoded values.
+//
+// Errors are reported by Err.
+func (d *Decoder) Values() iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		for {
+			v, err := d.Decode()
+			if err != nil || !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the first error encountered by the Decoder other than io.EOF.
+func (d *Decoder) Err() error { return d.err }
+
+func (d *Decoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("sexp: %s at offset %d", fmt.Sprintf(format, args...), d.off)
+}
+
+func (d *Decoder) readRune() (rune, error) {
+	r, n, err := d.r.ReadRune()
+	d.off += int64(n)
+	d.size = n
+	return r, err
+}
+
+// unreadRune unreads the last rune read by readRune.
+func (d *Decoder) unreadRune() {
+	d.r.UnreadRune()
+	d.off -= int64(d.size)
+}
+
+func (d *Decoder) peekRune() (rune, error) {
+	r, err := d.readRune()
+	if err == nil {
+		d.unreadRune()
+	}
+	return r, err
+}
+
+func (d *Decoder) isSpace(r rune) bool {
+	return unicode.IsSpace(r) || d.dialect == EDN && r == ','
+}
+
+// skipSpace skips white space and comments.
+func (d *Decoder) skipSpace() error {
+	for {
+		r, err := d.peekRune()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.isSpace(r):
+			d.readRune()
+		case r == ';' && d.dialect != Rivest:
+			s, err := d.r.ReadString('\n')
+			d.off += int64(len(s))
+			if err != nil {
+				return err
+			}
+		case r == '#' && d.dialect != Rivest:
+			b, _ := d.r.Peek(2)
+			if len(b) < 2 {
+				return nil
+			}
+			switch {
+			case b[1] == '|' && d.dialect == Scheme:
+				d.discard(2)
+				if err := d.blockComment(); err != nil {
+					return err
+				}
+			case b[1] == ';' && d.dialect == Scheme, b[1] == '_' && d.dialect == EDN:
+				d.discard(2)
+				if err := d.skipSpace(); err != nil {
+					return err
+				}
+				if _, err := d.value(0); err != nil {
+					return err
+				}
+			default:
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (d *Decoder) discard(n int) {
+	n, _ = d.r.Discard(n)
+	d.off += int64(n)
+}
+
+// blockComment skips a nested #| ... |# comment.
+func (d *Decoder) blockComment() error {
+	depth := 1
+	var prev rune
+	for depth > 0 {
+		r, err := d.readRune()
+		if err != nil {
+			return err
+		}
+		switch {
+		case prev == '|' && r == '#':
+			depth--
+			r = 0
+		case prev == '#' && r == '|':
+			depth++
+			r = 0
+		}
+		prev = r
+	}
+	return nil
+}
+
+// isDelim reports whether r ends a token.
+func (d *Decoder) isDelim(r rune) bool {
+	if d.isSpace(r) {
+		return true
+	}
+	switch r {
+	case '(', ')', '[', ']', '{', '}', '"', ';':
+		return true
+	case '|', '#':
+		return d.dialect == Rivest
+	}
+	return false
+}
+
+// token reads a token beginning with r.
+func (d *Decoder) token(r rune) (string, error) {
+	var sb strings.Builder
+	sb.WriteRune(r)
+	for {
+		r, err := d.readRune()
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if d.isDelim(r) {
+			d.unreadRune()
+			return sb.String(), nil
+		}
+		sb.WriteRune(r)
+	}
+}
+
+// items reads values until the closing delimiter end.
+func (d *Decoder) items(depth int, end rune) (lisp.Group, error) {
+	g := lisp.Group{}
+	for {
+		if err := d.skipSpace(); err != nil {
+			return nil, err
+		}
+		v, err := d.value(depth + 1)
+		if err == errClose {
+			if d.close != end {
+				return nil, d.errorf("unexpected %q", d.close)
+			}
+			return g, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		g = append(g, v)
+	}
+}
+
+// value reads the value at the current position.
+func (d *Decoder) value(depth int) (lisp.Val, error) {
+	if depth > maxDepth {
+		return nil, d.errorf("nesting too deep")
+	}
+	r, err := d.readRune()
+	if err != nil {
+		return nil, err
+	}
+	switch r {
+	case ')', ']', '}':
+		d.close = r
+		return nil, errClose
+	}
+	switch d.dialect {
+	case Rivest:
+		return d.rivest(depth, r)
+	case Scheme:
+		return d.scheme(depth, r)
+	default:
+		return d.edn(depth, r)
+	}
+}
+
+func (d *Decoder) rivest(depth int, r rune) (lisp.Val, error) {
+	switch {
+	case r == '(':
+		g, err := d.items(depth, ')')
+		if err != nil {
+			return nil, err
+		}
+		return list(g), nil
+	case r == '[':
+		if err := d.skipSpace(); err != nil {
+			return nil, err
+		}
+		hint, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.skipSpace(); err != nil {
+			return nil, err
+		}
+		if r, err := d.readRune(); err != nil || r != ']' {
+			return nil, d.errorf("expected ']' after display hint")
+		}
+		if err := d.skipSpace(); err != nil {
+			return nil, err
+		}
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return lisp.Group{hintLit, hint, v}, nil
+	case r == '{':
+		s, err := d.r.ReadString('}')
+		if err != nil {
+			return nil, err
+		}
+		d.off += int64(len(s))
+		b, err := base64.StdEncoding.DecodeString(stripSpace(s[:len(s)-1]))
+		if err != nil {
+			return nil, d.errorf("bad transport encoding: %v", err)
+		}
+		v, err := NewDecoder(strings.NewReader(string(b)), Rivest).Decode()
+		if err != nil {
+			return nil, d.errorf("bad transport encoding: %v", err)
+		}
+		return v, nil
+	case '0' <= r && r <= '9':
+		s, err := d.digits(r)
+		if err != nil {
+			return nil, err
+		}
+		next, err := d.peekRune()
+		if err != nil || !strings.ContainsRune(":\"#|", next) {
+			// A token beginning with digits.
+			if err == nil && !d.isDelim(next) {
+				d.readRune()
+				rest, err := d.token(next)
+				if err != nil {
+					return nil, err
+				}
+				s += rest
+			}
+			return atom([]byte(s)), nil
+		}
+		n, err := strconv.ParseUint(s, 10, 31)
+		if err != nil {
+			return nil, d.errorf("bad length %q", s)
+		}
+		d.readRune()
+		if next != ':' {
+			return d.rivestString(next)
+		}
+		// Read incrementally rather than trust n for the allocation.
+		b, err := io.ReadAll(io.LimitReader(d.r, int64(n)))
+		d.off += int64(len(b))
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(b)) < n {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return atom(b), nil
+	case r == '"' || r == '#' || r == '|':
+		return d.rivestString(r)
+	default:
+		s, err := d.token(r)
+		if err != nil {
+			return nil, err
+		}
+		return atom([]byte(s)), nil
+	}
+}
+
+// digits reads decimal digits beginning with r.
+func (d *Decoder) digits(r rune) (string, error) {
+	var sb strings.Builder
+	sb.WriteRune(r)
+	for {
+		r, err := d.readRune()
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if r < '0' || '9' < r {
+			d.unreadRune()
+			return sb.String(), nil
+		}
+		sb.WriteRune(r)
+	}
+}
+
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// rivestString reads a quoted, hexadecimal or base64 string opened by r.
+func (d *Decoder) rivestString(r rune) (lisp.Val, error) {
+	if r == '"' {
+		s, err := d.str()
+		if err != nil {
+			return nil, err
+		}
+		return atom([]byte(s)), nil
+	}
+	s, err := d.r.ReadString(byte(r))
+	if err != nil {
+		return nil, err
+	}
+	d.off += int64(len(s))
+	s = stripSpace(s[:len(s)-1])
+	var b []byte
+	if r == '#' {
+		b, err = hex.DecodeString(s)
+	} else {
+		b, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return nil, d.errorf("bad atom encoding: %v", err)
+	}
+	return atom(b), nil
+}
+
+// atom returns the value for the Rivest atom b.
+func atom(b []byte) lisp.Val {
+	if !utf8.Valid(b) {
+		return lisp.Group{bytesLit, lisp.Lit(hex.EncodeToString(b))}
+	}
+	return xlisp.Quote(string(b))
+}
+
+// str reads the rest of a double quoted string.
+func (d *Decoder) str() (string, error) {
+	var sb strings.Builder
+	for {
+		r, err := d.readRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			r, err := d.escape()
+			if err != nil {
+				return "", err
+			}
+			if r >= 0 {
+				sb.WriteRune(r)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+}
+
+// escape reads an escape sequence after a backslash.
+// It returns -1 for a line continuation.
+func (d *Decoder) escape() (rune, error) {
+	r, err := d.readRune()
+	if err != nil {
+		return 0, err
+	}
+	switch r {
+	case 'a':
+		return '\a', nil
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'v':
+		return '\v', nil
+	case '\n':
+		return -1, nil
+	case 'x', 'u':
+		// Scheme uses \xHH; and EDN uses \uHHHH.
+		var sb strings.Builder
+		for {
+			c, err := d.readRune()
+			if err != nil {
+				return 0, err
+			}
+			if c == ';' && r == 'x' {
+				break
+			}
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				d.unreadRune()
+				break
+			}
+			sb.WriteRune(c)
+			if r == 'u' && sb.Len() == 4 {
+				break
+			}
+		}
+		n, err := strconv.ParseUint(sb.String(), 16, 32)
+		if err != nil || n > unicode.MaxRune {
+			return 0, d.errorf("bad escape \\%c%s", r, sb.String())
+		}
+		return rune(n), nil
+	default:
+		return r, nil
+	}
+}
+
+// symbol returns the value for the symbol or number token s.
+func symbol(s string) lisp.Val {
+	if numPattern.MatchString(s) {
+		return number(s)
+	}
+	return xlisp.Quote(s)
+}
+
+// charNames are the named characters shared by Scheme and EDN.
+var charNames = map[string]rune{
+	"space":     ' ',
+	"newline":   '\n',
+	"tab":       '\t',
+	"return":    '\r',
+	"nul":       0,
+	"null":      0,
+	"alarm":     '\a',
+	"backspace": '\b',
+	"delete":    0x7f,
+	"escape":    0x1b,
+	"formfeed":  '\f',
+}
+
+// char reads a character after #\ or \.
+func (d *Decoder) char() (lisp.Val, error) {
+	r, err := d.readRune()
+	if err != nil {
+		return nil, err
+	}
+	s, err := d.token(r)
+	if err != nil {
+		return nil, err
+	}
+	if utf8.RuneCountInString(s) > 1 {
+		if c, ok := charNames[s]; ok {
+			r = c
+		} else if (s[0] == 'x' || s[0] == 'u') && len(s) > 1 {
+			n, err := strconv.ParseUint(s[1:], 16, 32)
+			if err != nil || n > unicode.MaxRune {
+				return nil, d.errorf("bad character %q", s)
+			}
+			r = rune(n)
+		} else {
+			return nil, d.errorf("bad character %q", s)
+		}
+	}
+	return lisp.Group{charLit, xlisp.Nat(uint64(r))}, nil
+}
+
+func (d *Decoder) scheme(depth int, r rune) (lisp.Val, error) {
+	switch r {
+	case '(', '[':
+		end := ')'
+		if r == '[' {
+			end = ']'
+		}
+		g, err := d.items(depth, end)
+		if err != nil {
+			return nil, err
+		}
+		return dotted(g)
+	case '"':
+		s, err := d.str()
+		if err != nil {
+			return nil, err
+		}
+		return lisp.Group{strLit, xlisp.Quote(s)}, nil
+	case '\'', '`', ',':
+		head := map[rune]string{'\'': "quote", '`': "quasiquote", ',': "unquote"}[r]
+		if r == ',' {
+			if next, err := d.peekRune(); err == nil && next == '@' {
+				d.readRune()
+				head = "unquote-splicing"
+			}
+		}
+		if err := d.skipSpace(); err != nil {
+			return nil, err
+		}
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return lisp.Group{xlisp.Quote(head), v}, nil
+	case '|':
+		var sb strings.Builder
+		for {
+			r, err := d.readRune()
+			if err != nil {
+				return nil, err
+			}
+			if r == '|' {
+				return xlisp.Quote(sb.String()), nil
+			}
+			if r == '\\' {
+				if r, err = d.escape(); err != nil {
+					return nil, err
+				}
+			}
+			sb.WriteRune(r)
+		}
+	case '#':
+		next, err := d.readRune()
+		if err != nil {
+			return nil, err
+		}
+		switch next {
+		case '(':
+			g, err := d.items(depth, ')')
+			if err != nil {
+				return nil, err
+			}
+			return append(lisp.Group{vectorLit}, g...), nil
+		case '\\':
+			return d.char()
+		}
+		s, err := d.token(next)
+		if err != nil {
+			return nil, err
+		}
+		switch s {
+		case "t", "true":
+			return lisp.Group{trueLit}, nil
+		case "f", "false":
+			return lisp.Group{falseLit}, nil
+		}
+		if strings.ContainsRune("xXbBoOdDeEiI", next) {
+			return lisp.Group{numLit, xlisp.Quote("#" + s)}, nil
+		}
+		return nil, d.errorf("unexpected #%s", s)
+	default:
+		s, err := d.token(r)
+		if err != nil {
+			return nil, err
+		}
+		return symbol(s), nil
+	}
+}
+
+// dotted returns the value for the Scheme list g which may be improper.
+func dotted(g lisp.Group) (lisp.Val, error) {
+	dot := xlisp.Quote(".")
+	for i, x := range g {
+		if !xlisp.Equal(x, dot) {
+			continue
+		}
+		if i == 0 || i != len(g)-2 {
+			return nil, fmt.Errorf("sexp: bad dotted list")
+		}
+		return append(append(lisp.Group{dottedLit}, g[:i]...), g[i+1]), nil
+	}
+	return list(g), nil
+}
+
+func (d *Decoder) edn(depth int, r rune) (lisp.Val, error) {
+	switch r {
+	case '(':
+		g, err := d.items(depth, ')')
+		if err != nil {
+			return nil, err
+		}
+		return list(g), nil
+	case '[':
+		g, err := d.items(depth, ']')
+		if err != nil {
+			return nil, err
+		}
+		return append(lisp.Group{vectorLit}, g...), nil
+	case '{':
+		g, err := d.items(depth, '}')
+		if err != nil {
+			return nil, err
+		}
+		return pairs(d, g)
+	case '"':
+		s, err := d.str()
+		if err != nil {
+			return nil, err
+		}
+		return lisp.Group{strLit, xlisp.Quote(s)}, nil
+	case '\\':
+		return d.char()
+	case ':':
+		next, err := d.readRune()
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.token(next)
+		if err != nil {
+			return nil, err
+		}
+		return lisp.Group{keywordLit, xlisp.Quote(s)}, nil
+	case '#':
+		next, err := d.readRune()
+		if err != nil {
+			return nil, err
+		}
+		switch next {
+		case '{':
+			g, err := d.items(depth, '}')
+			if err != nil {
+				return nil, err
+			}
+			return append(lisp.Group{setLit}, g...), nil
+		case '#':
+			s, err := d.token(next)
+			if err != nil {
+				return nil, err
+			}
+			return lisp.Group{numLit, xlisp.Quote("#" + s)}, nil
+		}
+		tag, err := d.token(next)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.skipSpace(); err != nil {
+			return nil, err
+		}
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return lisp.Group{tagLit, xlisp.Quote(tag), v}, nil
+	default:
+		s, err := d.token(r)
+		if err != nil {
+			return nil, err
+		}
+		switch s {
+		case "nil":
+			return lisp.Group{nilLit}, nil
+		case "true":
+			return lisp.Group{trueLit}, nil
+		case "false":
+			return lisp.Group{falseLit}, nil
+		}
+		return symbol(s), nil
+	}
+}
+
+// pairs returns the map for the EDN map elements g.
+func pairs(d *Decoder, g lisp.Group) (lisp.Val, error) {
+	if len(g)%2 != 0 {
+		return nil, d.errorf("map has odd number of elements")
+	}
+	m := lisp.Group{mapLit}
+	for i := 0; i < len(g); i += 2 {
+		m = append(m, lisp.Group{g[i], g[i+1]})
+	}
+	return m, nil
+}
@@ -0,0 +1,371 @@
+package sexp
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Encoder writes Lisp values as foreign s-expressions.
+type Encoder struct {
+	dialect Dialect
+	w       *bufio.Writer
+}
+
+// NewEncoder returns an Encoder writing the dialect d to w.
+func NewEncoder(w io.Writer, d Dialect) *Encoder {
+	return &Encoder{dialect: d, w: bufio.NewWriter(w)}
+}
+
+// Encode writes the value v followed by a new line and flushes the Encoder.
+//
+// Encode returns an error if v cannot be represented in the dialect.
+func (e *Encoder) Encode(v lisp.Val) error {
+	if err := e.encode(v); err != nil {
+		return err
+	}
+	if e.dialect != Rivest {
+		e.w.WriteByte('\n')
+	}
+	return e.w.Flush()
+}
+
+func unquote(v lisp.Val) (string, error) {
+	s, err := xlisp.Unquote(v)
+	if err != nil {
+		return "", fmt.Errorf("sexp: %w", err)
+	}
+	return s, nil
+}
+
+func (e *Encoder) unsupported(v lisp.Val) error {
+	return fmt.Errorf("sexp: %v cannot be represented in %v", v, e.dialect)
+}
+
+// arg returns the text of the only argument of the node v.
+func arg(v lisp.Group) (string, error) {
+	if len(v) != 2 {
+		return "", fmt.Errorf("sexp: malformed (%v): %v", v[0], v)
+	}
+	return unquote(v[1])
+}
+
+func (e *Encoder) encode(v lisp.Val) error {
+	switch v := v.(type) {
+	case lisp.Lit:
+		if e.dialect == Rivest {
+			e.atom([]byte(v))
+			return nil
+		}
+		if natPattern.MatchString(string(v)) {
+			e.w.WriteString(string(v))
+			return nil
+		}
+		return e.symbol(string(v))
+	case lisp.Group:
+		return e.group(v)
+	default:
+		return fmt.Errorf("sexp: unexpected Val %v", v)
+	}
+}
+
+func (e *Encoder) group(v lisp.Group) error {
+	if len(v) == 0 {
+		e.w.WriteString("()")
+		return nil
+	}
+	head := v[0]
+	if !isReserved(head) {
+		return e.list("(", v, ")")
+	}
+	switch head {
+	case quoteLit:
+		s, err := unquote(v)
+		if err != nil {
+			return err
+		}
+		if e.dialect == Rivest {
+			e.atom([]byte(s))
+			return nil
+		}
+		return e.symbol(s)
+	case listLit:
+		return e.list("(", v[1:], ")")
+	case strLit, numLit:
+		s, err := arg(v)
+		if err != nil {
+			return err
+		}
+		switch {
+		case e.dialect == Rivest:
+			e.atom([]byte(s))
+		case head == strLit:
+			e.str(s)
+		case numPattern.MatchString(s) || strings.HasPrefix(s, "#") && !strings.ContainsFunc(s, e.isDelim):
+			e.w.WriteString(s)
+		default:
+			return fmt.Errorf("sexp: invalid number %q", s)
+		}
+		return nil
+	case bytesLit:
+		if e.dialect != Rivest {
+			return e.unsupported(v)
+		}
+		var b []byte
+		if len(v) == 2 {
+			h, ok := v[1].(lisp.Lit)
+			if !ok {
+				return fmt.Errorf("sexp: malformed (bytes H): %v", v)
+			}
+			var err error
+			if b, err = hex.DecodeString(string(h)); err != nil {
+				return fmt.Errorf("sexp: malformed (bytes H): %v", v)
+			}
+		} else if len(v) != 1 {
+			return fmt.Errorf("sexp: malformed (bytes H): %v", v)
+		}
+		e.atom(b)
+		return nil
+	case hintLit:
+		if e.dialect != Rivest || len(v) != 3 {
+			return e.unsupported(v)
+		}
+		e.w.WriteByte('[')
+		if err := e.encode(v[1]); err != nil {
+			return err
+		}
+		e.w.WriteByte(']')
+		return e.encode(v[2])
+	}
+	if e.dialect == Rivest {
+		return e.unsupported(v)
+	}
+	switch head {
+	case trueLit, falseLit:
+		if len(v) != 1 {
+			return fmt.Errorf("sexp: malformed (%v): %v", head, v)
+		}
+		if e.dialect == Scheme {
+			e.w.WriteString(map[lisp.Val]string{trueLit: "#t", falseLit: "#f"}[head])
+		} else {
+			e.w.WriteString(string(head.(lisp.Lit)))
+		}
+		return nil
+	case charLit:
+		s, err := arg(v)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil || n > unicode.MaxRune {
+			return fmt.Errorf("sexp: malformed (char N): %v", v)
+		}
+		e.char(rune(n))
+		return nil
+	case vectorLit:
+		if e.dialect == Scheme {
+			return e.list("#(", v[1:], ")")
+		}
+		return e.list("[", v[1:], "]")
+	case dottedLit:
+		if e.dialect != Scheme || len(v) < 3 {
+			return e.unsupported(v)
+		}
+		n := len(v) - 1
+		if err := e.list("(", v[1:n], ""); err != nil {
+			return err
+		}
+		e.w.WriteString(" . ")
+		if err := e.encode(v[n]); err != nil {
+			return err
+		}
+		e.w.WriteByte(')')
+		return nil
+	}
+	if e.dialect != EDN {
+		return e.unsupported(v)
+	}
+	switch head {
+	case nilLit:
+		if len(v) != 1 {
+			return fmt.Errorf("sexp: malformed (nil): %v", v)
+		}
+		e.w.WriteString("nil")
+		return nil
+	case setLit:
+		return e.list("#{", v[1:], "}")
+	case mapLit:
+		e.w.WriteByte('{')
+		for i, x := range v[1:] {
+			kv, ok := x.(lisp.Group)
+			if !ok || len(kv) != 2 {
+				return fmt.Errorf("sexp: malformed map entry: %v", x)
+			}
+			if i > 0 {
+				e.w.WriteString(", ")
+			}
+			if err := e.list("", kv, ""); err != nil {
+				return err
+			}
+		}
+		e.w.WriteByte('}')
+		return nil
+	case keywordLit:
+		s, err := arg(v)
+		if err != nil {
+			return err
+		}
+		if !e.validSymbol(s) {
+			return fmt.Errorf("sexp: invalid keyword %q", s)
+		}
+		e.w.WriteByte(':')
+		e.w.WriteString(s)
+		return nil
+	case tagLit:
+		if len(v) != 3 {
+			return fmt.Errorf("sexp: malformed (tag X v): %v", v)
+		}
+		s, err := unquote(v[1])
+		if err != nil {
+			return err
+		}
+		if !e.validSymbol(s) {
+			return fmt.Errorf("sexp: invalid tag %q", s)
+		}
+		e.w.WriteByte('#')
+		e.w.WriteString(s)
+		e.w.WriteByte(' ')
+		return e.encode(v[2])
+	}
+	return e.unsupported(v)
+}
+
+// list writes the elements of g between open and close.
+func (e *Encoder) list(open string, g lisp.Group, close string) error {
+	e.w.WriteString(open)
+	for i, x := range g {
+		if i > 0 && e.dialect != Rivest {
+			e.w.WriteByte(' ')
+		}
+		if err := e.encode(x); err != nil {
+			return err
+		}
+	}
+	e.w.WriteString(close)
+	return nil
+}
+
+// atom writes b as a Rivest canonical atom.
+func (e *Encoder) atom(b []byte) {
+	e.w.WriteString(strconv.Itoa(len(b)))
+	e.w.WriteByte(':')
+	e.w.Write(b)
+}
+
+func (e *Encoder) isDelim(r rune) bool {
+	if unicode.IsSpace(r) || e.dialect == EDN && r == ',' {
+		return true
+	}
+	return strings.ContainsRune("()[]{}\";'`|\\", r)
+}
+
+// validSymbol reports whether s can be written as a bare symbol.
+func (e *Encoder) validSymbol(s string) bool {
+	if s == "" || s == "." || strings.HasPrefix(s, "#") || numPattern.MatchString(s) {
+		return false
+	}
+	if e.dialect == EDN {
+		switch s {
+		case "nil", "true", "false":
+			return false
+		}
+		if s[0] == ':' || '0' <= s[0] && s[0] <= '9' {
+			return false
+		}
+	}
+	for _, r := range s {
+		if e.isDelim(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// symbol writes the symbol s using |...| in Scheme if needed.
+func (e *Encoder) symbol(s string) error {
+	if e.validSymbol(s) && !natPattern.MatchString(s) {
+		e.w.WriteString(s)
+		return nil
+	}
+	if e.dialect != Scheme {
+		return fmt.Errorf("sexp: invalid symbol %q for %v", s, e.dialect)
+	}
+	e.w.WriteByte('|')
+	for _, r := range s {
+		switch r {
+		case '|', '\\':
+			e.w.WriteByte('\\')
+			e.w.WriteRune(r)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(e.w, "\\x%x;", r)
+				continue
+			}
+			e.w.WriteRune(r)
+		}
+	}
+	e.w.WriteByte('|')
+	return nil
+}
+
+// str writes the double quoted string s.
+func (e *Encoder) str(s string) {
+	e.w.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			e.w.WriteByte('\\')
+			e.w.WriteRune(r)
+		case '\n':
+			e.w.WriteString(`\n`)
+		case '\t':
+			e.w.WriteString(`\t`)
+		case '\r':
+			e.w.WriteString(`\r`)
+		default:
+			switch {
+			case !unicode.IsControl(r):
+				e.w.WriteRune(r)
+			case e.dialect == Scheme:
+				fmt.Fprintf(e.w, "\\x%x;", r)
+			default:
+				fmt.Fprintf(e.w, "\\u%04x", r)
+			}
+		}
+	}
+	e.w.WriteByte('"')
+}
+
+// char writes the character r.
+func (e *Encoder) char(r rune) {
+	if e.dialect == Scheme {
+		e.w.WriteString(`#\`)
+	} else {
+		e.w.WriteByte('\\')
+	}
+	switch {
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r):
+		e.w.WriteRune(r)
+	case e.dialect == Scheme:
+		fmt.Fprintf(e.w, "x%x", r)
+	default:
+		fmt.Fprintf(e.w, "u%04x", r)
+	}
+}
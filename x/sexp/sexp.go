@@ -0,0 +1,125 @@
+// Package sexp implements readers and writers for foreign s-expression dialects.
+//
+// The supported dialects are Rivest canonical and advanced s-expressions,
+// Scheme and Common Lisp style s-expressions and Clojure EDN.
+//
+// Foreign values are mapped to Lisp values as follows:
+//
+//	symbol            Quoted symbol text (see xlisp.Quote)
+//	123               123 for unsigned integers
+//	-1.5              (num X) where X is the quoted number text
+//	"text"            (str X) where X is the quoted text
+//	(a b ...)         (a b ...)
+//	(a b . c)         (dotted a b c)
+//	#t #f             (true) (false)
+//	nil               (nil)
+//	#\a               (char N) where N is the code point
+//	#(a ...) [a ...]  (vector a ...)
+//	{k v ...}         (map (k v) ...)
+//	#{a ...}          (set a ...)
+//	:name             (keyword X) where X is the quoted name
+//	#name v           (tag X v) where X is the quoted tag name
+//	Rivest atom       Quoted atom text or (bytes H) for non UTF-8 atoms
+//	[hint]atom        (hint X Y) for the display hint X and atom Y
+//
+// Lists whose first element is one of the reserved Lits q, str, num,
+// dotted, true, false, nil, char, vector, map, set, keyword, tag, bytes,
+// hint or list are written as (list a ...).
+//
+// Quote shorthand such as 'x is read as the list (quote x).
+// Comments and discarded forms are skipped.
+//
+// Rivest atoms are octet strings, so symbols, numbers and (str X)
+// are all written as atoms in the Rivest dialect.
+// The Encoder writes Rivest canonical s-expressions.
+package sexp
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/ajzaff/lisp"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+// Dialect is a foreign s-expression dialect.
+type Dialect int
+
+const (
+	// Rivest is the s-expression format of Rivest's draft.
+	// The Decoder accepts the canonical and advanced transport forms.
+	Rivest Dialect = iota
+
+	// Scheme is the s-expression syntax of Scheme and Common Lisp.
+	Scheme
+
+	// EDN is the extensible data notation of Clojure.
+	EDN
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case Rivest:
+		return "Rivest"
+	case Scheme:
+		return "Scheme"
+	case EDN:
+		return "EDN"
+	default:
+		return fmt.Sprintf("Dialect(%d)", int(d))
+	}
+}
+
+// Reserved Lits used by the mapping.
+const (
+	quoteLit   lisp.Lit = "q"
+	strLit     lisp.Lit = "str"
+	numLit     lisp.Lit = "num"
+	dottedLit  lisp.Lit = "dotted"
+	trueLit    lisp.Lit = "true"
+	falseLit   lisp.Lit = "false"
+	nilLit     lisp.Lit = "nil"
+	charLit    lisp.Lit = "char"
+	vectorLit  lisp.Lit = "vector"
+	mapLit     lisp.Lit = "map"
+	setLit     lisp.Lit = "set"
+	keywordLit lisp.Lit = "keyword"
+	tagLit     lisp.Lit = "tag"
+	bytesLit   lisp.Lit = "bytes"
+	hintLit    lisp.Lit = "hint"
+	listLit    lisp.Lit = "list"
+)
+
+func isReserved(v lisp.Val) bool {
+	switch v {
+	case quoteLit, strLit, numLit, dottedLit, trueLit, falseLit, nilLit, charLit,
+		vectorLit, mapLit, setLit, keywordLit, tagLit, bytesLit, hintLit, listLit:
+		return true
+	default:
+		return false
+	}
+}
+
+// maxDepth bounds the nesting of values accepted by the Decoder.
+const maxDepth = 10000
+
+var (
+	natPattern = regexp.MustCompile(`^[0-9]+$`)
+	numPattern = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?[NM]?$|^[+-]?[0-9]+/[0-9]+$|^[+-](inf|nan)\.0$`)
+)
+
+// number returns the value for the number token s.
+func number(s string) lisp.Val {
+	if natPattern.MatchString(s) {
+		return lisp.Lit(s)
+	}
+	return lisp.Group{numLit, xlisp.Quote(s)}
+}
+
+// list returns the Group for the list elements g.
+func list(g lisp.Group) lisp.Group {
+	if len(g) > 0 && isReserved(g[0]) {
+		return append(lisp.Group{listLit}, g...)
+	}
+	return g
+}
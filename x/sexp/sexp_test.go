@@ -0,0 +1,273 @@
+package sexp
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParseMultiple(t *testing.T, src string) []lisp.Val {
+	t.Helper()
+	var vs []lisp.Val
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	for n := range sc.Nodes() {
+		vs = append(vs, n.Val)
+	}
+	if err := sc.Err(); err != nil {
+		panic(fmt.Sprintf("mustParse: failed to parse: %q: %v", src, err))
+	}
+	return vs
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		dialect Dialect
+		input   string
+		want    string
+	}{{
+		name:    "Rivest canonical",
+		dialect: Rivest,
+		input:   "(3:abc(1:x3:a b))",
+		want:    "(abc (x (q a(u 32)b)))",
+	}, {
+		name:    "Rivest advanced",
+		dialect: Rivest,
+		input:   `(abc "x\ny" #616263# |YWJj| 12 3:a:b)`,
+		want:    "(abc (q x(u 10)y) abc abc 12 (q a(u 58)b))",
+	}, {
+		name:    "Rivest display hint and bytes",
+		dialect: Rivest,
+		input:   "([4:text]2:hi #ff00#)",
+		want:    "((hint text hi) (bytes ff00))",
+	}, {
+		name:    "Rivest transport",
+		dialect: Rivest,
+		input:   "{KDE6YSk=}",
+		want:    "(a)",
+	}, {
+		name:    "Rivest reserved head",
+		dialect: Rivest,
+		input:   "(3:str1:a)",
+		want:    "(list str a)",
+	}, {
+		name:    "Scheme",
+		dialect: Scheme,
+		input:   `(define (f x) (string-append "a\"b" x)) ; comment`,
+		want:    "(define (f x) ((q string(u 45)append) (str (q a(u 34)b)) x))",
+	}, {
+		name:    "Scheme numbers",
+		dialect: Scheme,
+		input:   "(1 -2 3.5 1/2 +inf.0 #xff + ...)",
+		want:    "(1 (num (q (u 45)2)) (num (q 3(u 46)5)) (num (q 1(u 47)2)) (num (q (u 43)inf(u 46)0)) (num (q (u 35)xff)) (q (u 43)) (q (u 46)(u 46)(u 46)))",
+	}, {
+		name:    "Scheme quote shorthand",
+		dialect: Scheme,
+		input:   "'a `(b ,c ,@d)",
+		want:    "(quote a) (quasiquote (b (unquote c) ((q unquote(u 45)splicing) d)))",
+	}, {
+		name:    "Scheme literals",
+		dialect: Scheme,
+		input:   `(#t #false #\a #\space #\x41 #(1 2) |a b| [x] (a b . c))`,
+		want:    "((true) (false) (char 97) (char 32) (char 65) (vector 1 2) (q a(u 32)b) (x) (dotted a b c))",
+	}, {
+		name:    "Scheme comments",
+		dialect: Scheme,
+		input:   "#| a #| nested |# |# (a #;(b) c)",
+		want:    "(a c)",
+	}, {
+		name:    "Scheme reserved head",
+		dialect: Scheme,
+		input:   "(list 1) (q)",
+		want:    "(list list 1) (list q)",
+	}, {
+		name:    "EDN",
+		dialect: EDN,
+		input:   `{:a [1, 2], "b" #{nil}} #inst "2020" \c #_ ignored (true false)`,
+		want:    "(map ((keyword a) (vector 1 2)) ((str b) (set (nil)))) (tag inst (str 2020)) (char 99) ((true) (false))",
+	}, {
+		name:    "EDN numbers",
+		dialect: EDN,
+		input:   "[12N 1.5M -3 ##Inf]",
+		want:    "(vector (num 12N) (num (q 1(u 46)5M)) (num (q (u 45)3)) (num (q (u 35)(u 35)Inf)))",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tc.input), tc.dialect)
+			got := slices.Collect(d.Values())
+			if err := d.Err(); err != nil {
+				t.Fatalf("Decode(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(mustParseMultiple(t, tc.want), got); diff != "" {
+				t.Errorf("Decode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		dialect Dialect
+		input   string
+	}{
+		{"Rivest truncated", Rivest, "(3:ab"},
+		{"Rivest huge length", Rivest, "2147483647:ab"},
+		{"Rivest length overflow", Rivest, "2147483648:ab"},
+		{"Rivest bad hex", Rivest, "#zz#"},
+		{"Scheme unclosed", Scheme, "(a"},
+		{"Scheme stray close", Scheme, ")"},
+		{"Scheme mismatched", Scheme, "(a]"},
+		{"Scheme bad dot", Scheme, "(a . b c)"},
+		{"Scheme unterminated string", Scheme, `"a`},
+		{"EDN odd map", EDN, "{:a}"},
+		{"EDN bad char", EDN, `\bad`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tc.input), tc.dialect)
+			for range d.Values() {
+			}
+			if d.Err() == nil {
+				t.Errorf("Decode(%q): got err = nil, want err", tc.name)
+			}
+		})
+	}
+}
+
+func TestDecodeErrorOffset(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{")", "sexp: unexpected ')' at offset 1"},
+		{"\xff )", "sexp: unexpected ')' at offset 3"},
+		{"\xff\xfe\xfd )", "sexp: unexpected ')' at offset 5"},
+	} {
+		d := NewDecoder(strings.NewReader(tc.input), Scheme)
+		for range d.Values() {
+		}
+		if err := d.Err(); err == nil || err.Error() != tc.want {
+			t.Errorf("Decode(%q): got err = %v, want %q", tc.input, err, tc.want)
+		}
+	}
+}
+
+func TestDecodeHugeLength(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	d := NewDecoder(strings.NewReader("(2147483647:ab)"), Rivest)
+	for range d.Values() {
+	}
+	runtime.ReadMemStats(&after)
+	if d.Err() == nil {
+		t.Errorf("Decode(): got err = nil, want err")
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("Decode(): allocated %d bytes for a truncated atom", n)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		dialect Dialect
+		input   string
+		want    string
+	}{{
+		name:    "Rivest",
+		dialect: Rivest,
+		input:   "(abc (q a(u 32)b) (hint text 12) (bytes ff))",
+		want:    "(3:abc3:a b[4:text]2:12" + "1:\xff)",
+	}, {
+		name:    "Scheme",
+		dialect: Scheme,
+		input:   "(define (f x) ((q string(u 45)append) (str (q a(u 34)(u 10)b)) x (q a(u 32)b) (true)))",
+		want:    "(define (f x) (string-append \"a\\\"\\nb\" x |a b| #t))\n",
+	}, {
+		name:    "Scheme literals",
+		dialect: Scheme,
+		input:   "(vector 1 (num (q (u 45)2))) (dotted a b c) (char 32) (list list)",
+		want:    "#(1 -2)\n(a b . c)\n#\\x20\n(list)\n",
+	}, {
+		name:    "EDN",
+		dialect: EDN,
+		input:   "(map ((keyword a) (vector 1 2)) ((str b) (set (nil)))) (tag inst (str 2020)) (char 99)",
+		want:    "{:a [1 2], \"b\" #{nil}}\n#inst \"2020\"\n\\c\n",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			e := NewEncoder(&sb, tc.dialect)
+			for _, v := range mustParseMultiple(t, tc.input) {
+				if err := e.Encode(v); err != nil {
+					t.Fatalf("Encode(%q): got err = %v", tc.name, err)
+				}
+			}
+			if diff := cmp.Diff(tc.want, sb.String()); diff != "" {
+				t.Errorf("Encode(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		dialect Dialect
+		input   string
+	}{
+		{"Rivest boolean", Rivest, "(true)"},
+		{"Scheme map", Scheme, "(map (a 1))"},
+		{"Scheme bytes", Scheme, "(bytes ff)"},
+		{"EDN dotted", EDN, "(dotted a b)"},
+		{"EDN bad symbol", EDN, "(q a(u 32)b)"},
+		{"bad number", Scheme, "(num (q 1(u 32)2))"},
+		{"malformed char", EDN, "(char x)"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEncoder(&strings.Builder{}, tc.dialect)
+			if err := e.Encode(mustParseMultiple(t, tc.input)[0]); err == nil {
+				t.Errorf("Encode(%q): got err = nil, want err", tc.name)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		dialect Dialect
+		input   string
+	}{
+		{Rivest, `(abc "x y" #ff# [t]a (str 1) ())`},
+		{Scheme, `(define (f . args) '(1 -2.5 "s\t" #\a #\space #(a) |a b| |1| x1 #t #f (q) #xff))`},
+		{EDN, `{:a [1 -2.5 "s\n"], "b" #{nil true}} #inst "2020" (list \a \space sym/ns ##Inf)`},
+	} {
+		t.Run(tc.dialect.String(), func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tc.input), tc.dialect)
+			want := slices.Collect(d.Values())
+			if err := d.Err(); err != nil {
+				t.Fatalf("Decode(%v): got err = %v", tc.dialect, err)
+			}
+			var sb strings.Builder
+			e := NewEncoder(&sb, tc.dialect)
+			for _, v := range want {
+				if err := e.Encode(v); err != nil {
+					t.Fatalf("Encode(%v): got err = %v", tc.dialect, err)
+				}
+			}
+			d = NewDecoder(strings.NewReader(sb.String()), tc.dialect)
+			got := slices.Collect(d.Values())
+			if err := d.Err(); err != nil {
+				t.Fatalf("Decode(%v): got err = %v", tc.dialect, err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("RoundTrip(%v): got diff (-want, +got):\n%v", tc.dialect, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+// Package stream implements pipeline operators over iterations of Lisp values.
+//
+// Operators compose with scan.Scanner.Values as a source and Print or Store as sinks:
+//
+//	var sc scan.Scanner
+//	sc.Reset(r)
+//	seq := stream.Filter(sc.Values(), isRecord)
+//	seq = stream.ParallelMap(seq, 8, transform)
+//	err := stream.Print(print.NewWriter(w), seq)
+package stream
+
+import (
+	"hash/maphash"
+	"iter"
+	"sync"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/x/hash"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+	"github.com/ajzaff/lisp/x/lispdb"
+	"github.com/ajzaff/lisp/x/print"
+)
+
+// Map returns an iteration over fn applied to each value in seq.
+func Map(seq iter.Seq[lisp.Val], fn func(lisp.Val) lisp.Val) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		for v := range seq {
+			if !yield(fn(v)) {
+				return
+			}
+		}
+	}
+}
+
+// Filter returns an iteration over the values in seq for which fn returns true.
+func Filter(seq iter.Seq[lisp.Val], fn func(lisp.Val) bool) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		for v := range seq {
+			if fn(v) && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// FlatMap returns an iteration over the concatenation of fn applied to each value in seq.
+func FlatMap(seq iter.Seq[lisp.Val], fn func(lisp.Val) iter.Seq[lisp.Val]) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		for v := range seq {
+			for x := range fn(v) {
+				if !yield(x) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Take returns an iteration over the first n values in seq.
+func Take(seq iter.Seq[lisp.Val], n int) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		if n <= 0 {
+			return
+		}
+		i := 0
+		for v := range seq {
+			if !yield(v) {
+				return
+			}
+			if i++; i >= n {
+				return
+			}
+		}
+	}
+}
+
+// Skip returns an iteration over the values in seq after the first n.
+func Skip(seq iter.Seq[lisp.Val], n int) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		i := 0
+		for v := range seq {
+			if i < n {
+				i++
+				continue
+			}
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Batch returns an iteration over Groups of n consecutive values in seq.
+//
+// The last Group may contain fewer than n values.
+func Batch(seq iter.Seq[lisp.Val], n int) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		if n <= 0 {
+			n = 1
+		}
+		var g lisp.Group
+		for v := range seq {
+			if g = append(g, v); len(g) == n {
+				if !yield(g) {
+					return
+				}
+				g = nil
+			}
+		}
+		if len(g) > 0 {
+			yield(g)
+		}
+	}
+}
+
+// head returns the key used by GroupByHead.
+func head(v lisp.Val) lisp.Val {
+	if g, ok := v.(lisp.Group); ok {
+		return xlisp.Head(g)
+	}
+	return v
+}
+
+func equalHead(a, b lisp.Val) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return xlisp.Equal(a, b)
+}
+
+// GroupByHead returns an iteration over Groups of consecutive values in seq with equal heads.
+//
+// The head of a Group is its first element while the head of a Lit is the Lit itself.
+// Sort the values by head first to group all values with equal heads.
+func GroupByHead(seq iter.Seq[lisp.Val]) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		var g lisp.Group
+		for v := range seq {
+			if len(g) > 0 && !equalHead(head(g[0]), head(v)) {
+				if !yield(g) {
+					return
+				}
+				g = nil
+			}
+			g = append(g, v)
+		}
+		if len(g) > 0 {
+			yield(g)
+		}
+	}
+}
+
+// Zip returns an iteration over pairs (x y) of values from a and b.
+//
+// Zip stops when either iteration is exhausted.
+func Zip(a, b iter.Seq[lisp.Val]) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		next, stop := iter.Pull(b)
+		defer stop()
+		for x := range a {
+			y, ok := next()
+			if !ok || !yield(lisp.Group{x, y}) {
+				return
+			}
+		}
+	}
+}
+
+// DistinctByHash returns an iteration over the values in seq omitting values equal to one seen before.
+//
+// Values are compared by hash and then checked with xlisp.Equal.
+// DistinctByHash retains each distinct value in memory.
+func DistinctByHash(seq iter.Seq[lisp.Val], seed maphash.Seed) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		var h hash.MapHash
+		h.SetSeed(seed)
+		seen := make(map[uint64][]lisp.Val)
+	next:
+		for v := range seq {
+			h.Reset()
+			h.WriteVal(v)
+			id := h.Sum64()
+			for _, x := range seen[id] {
+				if xlisp.Equal(x, v) {
+					continue next
+				}
+			}
+			seen[id] = append(seen[id], v)
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// ParallelMap returns an iteration over fn applied to each value in seq using n goroutines.
+//
+// Output values are in the same order as seq.
+// seq is consumed on a separate goroutine which is finished before ParallelMap returns.
+func ParallelMap(seq iter.Seq[lisp.Val], n int, fn func(lisp.Val) lisp.Val) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		if n <= 0 {
+			n = 1
+		}
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		done := make(chan struct{})
+		defer close(done)
+		results := make(chan chan lisp.Val, n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(results)
+			for v := range seq {
+				c := make(chan lisp.Val, 1)
+				select {
+				case results <- c:
+				case <-done:
+					return
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					c <- fn(v)
+				}()
+			}
+		}()
+		for c := range results {
+			if !yield(<-c) {
+				return
+			}
+		}
+	}
+}
+
+// Print writes each value in seq to w and closes w.
+func Print(w *print.Writer, seq iter.Seq[lisp.Val]) error {
+	for v := range seq {
+		if err := w.WriteVal(v); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// Store stores the values in seq to s in transactions of up to n values each with weight w.
+func Store(s lispdb.StoreInterface, seq iter.Seq[lisp.Val], n int, w float64) error {
+	for v := range Batch(seq, n) {
+		if err := lispdb.Store(s, v.(lisp.Group), w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
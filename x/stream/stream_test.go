@@ -0,0 +1,136 @@
+package stream
+
+import (
+	"hash/maphash"
+	"iter"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+	"github.com/ajzaff/lisp/x/lispdb"
+	"github.com/ajzaff/lisp/x/print"
+	"github.com/google/go-cmp/cmp"
+)
+
+func values(src string) iter.Seq[lisp.Val] {
+	return func(yield func(lisp.Val) bool) {
+		var sc scan.Scanner
+		sc.Reset(strings.NewReader(src))
+		for v := range sc.Values() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+func wrap(v lisp.Val) lisp.Val { return lisp.Group{lisp.Lit("w"), v} }
+
+func isLit(v lisp.Val) bool {
+	_, ok := v.(lisp.Lit)
+	return ok
+}
+
+func TestOperators(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input iter.Seq[lisp.Val]
+		want  string
+	}{{
+		name:  "Map",
+		input: Map(values("a (b)"), wrap),
+		want:  "(w a)(w(b))",
+	}, {
+		name:  "Filter",
+		input: Filter(values("a (b) c"), isLit),
+		want:  "a c",
+	}, {
+		name: "FlatMap",
+		input: FlatMap(values("(a b) c (d)"), func(v lisp.Val) iter.Seq[lisp.Val] {
+			if g, ok := v.(lisp.Group); ok {
+				return slices.Values(g)
+			}
+			return slices.Values([]lisp.Val{v})
+		}),
+		want: "a b c d",
+	}, {
+		name:  "Take",
+		input: Take(values("a b c d"), 2),
+		want:  "a b",
+	}, {
+		name:  "Take more than available",
+		input: Take(values("a b"), 5),
+		want:  "a b",
+	}, {
+		name:  "Skip",
+		input: Skip(values("a b c d"), 3),
+		want:  "d",
+	}, {
+		name:  "Batch",
+		input: Batch(values("a b c d e"), 2),
+		want:  "(a b)(c d)(e)",
+	}, {
+		name:  "GroupByHead",
+		input: GroupByHead(values("(a 1) (a 2) (b 3) c c (a 4) ()")),
+		want:  "((a 1)(a 2))((b 3))(c c)((a 4))(())",
+	}, {
+		name:  "Zip",
+		input: Zip(values("a b c"), values("1 2")),
+		want:  "(a 1)(b 2)",
+	}, {
+		name:  "DistinctByHash",
+		input: DistinctByHash(values("a (b c) a b (b c) (b) b"), maphash.MakeSeed()),
+		want:  "a(b c)b(b)",
+	}, {
+		name:  "ParallelMap",
+		input: ParallelMap(values("a b c d e f g h"), 3, wrap),
+		want:  "(w a)(w b)(w c)(w d)(w e)(w f)(w g)(w h)",
+	}, {
+		name:  "ParallelMap early stop",
+		input: Take(ParallelMap(values("a b c d e f g h"), 2, wrap), 3),
+		want:  "(w a)(w b)(w c)",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			w := print.NewWriter(&sb)
+			w.NewLine = false
+			if err := Print(w, tc.input); err != nil {
+				t.Fatalf("Print(%q): got err = %v", tc.name, err)
+			}
+			if diff := cmp.Diff(tc.want, sb.String()); diff != "" {
+				t.Errorf("%s: got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestParallelMapOrder(t *testing.T) {
+	var want []lisp.Val
+	seq := func(yield func(lisp.Val) bool) {
+		for i := range 1000 {
+			if !yield(xlisp.Nat(uint64(i))) {
+				return
+			}
+		}
+	}
+	for v := range seq {
+		want = append(want, wrap(v))
+	}
+	got := slices.Collect(ParallelMap(seq, 16, wrap))
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParallelMap(): got diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestStore(t *testing.T) {
+	db := lispdb.NewInMemory()
+	if err := Store(db, values("1 2 3 1 2"), 2, 1); err != nil {
+		t.Fatalf("Store(): got err = %v", err)
+	}
+	if got, want := db.Len(), 3; got != want {
+		t.Errorf("Store(): got len = %d, want len = %d", got, want)
+	}
+}
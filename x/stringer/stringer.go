@@ -49,8 +49,7 @@ func Lit(x lisp.Lit) string {
 	if !appendLit(x, &sb, true) {
 		// The Lit appears to be invalid.
 		// Fall back to GoString instead.
-		sb.Reset()
-		appendGoLit(x, &sb)
+		return GoString(x)
 	}
 	return sb.String()
 }
@@ -83,8 +82,7 @@ func Group(x lisp.Group) string {
 	if valid := appendGroup(x, &sb); !valid {
 		// The Group appears to be invalid.
 		// Fall back to GoString instead.
-		sb.Reset()
-		appendGoGroup(x, &sb)
+		return GoString(x)
 	}
 	return sb.String()
 }
@@ -107,30 +105,13 @@ func appendGroup(x lisp.Group, sb *strings.Builder) (valid bool) {
 	return true
 }
 
-func appendGoVal(x lisp.Val, sb *strings.Builder) {
-	switch x := x.(type) {
-	case lisp.Lit:
-		appendGoLit(x, sb)
-	case lisp.Group:
-		appendGoGroup(x, sb)
-	case nil:
-		sb.WriteString("<nil>")
-	default:
-		// Value Unknown type.
-		fmt.Fprintf(sb, "<Vunk>(%#v)", x)
-	}
-}
-
-func appendGoLit(x lisp.Lit, sb *strings.Builder) { fmt.Fprintf(sb, "lisp.Lit(%q)", x) }
-
-func appendGoGroup(x lisp.Group, sb *strings.Builder) {
+// GoString returns the Go syntax representation of the Val.
+//
+// The result is a Go expression which constructs x such as lisp.Group{lisp.Lit("a")}.
+// GoString is equivalent to formatting x using %#v.
+func GoString(x lisp.Val) string {
 	if x == nil {
-		sb.WriteString("(lisp.Group)(nil)")
-		return
-	}
-	sb.WriteString("lisp.Group{")
-	for _, e := range x {
-		appendGoVal(e, sb)
+		return "nil"
 	}
-	sb.WriteByte('}')
+	return fmt.Sprintf("%#v", x)
 }
@@ -122,3 +122,34 @@ func TestGroup(t *testing.T) {
 		})
 	}
 }
+
+func TestGoString(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input lisp.Val
+		want  string
+	}{{
+		name:  "Lit",
+		input: lisp.Lit("a"),
+		want:  `lisp.Lit("a")`,
+	}, {
+		name:  "nil Group",
+		input: lisp.Group(nil),
+		want:  `(lisp.Group)(nil)`,
+	}, {
+		name:  "Group",
+		input: lisp.Group{lisp.Lit("a"), lisp.Group{}, lisp.Lit("1")},
+		want:  `lisp.Group{lisp.Lit("a"), lisp.Group{}, lisp.Lit("1")}`,
+	}, {
+		name:  "nil element",
+		input: lisp.Group{nil},
+		want:  `lisp.Group{nil}`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := GoString(tc.input)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("GoString(%q): got diff:\n%s", tc.name, diff)
+			}
+		})
+	}
+}
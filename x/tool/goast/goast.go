@@ -0,0 +1,31 @@
+// Binary goast prints the syntax tree of a Go source file as Lisp.
+package main
+
+import (
+	"flag"
+	"go/token"
+	"log"
+	"os"
+
+	"github.com/ajzaff/lisp/x/goast"
+	"github.com/ajzaff/lisp/x/print"
+)
+
+var (
+	file = flag.String("file", "", "Go source file to read.")
+	pos  = flag.Bool("pos", false, "Include positions as (Field Line Column).")
+)
+
+func main() {
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("-file is required")
+	}
+
+	v, err := goast.ParseFile(token.NewFileSet(), *file, nil, goast.Options{Positions: *pos})
+	if err != nil {
+		log.Fatal(err)
+	}
+	print.StdPrinter(os.Stdout).Print(v)
+}
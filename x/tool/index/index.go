@@ -0,0 +1,85 @@
+// Binary index builds and reads sidecar indexes of top-level expressions.
+//
+// Usage:
+//
+//	index -file data.lisp                  # Writes data.lisp.idx.
+//	index -file data.lisp -get 5 -count 10 # Prints expressions [5, 15).
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ajzaff/lisp/x/index"
+	"github.com/ajzaff/lisp/x/print"
+)
+
+var (
+	file  = flag.String("file", "", "File to index.")
+	out   = flag.String("index", "", "Index file (Default uses the file name with .idx appended).")
+	get   = flag.Int("get", -1, "Print the expression at the given position using an existing index.")
+	count = flag.Int("count", 1, "Number of expressions printed by -get.")
+)
+
+func main() {
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("-file is required")
+	}
+	if *out == "" {
+		*out = *file + ".idx"
+	}
+
+	if *get < 0 {
+		build()
+		return
+	}
+
+	data, err := os.Open(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer data.Close()
+	idx, err := os.Open(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer idx.Close()
+	fi, err := idx.Stat()
+	if err != nil {
+		log.Fatal(err)
+	}
+	r, err := index.NewReader(data, idx, fi.Size())
+	if err != nil {
+		log.Fatal(err)
+	}
+	end := min(*get+*count, r.Len())
+	p := print.StdPrinter(os.Stdout)
+	values, errFn := r.Values(*get, end)
+	for v := range values {
+		p.Print(v)
+	}
+	if err := errFn(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func build() {
+	data, err := os.Open(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer data.Close()
+	idx, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := index.Build(idx, data); err != nil {
+		log.Fatal(err)
+	}
+	if err := idx.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
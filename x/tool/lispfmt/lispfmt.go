@@ -0,0 +1,272 @@
+// Binary lispfmt formats Lisp source files in the canonical style.
+//
+// Without file arguments, lispfmt formats standard input to standard output.
+// Directory arguments are walked for .lisp files.
+//
+// With -check, or -l on standard input, lispfmt exits with a nonzero status
+// when the input is not formatted.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ajzaff/lisp/x/format"
+)
+
+var (
+	write    = flag.Bool("w", false, "Write the result to the source file instead of standard output.")
+	list     = flag.Bool("l", false, "List files whose formatting differs from lispfmt's.")
+	diff     = flag.Bool("d", false, "Display diffs instead of rewriting files.")
+	check    = flag.Bool("check", false, "Exit with a nonzero status if any file is not formatted.")
+	comments = flag.Bool("comments", false, "Allow line comments beginning with ';'. Groups containing comments are kept as is.")
+	width    = flag.Int("width", 80, "Target line width (0 prints each top-level expression on one line).")
+)
+
+func main() {
+	flag.Parse()
+
+	opts := format.Options{Width: *width, Comments: *comments}
+
+	if flag.NArg() == 0 {
+		if *write {
+			log.Fatal("cannot use -w with standard input")
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		changed, err := process("<standard input>", src, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if (*check || *list) && changed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var unformatted, failed bool
+	for _, arg := range flag.Args() {
+		err := filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || path != arg && !strings.HasSuffix(path, ".lisp") {
+				return nil
+			}
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			changed, err := process(path, src, opts)
+			if err != nil {
+				log.Printf("%s: %v", path, err)
+				failed = true
+				return nil
+			}
+			unformatted = unformatted || changed
+			return nil
+		})
+		if err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+	if failed || *check && unformatted {
+		os.Exit(1)
+	}
+}
+
+// process formats the file and reports whether it changed.
+func process(path string, src []byte, opts format.Options) (bool, error) {
+	res, err := format.Format(src, opts)
+	if err != nil {
+		return false, err
+	}
+	changed := !bytes.Equal(src, res)
+	if *list && changed {
+		fmt.Println(path)
+	}
+	if *diff && changed {
+		fmt.Print(unifiedDiff(path, string(src), string(res)))
+	}
+	if *write && changed {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(path, res, fi.Mode().Perm()); err != nil {
+			return false, err
+		}
+	}
+	if !*list && !*diff && !*write && !*check {
+		os.Stdout.Write(res)
+	}
+	return changed, nil
+}
+
+// edit is a line of an edit script prefixed with ' ', '-' or '+'.
+type edit struct {
+	op   byte
+	line string
+	i, j int // Line numbers in a and b before this edit.
+}
+
+// unifiedDiff returns a unified diff of the lines of a and b.
+func unifiedDiff(path, a, b string) string {
+	const context = 3
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s.orig\n+++ %s\n", path, path)
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			k++
+			continue
+		}
+		// Extend the hunk while changes are within 2*context lines.
+		start := max(0, k-context)
+		end := k
+		for n := k + 1; n < len(edits); n++ {
+			if edits[n].op == ' ' {
+				continue
+			}
+			if n-end-1 > 2*context {
+				break
+			}
+			end = n
+		}
+		end = min(len(edits), end+context+1)
+		var nx, ny int
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				nx++
+			}
+			if e.op != '-' {
+				ny++
+			}
+		}
+		// Empty ranges start at the line before as in diff -u.
+		i, j := edits[start].i+1, edits[start].j+1
+		if nx == 0 {
+			i--
+		}
+		if ny == 0 {
+			j--
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", i, nx, j, ny)
+		for _, e := range edits[start:end] {
+			fmt.Fprintf(&sb, "%c%s\n", e.op, e.line)
+		}
+		k = end
+	}
+	return sb.String()
+}
+
+// diffLines returns a shortest edit script from x to y.
+//
+// It uses the linear space variant of Myers' O(ND) algorithm.
+func diffLines(x, y []string) []edit {
+	d := differ{x: x, y: y}
+	d.diff(0, len(x), 0, len(y))
+	return d.edits
+}
+
+type differ struct {
+	x, y  []string
+	edits []edit
+}
+
+// diff appends the edits from x[i0:i1] to y[j0:j1].
+func (d *differ) diff(i0, i1, j0, j1 int) {
+	for i0 < i1 && j0 < j1 && d.x[i0] == d.y[j0] {
+		d.edits = append(d.edits, edit{' ', d.x[i0], i0, j0})
+		i0, j0 = i0+1, j0+1
+	}
+	n := 0 // Length of the common suffix.
+	for i0 < i1 && j0 < j1 && d.x[i1-1] == d.y[j1-1] {
+		i1, j1, n = i1-1, j1-1, n+1
+	}
+	switch {
+	case i0 == i1:
+		for j := j0; j < j1; j++ {
+			d.edits = append(d.edits, edit{'+', d.y[j], i0, j})
+		}
+	case j0 == j1:
+		for i := i0; i < i1; i++ {
+			d.edits = append(d.edits, edit{'-', d.x[i], i, j0})
+		}
+	default:
+		x, y, u, v := middleSnake(d.x[i0:i1], d.y[j0:j1])
+		d.diff(i0, i0+x, j0, j0+y)
+		for k := range u - x {
+			d.edits = append(d.edits, edit{' ', d.x[i0+x+k], i0 + x + k, j0 + y + k})
+		}
+		d.diff(i0+u, i1, j0+v, j1)
+	}
+	for k := range n {
+		d.edits = append(d.edits, edit{' ', d.x[i1+k], i1 + k, j1 + k})
+	}
+}
+
+// middleSnake returns the start (x, y) and end (u, v) of the middle snake
+// of a shortest edit script from a to b.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	dmax := (n + m + 1) / 2
+	off := dmax + 1
+	// vf[off+k] and vb[off+k] are the furthest x reached on diagonal k
+	// searching forward from the start and backward from the end.
+	vf := make([]int, 2*dmax+3)
+	vb := make([]int, 2*dmax+3)
+	for d := 0; d <= dmax; d++ {
+		for k := -d; k <= d; k += 2 {
+			x := vf[off+k+1]
+			if k != -d && (k == d || vf[off+k-1] >= vf[off+k+1]) {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			vf[off+k] = x
+			if kr := delta - k; odd && -d < kr && kr < d && x+vb[off+kr] >= n {
+				return x0, y0, x, y
+			}
+		}
+		for kr := -d; kr <= d; kr += 2 {
+			xr := vb[off+kr+1]
+			if kr != -d && (kr == d || vb[off+kr-1] >= vb[off+kr+1]) {
+				xr = vb[off+kr-1] + 1
+			}
+			yr := xr - kr
+			xr0, yr0 := xr, yr
+			for xr < n && yr < m && a[n-1-xr] == b[m-1-yr] {
+				xr, yr = xr+1, yr+1
+			}
+			vb[off+kr] = xr
+			if k := delta - kr; !odd && -d <= k && k <= d && vf[off+k]+xr >= n {
+				return n - xr, m - yr, n - xr0, m - yr0
+			}
+		}
+	}
+	panic("unreachable")
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// lcs returns the length of the longest common subsequence of x and y.
+func lcs(x, y []string) int {
+	dp := make([][]int, len(x)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func TestDiffLines(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	randLines := func() []string {
+		lines := make([]string, r.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + r.Intn(3)))
+		}
+		return lines
+	}
+	for range 2000 {
+		x, y := randLines(), randLines()
+		edits := diffLines(x, y)
+		var gotX, gotY []string
+		i, j, common := 0, 0, 0
+		for _, e := range edits {
+			if e.i != i || e.j != j {
+				t.Fatalf("diffLines(%q, %q): got edit %+v at lines (%d, %d)", x, y, e, i, j)
+			}
+			switch e.op {
+			case ' ':
+				gotX, gotY = append(gotX, e.line), append(gotY, e.line)
+				i, j, common = i+1, j+1, common+1
+			case '-':
+				gotX = append(gotX, e.line)
+				i++
+			case '+':
+				gotY = append(gotY, e.line)
+				j++
+			}
+		}
+		if diff := cmp.Diff(x, gotX, cmpopts.EquateEmpty()); diff != "" {
+			t.Fatalf("diffLines(%q, %q): got diff of x (-want, +got):\n%v", x, y, diff)
+		}
+		if diff := cmp.Diff(y, gotY, cmpopts.EquateEmpty()); diff != "" {
+			t.Fatalf("diffLines(%q, %q): got diff of y (-want, +got):\n%v", x, y, diff)
+		}
+		if want := lcs(x, y); common != want {
+			t.Fatalf("diffLines(%q, %q): got %d common lines, want minimal edits with %d", x, y, common, want)
+		}
+	}
+}
+
+func numberedLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprint(i + 1)
+	}
+	return lines
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	long := numberedLines(20)
+	changed := numberedLines(20)
+	changed[1], changed[18] = "x", "y"
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want string
+	}{{
+		name: "empty",
+		want: "--- p.orig\n+++ p\n",
+	}, {
+		name: "change",
+		a:    "a\nb\nc\n",
+		b:    "a\nx\nc\n",
+		want: "--- p.orig\n+++ p\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+	}, {
+		name: "add to empty",
+		b:    "a\nb\n",
+		want: "--- p.orig\n+++ p\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+	}, {
+		name: "remove all",
+		a:    "a\n",
+		want: "--- p.orig\n+++ p\n@@ -1,1 +0,0 @@\n-a\n",
+	}, {
+		name: "two hunks",
+		a:    strings.Join(long, "\n") + "\n",
+		b:    strings.Join(changed, "\n") + "\n",
+		want: "--- p.orig\n+++ p\n" +
+			"@@ -1,5 +1,5 @@\n 1\n-2\n+x\n 3\n 4\n 5\n" +
+			"@@ -16,5 +16,5 @@\n 16\n 17\n 18\n-19\n+y\n 20\n",
+	}, {
+		name: "nearby changes share a hunk",
+		a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+		b:    "x\n2\n3\n4\n5\n6\n7\ny\n9\n10\n",
+		want: "--- p.orig\n+++ p\n@@ -1,10 +1,10 @@\n-1\n+x\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+y\n 9\n 10\n",
+	}, {
+		name: "distant changes split hunks",
+		a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+		b:    "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+		want: "--- p.orig\n+++ p\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+y\n",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := unifiedDiff("p", tc.a, tc.b)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unifiedDiff(%q): got diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
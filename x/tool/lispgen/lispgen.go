@@ -0,0 +1,148 @@
+// Binary lispgen generates Go source declaring the values of Lisp files.
+//
+// Lispgen is intended for use with go:generate:
+//
+//	//go:generate go run github.com/ajzaff/lisp/x/tool/lispgen -name Rules rules.lisp
+//
+// In go mode, the values are declared as a []lisp.Val variable using Go literals.
+// In blisp mode, the values are written to a blisp file embedded in the package
+// and the variable is a func() []lisp.Val which decodes them on first use.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/ajzaff/lisp/x/blisp"
+	"github.com/ajzaff/lisp/x/stringer"
+)
+
+var (
+	pkg  = flag.String("package", os.Getenv("GOPACKAGE"), "Package name of the generated file (Default uses $GOPACKAGE)")
+	name = flag.String("name", "Values", "Name of the generated variable.")
+	out  = flag.String("o", "", "Output file (Default uses <name>_lisp.go)")
+	mode = flag.String("mode", "go", `Generation mode ("go" or "blisp")`)
+)
+
+func main() {
+	flag.Parse()
+
+	if *pkg == "" {
+		log.Fatal("-package is required outside go:generate")
+	}
+	if flag.NArg() == 0 {
+		log.Fatal("at least one Lisp file is required")
+	}
+	if *out == "" {
+		*out = strings.ToLower(*name) + "_lisp.go"
+	}
+
+	var vs []lisp.Val
+	for _, file := range flag.Args() {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var sc scan.Scanner
+		sc.Reset(bytes.NewReader(src))
+		for n := range sc.Nodes() {
+			vs = append(vs, n.Val)
+		}
+		if err := sc.Err(); err != nil {
+			log.Fatalf("%s: %v", file, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by lispgen %s; DO NOT EDIT.\n\n", strings.Join(flag.Args(), " "))
+	fmt.Fprintf(&buf, "package %s\n\n", *pkg)
+
+	switch *mode {
+	case "go":
+		buf.WriteString("import \"github.com/ajzaff/lisp\"\n\n")
+		fmt.Fprintf(&buf, "// %s holds the values of %s.\n", *name, strings.Join(flag.Args(), ", "))
+		fmt.Fprintf(&buf, "var %s = []lisp.Val{\n", *name)
+		for _, v := range vs {
+			fmt.Fprintf(&buf, "%s,\n", stringer.GoString(v))
+		}
+		buf.WriteString("}\n")
+	case "blisp":
+		blob := strings.TrimSuffix(*out, ".go") + ".blisp"
+		writeBlisp(blob, vs)
+		buf.WriteString(`import (
+	"bytes"
+	_ "embed"
+	"sync"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/x/blisp"
+)
+
+`)
+		data := lowerFirst(*name) + "Data"
+		fmt.Fprintf(&buf, "//go:embed %s\nvar %s []byte\n\n", filepath.Base(blob), data)
+		fmt.Fprintf(&buf, "// %s returns the values of %s.\n", *name, strings.Join(flag.Args(), ", "))
+		fmt.Fprintf(&buf, "//\n// The values are decoded on first use.\n")
+		fmt.Fprintf(&buf, `var %s = sync.OnceValue(func() []lisp.Val {
+	var d blisp.Decoder
+	d.Reset(bytes.NewReader(%s))
+	if err := d.DecodeMagic(); err != nil {
+		panic(err)
+	}
+	var vs []lisp.Val
+	for v := range d.Values() {
+		vs = append(vs, v)
+	}
+	if err := d.Err(); err != nil {
+		panic(err)
+	}
+	return vs
+})
+`, *name, data)
+	default:
+		log.Fatalf("unexpected -mode: %v", *mode)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("formatting generated source: %v", err)
+	}
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func writeBlisp(file string, vs []lisp.Val) {
+	var buf bytes.Buffer
+	var e blisp.Encoder
+	e.Reset(&buf)
+	if err := e.EncodeMagic(); err != nil {
+		log.Fatal(err)
+	}
+	for _, v := range vs {
+		if err := e.Encode(v); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ajzaff/lisp"
+	"github.com/ajzaff/lisp/scan"
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustParseMultiple(t *testing.T, src string) []lisp.Val {
+	t.Helper()
+	var sc scan.Scanner
+	sc.Reset(strings.NewReader(src))
+	var vs []lisp.Val
+	for n := range sc.Nodes() {
+		vs = append(vs, n.Val)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("mustParseMultiple(%q): got err = %v", src, err)
+	}
+	return vs
+}
+
+// goCmd runs the go command in dir and returns its standard output.
+func goCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go %s: got err = %v:\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return string(out)
+}
+
+func TestLispgen(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	root, err := filepath.Abs("../../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	gen := filepath.Join(dir, "gen")
+	if err := os.Mkdir(gen, 0755); err != nil {
+		t.Fatal(err)
+	}
+	lispgen := filepath.Join(dir, "lispgen")
+	goCmd(t, ".", "build", "-o", lispgen, ".")
+
+	const src = "a (b 1 ()) (c (d (e)))\n0 (f g)\n"
+	files := map[string]string{
+		"go.mod": fmt.Sprintf("module lispgentest\n\ngo 1.23.5\n\nrequire github.com/ajzaff/lisp v0.0.0\n\nreplace github.com/ajzaff/lisp => %s\n", root),
+		"go.sum": string(sum),
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"lispgentest/gen"
+)
+
+func main() {
+	fmt.Printf("%#v\n", gen.Go)
+	fmt.Printf("%#v\n", gen.Blisp())
+	fmt.Printf("%#v\n", gen.EmptyGo)
+	fmt.Printf("%#v\n", gen.EmptyBlisp())
+}
+`,
+		"gen/values.lisp": src,
+		"gen/empty.lisp":  "",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-name", "Go", "-mode", "go", "values.lisp"},
+		{"-name", "Blisp", "-mode", "blisp", "values.lisp"},
+		{"-name", "EmptyGo", "-mode", "go", "empty.lisp"},
+		{"-name", "EmptyBlisp", "-mode", "blisp", "empty.lisp"},
+	} {
+		cmd := exec.Command(lispgen, append([]string{"-package", "gen"}, args...)...)
+		cmd.Dir = gen
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("lispgen %s: got err = %v:\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+
+	vs := fmt.Sprintf("%#v", mustParseMultiple(t, src))
+	want := strings.Join([]string{vs, vs, fmt.Sprintf("%#v", []lisp.Val{}), fmt.Sprintf("%#v", []lisp.Val(nil))}, "\n") + "\n"
+	got := goCmd(t, dir, "run", ".")
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("lispgen: got diff (-want, +got):\n%v", diff)
+	}
+}
@@ -16,19 +16,31 @@ import (
 	"github.com/ajzaff/lisp/visit"
 	"github.com/ajzaff/lisp/x/blisp"
 	"github.com/ajzaff/lisp/x/hash"
+	"github.com/ajzaff/lisp/x/lispcsv"
 	"github.com/ajzaff/lisp/x/lispdb"
 	"github.com/ajzaff/lisp/x/lispjson"
+	"github.com/ajzaff/lisp/x/lispxml"
 	"github.com/ajzaff/lisp/x/print"
 	"github.com/ajzaff/lisp/x/stringer"
 	"golang.org/x/text/unicode/rangetable"
 )
 
 var (
-	order = flag.String("order", "", `Print order for AST print mode (Optional "reverse". Default uses in-order)`)
-	mode  = flag.String("mode", "", `Print mode (Optional "tok", "ast", "db", "bin", "json", "idtab", "none". Default uses StdPrinter)`)
-	file  = flag.String("file", "", "File to read lisp code from.")
+	order  = flag.String("order", "", `Print order for AST print mode (Optional "reverse". Default uses in-order)`)
+	mode   = flag.String("mode", "", `Print mode (Optional "tok", "ast", "db", "bin", "json", "xml", "csv", "tsv", "idtab", "none". Default uses StdPrinter)`)
+	file   = flag.String("file", "", "File to read lisp code from.")
+	header = flag.Bool("header", false, "Use a header row for CSV and TSV input and output.")
+	in     = flag.String("in", "", `Input format (Optional "bin", "json", "xml", "csv", "tsv". Default uses text)`)
 )
 
+func csvOptions(format string) lispcsv.Options {
+	opts := lispcsv.Options{Header: *header}
+	if format == "tsv" {
+		opts.Comma = '\t'
+	}
+	return opts
+}
+
 var tokStr = []string{"?", "Id", "(", ")"}
 
 func main() {
@@ -45,13 +57,54 @@ func main() {
 	}
 
 	var vs []lisp.Val
-	var sc scan.Scanner
-	sc.Reset(bytes.NewReader(src))
-	for n := range sc.Nodes() {
-		vs = append(vs, n.Val)
-	}
-	if err := sc.Err(); err != nil {
-		log.Fatal(err)
+	switch *in {
+	case "": // text
+		var sc scan.Scanner
+		sc.Reset(bytes.NewReader(src))
+		for n := range sc.Nodes() {
+			vs = append(vs, n.Val)
+		}
+		if err := sc.Err(); err != nil {
+			log.Fatal(err)
+		}
+	case "bin":
+		var d blisp.Decoder
+		d.Reset(bytes.NewReader(src))
+		if err := d.DecodeMagic(); err != nil {
+			log.Fatal(err)
+		}
+		for v := range d.Values() {
+			vs = append(vs, v)
+		}
+		if err := d.Err(); err != nil {
+			log.Fatal(err)
+		}
+	case "json":
+		d := lispjson.NewDecoder(bytes.NewReader(src))
+		for v := range d.Values() {
+			vs = append(vs, v)
+		}
+		if err := d.Err(); err != nil {
+			log.Fatal(err)
+		}
+	case "xml":
+		d := lispxml.NewDecoder(bytes.NewReader(src), lispxml.Options{})
+		for v := range d.Values() {
+			vs = append(vs, v)
+		}
+		if err := d.Err(); err != nil {
+			log.Fatal(err)
+		}
+	case "csv", "tsv":
+		d := lispcsv.NewDecoder(bytes.NewReader(src), csvOptions(*in))
+		for v := range d.Values() {
+			vs = append(vs, v)
+		}
+		if err := d.Err(); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unexpected -in format: %v", *in)
 	}
 
 	switch *mode {
@@ -95,12 +148,8 @@ func main() {
 			Refs        []uint64
 			InverseRefs []uint64
 		})
-		var h hash.MapHash
-		h.SetSeed(db.Seed())
 		for _, v := range vs {
-			h.Reset()
-			h.WriteVal(v)
-			root := h.Sum64()
+			root := hash.SumVal(db.Seed(), v)
 			lispdb.EachTransRef(db, root, func(i lispdb.ID) bool {
 				v, w := lispdb.QueryOneID(db, i)
 				var idRefs []lispdb.ID
@@ -148,12 +197,34 @@ func main() {
 		e.Reset(os.Stdout)
 		e.EncodeMagic()
 		for _, v := range vs {
-			e.Encode(v)
+			if err := e.Encode(v); err != nil {
+				log.Fatal(err)
+			}
 		}
 	case "json":
+		e := lispjson.NewEncoder(os.Stdout)
 		for _, v := range vs {
-			lispjson.NewEncoder(os.Stdout).Encode(v)
-			println()
+			if err := e.Encode(v); err != nil {
+				log.Fatal(err)
+			}
+		}
+	case "xml":
+		e := lispxml.NewEncoder(os.Stdout, lispxml.Options{})
+		for _, v := range vs {
+			if err := e.Encode(v); err != nil {
+				log.Fatal(err)
+			}
+		}
+		fmt.Println()
+	case "csv", "tsv":
+		e := lispcsv.NewEncoder(os.Stdout, csvOptions(*mode))
+		for _, v := range vs {
+			if err := e.Encode(v); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := e.Close(); err != nil {
+			log.Fatal(err)
 		}
 	case "idtab":
 		t := rangetable.Merge(unicode.Letter)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
@@ -10,15 +11,19 @@ import (
 
 	"github.com/ajzaff/lisp"
 	"github.com/ajzaff/lisp/scan"
+	"github.com/ajzaff/lisp/x/highlight"
 	"github.com/ajzaff/lisp/x/print"
 )
 
+var color = flag.Bool("color", false, "Highlight output with ANSI colors.")
+
 const (
 	cur  = "> "
 	cont = "... "
 )
 
 func main() {
+	flag.Parse()
 
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGINT)
@@ -47,7 +52,13 @@ func main() {
 				continue
 			}
 			for _, v := range vs {
-				print.StdPrinter(os.Stdout).Print(v)
+				if !*color {
+					print.StdPrinter(os.Stdout).Print(v)
+					continue
+				}
+				var buf strings.Builder
+				print.StdPrinter(&buf).Print(v)
+				highlight.ANSI(os.Stdout, []byte(buf.String()), highlight.Default)
 			}
 			sb.Reset()
 		case input == "quit":
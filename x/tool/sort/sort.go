@@ -0,0 +1,78 @@
+// Binary sort sorts the top-level values of large Lisp files using bounded memory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ajzaff/lisp/x/extsort"
+	xlisp "github.com/ajzaff/lisp/x/lisp"
+)
+
+var (
+	file    = flag.String("file", "", "File to read lisp code from (Default uses stdin).")
+	out     = flag.String("o", "", "File to write sorted output to (Default uses stdout).")
+	key     = flag.String("key", "", `Comma separated path of Group indices used as the sort key (e.g. "1,0").`)
+	lexical = flag.Bool("lexical", false, "Whether to use LexicalCompare instead of Compare.")
+	dedup   = flag.Bool("dedup", false, "Whether to drop values with duplicate keys.")
+	count   = flag.Bool("count", false, "Whether to output (n v) counting values with duplicate keys.")
+	mem     = flag.Int("mem", extsort.DefaultMaxBytes>>20, "Memory budget in MiB.")
+	tmp     = flag.String("tmp", "", "Directory for spill files (Default uses the system temp directory).")
+)
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run sorts the input so deferred files are closed before main exits.
+func run() error {
+	opts := extsort.Options{
+		Dedup:    *dedup,
+		Count:    *count,
+		MaxBytes: *mem << 20,
+		TempDir:  *tmp,
+	}
+	if *lexical {
+		opts.Compare = xlisp.LexicalCompare
+	}
+	if *key != "" {
+		for _, s := range strings.Split(*key, ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("bad -key: %v", err)
+			}
+			opts.Key = append(opts.Key, i)
+		}
+	}
+
+	var r io.Reader = os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	var w io.WriteCloser = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := extsort.Sort(w, r, opts); err != nil {
+		return err
+	}
+	return w.Close()
+}